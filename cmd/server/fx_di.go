@@ -6,6 +6,9 @@ import (
 	"gwid.io/gwid-core/internal/controllers"
 	"gwid.io/gwid-core/internal/cron"
 	"gwid.io/gwid-core/internal/database"
+	"gwid.io/gwid-core/internal/mailer"
+	"gwid.io/gwid-core/internal/middleware"
+	"gwid.io/gwid-core/internal/oauth"
 	"gwid.io/gwid-core/internal/repositories"
 	"gwid.io/gwid-core/internal/router"
 	"gwid.io/gwid-core/internal/services"
@@ -17,16 +20,30 @@ func RunGwidCore() {
 			config.NewConfig,
 
 			database.NewDatabase,
+			database.NewRedis,
 
 			repositories.NewUserRepository,
 			repositories.NewGatewayRepository,
 			repositories.NewAWSCredentialsRepository,
 			repositories.NewEC2Repository,
 			repositories.NewReferralRewardRepository,
+			repositories.NewSessionRepository,
+			repositories.NewUserTokenRepository,
+			repositories.NewRecoveryCodeRepository,
+			repositories.NewOAuthRepository,
+			repositories.NewAPIKeyRepository,
+			repositories.NewOrganizationRepository,
+			repositories.NewAuditRepository,
+			repositories.NewAccountDeletionRepository,
+
+			mailer.NewMailer,
+			oauth.NewRegistry,
 
 			services.NewAuthService,
+			services.NewTwoFactorService,
+			services.NewOAuthService,
 			services.NewJwtService,
-			services.NewUserService,
+			fx.Annotate(services.NewUserService, fx.As(fx.Self()), fx.As(new(middleware.EmailVerificationChecker)), fx.As(new(middleware.RoleChecker))),
 			services.NewGatewayService,
 			services.NewRegionService,
 			services.NewAWSCredentialsService,
@@ -34,16 +51,34 @@ func RunGwidCore() {
 			services.NewEC2Service,
 			services.NewGatewayTaskService,
 			services.NewReferralRewardService,
+			services.NewCloudflareService,
+			services.NewAWSComputeProvider,
+			services.NewFakeComputeProvider,
+			fx.Annotate(services.NewSessionService, fx.As(fx.Self()), fx.As(new(middleware.SessionValidator))),
+			services.NewComputeProviderRegistry,
+			fx.Annotate(services.NewAPIKeyService, fx.As(fx.Self()), fx.As(new(middleware.APIKeyValidator))),
+			services.NewAuditService,
+			services.NewLoginThrottleService,
+			services.NewAdminService,
+			services.NewAccountDeletionService,
+			services.NewAWSCredentialsDeletionService,
+			fx.Annotate(services.NewOrganizationService, fx.As(fx.Self()), fx.As(new(middleware.OrganizationResolver))),
 
 			controllers.NewAuthController,
+			controllers.NewOAuthController,
 			controllers.NewUserController,
 			controllers.NewGatewayController,
 			controllers.NewRegionController,
 			controllers.NewAWSCredentialsController,
 			controllers.NewEC2Controller,
+			controllers.NewAPIKeyController,
+			controllers.NewOrganizationController,
+			controllers.NewAdminController,
 
 			cron.NewCronService,
 			cron.NewEC2Cron,
+			cron.NewEncryptionCron,
+			cron.NewOAuthCron,
 
 			router.NewRouter,
 			NewGinServer,
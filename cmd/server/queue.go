@@ -11,7 +11,7 @@ import (
 	"gwid.io/gwid-core/internal/utils"
 )
 
-func RunQueueServer(lc fx.Lifecycle, cfg *config.Config, gatewayTaskService *services.GatewayTaskService) {
+func RunQueueServer(lc fx.Lifecycle, cfg *config.Config, gatewayTaskService *services.GatewayTaskService, accountDeletionService *services.AccountDeletionService, awsCredentialsDeletionService *services.AWSCredentialsDeletionService) {
 	srv := asynq.NewServer(
 		asynq.RedisClientOpt{Addr: cfg.RedisAddress, Password: cfg.RedisPassword},
 		asynq.Config{
@@ -25,7 +25,14 @@ func RunQueueServer(lc fx.Lifecycle, cfg *config.Config, gatewayTaskService *ser
 	)
 
 	mux := asynq.NewServeMux()
+	mux.HandleFunc(utils.TypeDeployGateway, gatewayTaskService.HandleDeployGatewayTask)
+	mux.HandleFunc(utils.TypeTerminateGateway, gatewayTaskService.HandleTerminateGatewayTask)
+	mux.HandleFunc(utils.TypeStopGateway, gatewayTaskService.HandleStopGatewayTask)
+	mux.HandleFunc(utils.TypeStartGateway, gatewayTaskService.HandleStartGatewayTask)
+	mux.HandleFunc(utils.TypeRebootGateway, gatewayTaskService.HandleRebootGatewayTask)
 	mux.HandleFunc(utils.TypeDeployAWSGateway, gatewayTaskService.HandleAWSDeployGatewayTask)
+	mux.HandleFunc(utils.TypeDeleteAccount, accountDeletionService.HandleDeleteAccountTask)
+	mux.HandleFunc(utils.TypeDeleteAWSCredentials, awsCredentialsDeletionService.HandleDeleteAWSCredentialsTask)
 
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -16,12 +18,63 @@ type Config struct {
 	RedisAddress       string
 	RedisPassword      string
 	EncryptionKey      string
+	EncryptionKeys     map[string]string
+	EncryptionKeyID    string
+	EncryptionBackend  string
+	Vault              VaultConfig
 	AwsAccessID        string
 	AwsSecretAccessKey string
 	DevPostgresConfig  PostgresConfig
 	ProdPostgresConfig PostgresConfig
 	CloudflareAPIToken string
 	CloudflareZoneName string
+	LivepeerVersion    string
+	ComputeBackend     string
+	AppURL             string
+	Mail               MailConfig
+	FakeCompute        FakeComputeConfig
+	OAuthRedirectURL   string
+	OAuthProviders     map[string]OAuthProviderConfig
+}
+
+type MailConfig struct {
+	Driver       string
+	From         string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	LogFile      string
+}
+
+// VaultConfig points at the Vault Transit engine used when EncryptionBackend
+// is "vault".
+type VaultConfig struct {
+	Address      string
+	Token        string
+	Namespace    string
+	TransitMount string
+	TransitKey   string
+}
+
+// FakeComputeConfig tunes the in-memory compute provider used when
+// ComputeBackend is "fake".
+type FakeComputeConfig struct {
+	BootDelay     time.Duration
+	CommandDelay  time.Duration
+	FailStep      string
+	CommandOutput string
+}
+
+// OAuthProviderConfig configures a social or SSO login provider. Kind is
+// "oidc" for providers with OpenID Connect discovery at Issuer, or "github".
+type OAuthProviderConfig struct {
+	Name         string
+	Kind         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
 }
 
 type PostgresConfig struct {
@@ -39,17 +92,47 @@ func NewConfig() *Config {
 	}
 
 	env := &Config{
-		Environment:        GetEnv("ENVIRONMENT", "development"),
-		Port:               GetEnv("PORT", "5000"),
-		GinMode:            GetEnv("GIN_MODE", "release"),
-		JwtSecret:          GetEnv("JWT_SECRET", "the-fallback-key"),
-		RedisAddress:       GetEnv("REDIS_ADDRESS", ""),
-		RedisPassword:      GetEnv("REDIS_PASSWORD", ""),
-		EncryptionKey:      GetEnv("ENCRYPTION_KEY", ""),
+		Environment:       GetEnv("ENVIRONMENT", "development"),
+		Port:              GetEnv("PORT", "5000"),
+		GinMode:           GetEnv("GIN_MODE", "release"),
+		JwtSecret:         GetEnv("JWT_SECRET", "the-fallback-key"),
+		RedisAddress:      GetEnv("REDIS_ADDRESS", ""),
+		RedisPassword:     GetEnv("REDIS_PASSWORD", ""),
+		EncryptionKey:     GetEnv("ENCRYPTION_KEY", ""),
+		EncryptionKeys:    ParseKeyring(GetEnv("ENCRYPTION_KEYS", "")),
+		EncryptionKeyID:   GetEnv("ENCRYPTION_ACTIVE_KEY_ID", ""),
+		EncryptionBackend: GetEnv("ENCRYPTION_BACKEND", "local"),
+		Vault: VaultConfig{
+			Address:      GetEnv("VAULT_ADDR", "http://127.0.0.1:8200"),
+			Token:        GetEnv("VAULT_TOKEN", ""),
+			Namespace:    GetEnv("VAULT_NAMESPACE", ""),
+			TransitMount: GetEnv("VAULT_TRANSIT_MOUNT", "transit"),
+			TransitKey:   GetEnv("VAULT_TRANSIT_KEY", "gwid"),
+		},
 		AwsAccessID:        GetEnv("AWS_ACCESS_ID", ""),
 		AwsSecretAccessKey: GetEnv("AWS_SECRET_ACCESS_KEY", ""),
 		CloudflareAPIToken: GetEnv("CF_API_TOKEN", ""),
 		CloudflareZoneName: GetEnv("CF_ZONE_NAME", ""),
+		LivepeerVersion:    GetEnv("LIVEPEER_VERSION", "v0.8.5"),
+		ComputeBackend:     GetEnv("COMPUTE_BACKEND", "aws"),
+		AppURL:             GetEnv("APP_URL", "http://localhost:3000"),
+		Mail: MailConfig{
+			Driver:       GetEnv("MAIL_DRIVER", "log"),
+			From:         GetEnv("MAIL_FROM", "GWID <no-reply@gwid.io>"),
+			SMTPHost:     GetEnv("SMTP_HOST", "localhost"),
+			SMTPPort:     GetEnv("SMTP_PORT", "587"),
+			SMTPUsername: GetEnv("SMTP_USERNAME", ""),
+			SMTPPassword: GetEnv("SMTP_PASSWORD", ""),
+			LogFile:      GetEnv("MAIL_LOG_FILE", ""),
+		},
+		OAuthRedirectURL: GetEnv("OAUTH_REDIRECT_URL", ""),
+		OAuthProviders:   ParseOAuthProviders(GetEnv("OAUTH_PROVIDERS", "")),
+		FakeCompute: FakeComputeConfig{
+			BootDelay:     GetEnvAsDuration("FAKE_COMPUTE_BOOT_DELAY", 5*time.Second),
+			CommandDelay:  GetEnvAsDuration("FAKE_COMPUTE_COMMAND_DELAY", 10*time.Second),
+			FailStep:      GetEnv("FAKE_COMPUTE_FAIL_STEP", ""),
+			CommandOutput: GetEnv("FAKE_COMPUTE_COMMAND_OUTPUT", ""),
+		},
 		DevPostgresConfig: PostgresConfig{
 			Host:         GetEnv("DEV_DB_HOST", "localhost"),
 			Port:         GetEnv("DEV_DB_PORT", "5432"),
@@ -69,6 +152,21 @@ func NewConfig() *Config {
 		},
 	}
 
+	if env.OAuthRedirectURL == "" {
+		env.OAuthRedirectURL = strings.TrimSuffix(env.AppURL, "/") + "/oauth/callback"
+	}
+
+	// Without a keyring the single legacy key becomes the only versioned key.
+	if len(env.EncryptionKeys) == 0 && env.EncryptionKey != "" {
+		env.EncryptionKeys = map[string]string{"v1": env.EncryptionKey}
+	}
+
+	if env.EncryptionKeyID == "" && len(env.EncryptionKeys) == 1 {
+		for keyID := range env.EncryptionKeys {
+			env.EncryptionKeyID = keyID
+		}
+	}
+
 	return env
 }
 
@@ -107,3 +205,78 @@ func GetEnvAsInt(key string, fallback int) int {
 	}
 	return fallback
 }
+
+func GetEnvAsDuration(key string, fallback time.Duration) time.Duration {
+	if valueStr, exists := os.LookupEnv(key); exists {
+		if value, err := time.ParseDuration(valueStr); err == nil {
+			return value
+		}
+	}
+	return fallback
+}
+
+// ParseKeyring reads a comma separated list of id:key pairs. Key IDs cannot
+// contain colons and keys cannot contain commas.
+func ParseKeyring(value string) map[string]string {
+	keyring := make(map[string]string)
+
+	for i, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		keyID, key, ok := strings.Cut(entry, ":")
+		if !ok || keyID == "" || key == "" {
+			fmt.Printf("Warning: ignoring malformed keyring entry %d\n", i+1)
+			continue
+		}
+
+		keyring[keyID] = key
+	}
+
+	return keyring
+}
+
+// ParseOAuthProviders reads the comma separated provider names in
+// OAUTH_PROVIDERS and the OAUTH_<NAME>_* variables of each. Providers without
+// a client ID are skipped.
+func ParseOAuthProviders(value string) map[string]OAuthProviderConfig {
+	providers := make(map[string]OAuthProviderConfig)
+
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OAUTH_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+
+		defaultKind, defaultIssuer, defaultScopes := "oidc", "", "openid email profile"
+
+		switch name {
+		case "github":
+			defaultKind, defaultScopes = "github", "read:user user:email"
+		case "google":
+			defaultIssuer = "https://accounts.google.com"
+		}
+
+		provider := OAuthProviderConfig{
+			Name:         name,
+			Kind:         GetEnv(prefix+"KIND", defaultKind),
+			Issuer:       strings.TrimSuffix(GetEnv(prefix+"ISSUER", defaultIssuer), "/"),
+			ClientID:     GetEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: GetEnv(prefix+"CLIENT_SECRET", ""),
+			Scopes:       strings.Fields(GetEnv(prefix+"SCOPES", defaultScopes)),
+		}
+
+		if provider.ClientID == "" {
+			fmt.Printf("Warning: ignoring OAuth provider %s without %sCLIENT_ID\n", name, prefix)
+			continue
+		}
+
+		providers[name] = provider
+	}
+
+	return providers
+}
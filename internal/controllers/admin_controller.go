@@ -0,0 +1,403 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gwid.io/gwid-core/internal/middleware"
+	"gwid.io/gwid-core/internal/services"
+	"gwid.io/gwid-core/internal/types"
+)
+
+type AdminController struct {
+	adminService *services.AdminService
+	auditService *services.AuditService
+}
+
+func NewAdminController(adminService *services.AdminService, auditService *services.AuditService) *AdminController {
+	return &AdminController{
+		adminService: adminService,
+		auditService: auditService,
+	}
+}
+
+func (ac *AdminController) GetUsers(c *gin.Context) {
+	params, exists := middleware.GetQueryParams(c)
+	if !exists {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"success": false, "error": "failed to get query params"})
+		return
+	}
+
+	data, statusCode, err := ac.adminService.GetUsers(params)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	total, err := ac.adminService.GetUsersCount(params)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	metadata := &types.Metadata{
+		Total:  total,
+		Count:  len(*data),
+		Page:   params.Page,
+		Limit:  params.Limit,
+		Order:  params.Order,
+		Search: params.Search,
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success":  true,
+		"data":     types.NewAdminUserResList(*data),
+		"metadata": metadata,
+	})
+}
+
+func (ac *AdminController) GetUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid user ID",
+		})
+
+		return
+	}
+
+	user, statusCode, err := ac.adminService.GetUser(userID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    types.NewAdminUserRes(user),
+	})
+}
+
+func (ac *AdminController) SuspendUser(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	suspendUserReq := c.MustGet("validatedInput").(types.SuspendUserReq)
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid user ID",
+		})
+
+		return
+	}
+
+	user, statusCode, err := ac.adminService.SuspendUser(userID, suspendUserReq, reqUser.ID, sessionMeta(c))
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    types.NewAdminUserRes(user),
+	})
+}
+
+func (ac *AdminController) UnsuspendUser(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid user ID",
+		})
+
+		return
+	}
+
+	user, statusCode, err := ac.adminService.UnsuspendUser(userID, reqUser.ID, sessionMeta(c))
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    types.NewAdminUserRes(user),
+	})
+}
+
+func (ac *AdminController) UpdateUserRole(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	updateUserRoleReq := c.MustGet("validatedInput").(types.UpdateUserRoleReq)
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid user ID",
+		})
+
+		return
+	}
+
+	user, statusCode, err := ac.adminService.UpdateUserRole(userID, updateUserRoleReq, reqUser.ID, sessionMeta(c))
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    types.NewAdminUserRes(user),
+	})
+}
+
+func (ac *AdminController) GetGateways(c *gin.Context) {
+	params, exists := middleware.GetQueryParams(c)
+	if !exists {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"success": false, "error": "failed to get query params"})
+		return
+	}
+
+	data, statusCode, err := ac.adminService.GetGateways(params)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	total, err := ac.adminService.GetGatewaysCount(params)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	metadata := &types.Metadata{
+		Total:  total,
+		Count:  len(*data),
+		Page:   params.Page,
+		Limit:  params.Limit,
+		Order:  params.Order,
+		Search: params.Search,
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success":  true,
+		"data":     types.NewGatewayResList(*data),
+		"metadata": metadata,
+	})
+}
+
+func (ac *AdminController) ForceTerminateGateway(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	gatewayID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid gateway ID",
+		})
+
+		return
+	}
+
+	gateway, statusCode, err := ac.adminService.ForceTerminateGateway(gatewayID, reqUser.ID, sessionMeta(c))
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    types.NewGatewayRes(gateway),
+	})
+}
+
+func (ac *AdminController) GetQueueHealth(c *gin.Context) {
+	queueHealth, statusCode, err := ac.adminService.GetQueueHealth()
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    queueHealth,
+	})
+}
+
+func (ac *AdminController) GetUserLockout(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid user ID",
+		})
+
+		return
+	}
+
+	lockout, statusCode, err := ac.adminService.GetUserLockout(userID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    lockout,
+	})
+}
+
+func (ac *AdminController) ClearUserLockout(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid user ID",
+		})
+
+		return
+	}
+
+	statusCode, err := ac.adminService.ClearUserLockout(userID, reqUser.ID, sessionMeta(c))
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"message": "login lockout cleared",
+	})
+}
+
+func (ac *AdminController) GetAuditEvents(c *gin.Context) {
+	params, exists := middleware.GetQueryParams(c)
+	if !exists {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"success": false, "error": "failed to get query params"})
+		return
+	}
+
+	data, statusCode, err := ac.auditService.GetAuditEvents(params)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	total, err := ac.auditService.GetAuditEventsCount(params)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	metadata := &types.Metadata{
+		Total:  total,
+		Count:  len(*data),
+		Page:   params.Page,
+		Limit:  params.Limit,
+		Order:  params.Order,
+		Search: params.Search,
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success":  true,
+		"data":     types.NewAuditEventResList(*data),
+		"metadata": metadata,
+	})
+}
+
+// ExportAuditEvents streams the events matching the same filters as
+// GetAuditEvents as CSV. Once the first row is sent errors can only be logged.
+func (ac *AdminController) ExportAuditEvents(c *gin.Context) {
+	params, exists := middleware.GetQueryParams(c)
+	if !exists {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"success": false, "error": "failed to get query params"})
+		return
+	}
+
+	statusCode, err := ac.auditService.ValidateAuditQuery(params)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-events-%s.csv"`, time.Now().UTC().Format("20060102-150405")))
+	c.Status(http.StatusOK)
+
+	if err := ac.auditService.ExportAuditEvents(params, c.Writer); err != nil {
+		log.Printf("unable to export audit events: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gwid.io/gwid-core/internal/models"
+	"gwid.io/gwid-core/internal/services"
+	"gwid.io/gwid-core/internal/types"
+)
+
+type APIKeyController struct {
+	apiKeyService *services.APIKeyService
+	auditService  *services.AuditService
+}
+
+func NewAPIKeyController(apiKeyService *services.APIKeyService, auditService *services.AuditService) *APIKeyController {
+	return &APIKeyController{
+		apiKeyService: apiKeyService,
+		auditService:  auditService,
+	}
+}
+
+func (s *APIKeyController) CreateAPIKey(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	createAPIKeyReq := c.MustGet("validatedInput").(types.CreateAPIKeyReq)
+
+	apiKey, statusCode, err := s.apiKeyService.CreateAPIKey(createAPIKeyReq, reqUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	s.auditService.Record(reqUser.ID, models.AuditAPIKeyCreated, models.AuditTargetAPIKey, apiKey.ID.String(), map[string]any{
+		"name":   apiKey.Name,
+		"scopes": apiKey.Scopes,
+	}, sessionMeta(c))
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    apiKey,
+	})
+}
+
+func (s *APIKeyController) GetUserAPIKeys(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	apiKeys, statusCode, err := s.apiKeyService.GetUserAPIKeys(reqUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    apiKeys,
+	})
+}
+
+func (s *APIKeyController) DeleteAPIKey(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	apiKeyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid API key ID",
+		})
+
+		return
+	}
+
+	statusCode, err := s.apiKeyService.DeleteAPIKey(apiKeyID, reqUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	s.auditService.Record(reqUser.ID, models.AuditAPIKeyRevoked, models.AuditTargetAPIKey, apiKeyID.String(), nil, sessionMeta(c))
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+	})
+}
@@ -2,7 +2,10 @@
 package controllers
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"gwid.io/gwid-core/internal/models"
@@ -14,16 +17,47 @@ type AuthController struct {
 	authService           *services.AuthService
 	userService           *services.UserService
 	referralRewardService *services.ReferralRewardService
+	twoFactorService      *services.TwoFactorService
+	auditService          *services.AuditService
 }
 
-func NewAuthController(authService *services.AuthService, userService *services.UserService, referralRewardService *services.ReferralRewardService) *AuthController {
+func NewAuthController(
+	authService *services.AuthService,
+	userService *services.UserService,
+	referralRewardService *services.ReferralRewardService,
+	twoFactorService *services.TwoFactorService,
+	auditService *services.AuditService,
+) *AuthController {
 	return &AuthController{
 		authService:           authService,
 		userService:           userService,
 		referralRewardService: referralRewardService,
+		twoFactorService:      twoFactorService,
+		auditService:          auditService,
 	}
 }
 
+func sessionMeta(c *gin.Context) types.SessionMeta {
+	return types.SessionMeta{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+		RequestID: c.GetString("requestID"),
+	}
+}
+
+// isThrottled reports whether err asks the client to slow down, and sets the
+// Retry-After header if it does.
+func isThrottled(c *gin.Context, err error) bool {
+	var throttledErr *services.LoginThrottledError
+	if !errors.As(err, &throttledErr) {
+		return false
+	}
+
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttledErr.RetryAfter.Seconds()))))
+
+	return true
+}
+
 func (s *AuthController) SignUp(c *gin.Context) {
 	signupReq := c.MustGet("validatedInput").(types.SignupReq)
 
@@ -43,9 +77,15 @@ func (s *AuthController) SignUp(c *gin.Context) {
 		ReferralCode: referralCode,
 	}
 
-	authRes, err := s.authService.SignUp(&user)
+	authRes, err := s.authService.SignUp(&user, sessionMeta(c))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+		statusCode := http.StatusBadRequest
+
+		if isThrottled(c, err) {
+			statusCode = http.StatusTooManyRequests
+		}
+
+		c.AbortWithStatusJSON(statusCode, gin.H{
 			"success": false,
 			"error":   err.Error(),
 		})
@@ -66,9 +106,17 @@ func (s *AuthController) SignUp(c *gin.Context) {
 func (s *AuthController) Login(c *gin.Context) {
 	loginReq := c.MustGet("validatedInput").(types.LoginReq)
 
-	authRes, err := s.authService.Login(loginReq)
+	authRes, challengeRes, err := s.authService.Login(loginReq, sessionMeta(c))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+		statusCode := http.StatusBadRequest
+
+		if errors.Is(err, services.ErrAccountSuspended) || errors.Is(err, services.ErrAccountDeleted) {
+			statusCode = http.StatusForbidden
+		} else if isThrottled(c, err) {
+			statusCode = http.StatusTooManyRequests
+		}
+
+		c.AbortWithStatusJSON(statusCode, gin.H{
 			"success": false,
 			"error":   err.Error(),
 		})
@@ -76,6 +124,15 @@ func (s *AuthController) Login(c *gin.Context) {
 		return
 	}
 
+	if challengeRes != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    challengeRes,
+		})
+
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    authRes,
@@ -87,7 +144,7 @@ func (s *AuthController) ChangePassword(c *gin.Context) {
 
 	changePasswordReq := c.MustGet("validatedInput").(types.ChangePasswordReq)
 
-	statusCode, err := s.authService.ChangePassword(changePasswordReq, reqUser.ID)
+	statusCode, err := s.authService.ChangePassword(changePasswordReq, reqUser.ID, reqUser.SessionID, sessionMeta(c))
 	if err != nil {
 		c.AbortWithStatusJSON(statusCode, gin.H{
 			"success": false,
@@ -102,3 +159,235 @@ func (s *AuthController) ChangePassword(c *gin.Context) {
 		"message": "password changed successfully",
 	})
 }
+
+func (s *AuthController) Refresh(c *gin.Context) {
+	refreshTokenReq := c.MustGet("validatedInput").(types.RefreshTokenReq)
+
+	authRes, statusCode, err := s.authService.Refresh(refreshTokenReq, sessionMeta(c))
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    authRes,
+	})
+}
+
+func (s *AuthController) Logout(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	statusCode, err := s.authService.Logout(reqUser.SessionID, reqUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"message": "logged out successfully",
+	})
+}
+
+func (s *AuthController) LogoutAll(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	statusCode, err := s.authService.LogoutAll(reqUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"message": "logged out of all sessions",
+	})
+}
+
+func (s *AuthController) ForgotPassword(c *gin.Context) {
+	forgotPasswordReq := c.MustGet("validatedInput").(types.ForgotPasswordReq)
+
+	statusCode := s.authService.ForgotPassword(forgotPasswordReq)
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"message": "if an account exists for this email, a password reset link has been sent",
+	})
+}
+
+func (s *AuthController) ResetPassword(c *gin.Context) {
+	resetPasswordReq := c.MustGet("validatedInput").(types.ResetPasswordReq)
+
+	statusCode, err := s.authService.ResetPassword(resetPasswordReq, sessionMeta(c))
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"message": "password reset successfully",
+	})
+}
+
+func (s *AuthController) VerifyEmail(c *gin.Context) {
+	verifyEmailReq := c.MustGet("validatedInput").(types.VerifyEmailReq)
+
+	statusCode, err := s.authService.VerifyEmail(verifyEmailReq)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"message": "email verified successfully",
+	})
+}
+
+func (s *AuthController) ResendVerificationEmail(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	statusCode, err := s.authService.ResendVerificationEmail(reqUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"message": "verification email sent",
+	})
+}
+
+func (s *AuthController) VerifyTwoFactorLogin(c *gin.Context) {
+	twoFactorLoginReq := c.MustGet("validatedInput").(types.TwoFactorLoginReq)
+
+	authRes, statusCode, err := s.authService.VerifyTwoFactorLogin(twoFactorLoginReq, sessionMeta(c))
+	if err != nil {
+		isThrottled(c, err)
+
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    authRes,
+	})
+}
+
+func (s *AuthController) SetupTwoFactor(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	setupRes, statusCode, err := s.twoFactorService.SetupTwoFactor(reqUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    setupRes,
+	})
+}
+
+func (s *AuthController) ConfirmTwoFactor(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	twoFactorCodeReq := c.MustGet("validatedInput").(types.TwoFactorCodeReq)
+
+	recoveryCodesRes, statusCode, err := s.twoFactorService.ConfirmTwoFactor(twoFactorCodeReq, reqUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	s.auditService.Record(reqUser.ID, models.AuditAuthTwoFactorEnabled, models.AuditTargetUser, reqUser.ID.String(), nil, sessionMeta(c))
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    recoveryCodesRes,
+	})
+}
+
+func (s *AuthController) DisableTwoFactor(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	disableTwoFactorReq := c.MustGet("validatedInput").(types.DisableTwoFactorReq)
+
+	statusCode, err := s.twoFactorService.DisableTwoFactor(disableTwoFactorReq, reqUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	s.auditService.Record(reqUser.ID, models.AuditAuthTwoFactorDisabled, models.AuditTargetUser, reqUser.ID.String(), nil, sessionMeta(c))
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"message": "two-factor authentication disabled",
+	})
+}
+
+func (s *AuthController) RegenerateRecoveryCodes(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	twoFactorCodeReq := c.MustGet("validatedInput").(types.TwoFactorCodeReq)
+
+	recoveryCodesRes, statusCode, err := s.twoFactorService.RegenerateRecoveryCodes(twoFactorCodeReq, reqUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    recoveryCodesRes,
+	})
+}
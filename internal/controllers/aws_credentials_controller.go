@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"gwid.io/gwid-core/internal/middleware"
 	"gwid.io/gwid-core/internal/models"
 	"gwid.io/gwid-core/internal/services"
@@ -11,12 +12,20 @@ import (
 )
 
 type AWSCredentialsController struct {
-	awsCredentialsService *services.AWSCredentialsService
+	awsCredentialsService         *services.AWSCredentialsService
+	awsCredentialsDeletionService *services.AWSCredentialsDeletionService
+	auditService                  *services.AuditService
 }
 
-func NewAWSCredentialsController(awsCredentialsService *services.AWSCredentialsService) *AWSCredentialsController {
+func NewAWSCredentialsController(
+	awsCredentialsService *services.AWSCredentialsService,
+	awsCredentialsDeletionService *services.AWSCredentialsDeletionService,
+	auditService *services.AuditService,
+) *AWSCredentialsController {
 	return &AWSCredentialsController{
-		awsCredentialsService: awsCredentialsService,
+		awsCredentialsService:         awsCredentialsService,
+		awsCredentialsDeletionService: awsCredentialsDeletionService,
+		auditService:                  auditService,
 	}
 }
 
@@ -25,14 +34,24 @@ func (ac *AWSCredentialsController) CreateAWSCredentials(c *gin.Context) {
 
 	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
 
+	organization := c.MustGet("organization").(*types.OrganizationContext)
+
 	awsCredentials := models.AWSCredentials{
-		AccessKeyID:     awsCredentialsReq.AccessKeyID,
-		SecretAccessKey: awsCredentialsReq.SecretAccessKey,
-		UserID:          reqUser.ID,
-		RoleName:        "",
-		RoleARN:         "",
-		ProfieName:      "",
-		ProfileARN:      "",
+		Kind:           models.AWSCredentialsKind(awsCredentialsReq.Kind),
+		UserID:         reqUser.ID,
+		OrganizationID: organization.ID,
+		RoleName:       "",
+		RoleARN:        "",
+		ProfieName:     "",
+		ProfileARN:     "",
+	}
+
+	if awsCredentials.Kind == models.AWSCredentialsAssumeRole {
+		awsCredentials.AssumeRoleARN = &awsCredentialsReq.AssumeRoleARN
+	} else {
+		awsCredentials.Kind = models.AWSCredentialsAccessKey
+		awsCredentials.AccessKeyID = &awsCredentialsReq.AccessKeyID
+		awsCredentials.SecretAccessKey = awsCredentialsReq.SecretAccessKey
 	}
 
 	statusCode, err := ac.awsCredentialsService.CreateAWSCredentials(&awsCredentials)
@@ -45,16 +64,19 @@ func (ac *AWSCredentialsController) CreateAWSCredentials(c *gin.Context) {
 		return
 	}
 
-	awsCredentials.User = nil
+	ac.auditService.Record(reqUser.ID, models.AuditAWSCredentialsCreated, models.AuditTargetAWSCredentials, awsCredentials.ID.String(), map[string]any{
+		"kind":            awsCredentials.Kind,
+		"organization_id": organization.ID,
+	}, sessionMeta(c))
 
 	c.JSON(statusCode, gin.H{
 		"success": true,
-		"data":    awsCredentials,
+		"data":    types.NewAWSCredentialsRes(&awsCredentials),
 	})
 }
 
-func (ac *AWSCredentialsController) GetUserAWSCredentials(c *gin.Context) {
-	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+func (ac *AWSCredentialsController) GetOrganizationAWSCredentials(c *gin.Context) {
+	organization := c.MustGet("organization").(*types.OrganizationContext)
 
 	params, exists := middleware.GetQueryParams(c)
 	if !exists {
@@ -62,7 +84,7 @@ func (ac *AWSCredentialsController) GetUserAWSCredentials(c *gin.Context) {
 		return
 	}
 
-	data, statusCode, err := ac.awsCredentialsService.GetUserAWSCredentials(reqUser.ID, params)
+	data, statusCode, err := ac.awsCredentialsService.GetOrganizationAWSCredentials(organization.ID, params)
 	if err != nil {
 		c.AbortWithStatusJSON(statusCode, gin.H{
 			"success": false,
@@ -72,7 +94,7 @@ func (ac *AWSCredentialsController) GetUserAWSCredentials(c *gin.Context) {
 		return
 	}
 
-	total, err := ac.awsCredentialsService.GetUserCredentialsCount(reqUser.ID)
+	total, err := ac.awsCredentialsService.GetOrganizationCredentialsCount(organization.ID)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -93,7 +115,116 @@ func (ac *AWSCredentialsController) GetUserAWSCredentials(c *gin.Context) {
 
 	c.JSON(statusCode, gin.H{
 		"success":  true,
-		"data":     data,
+		"data":     types.NewAWSCredentialsResList(*data),
 		"metadata": metadata,
 	})
 }
+
+func (ac *AWSCredentialsController) GetAWSExternalID(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	data, statusCode, err := ac.awsCredentialsService.GetAWSExternalID(reqUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    data,
+	})
+}
+
+func (ac *AWSCredentialsController) UpdateAWSCredentials(c *gin.Context) {
+	updateReq := c.MustGet("validatedInput").(types.UpdateAWSCredentialsReq)
+
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	organization := c.MustGet("organization").(*types.OrganizationContext)
+
+	credentialsID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid credentials ID",
+		})
+
+		return
+	}
+
+	awsCredentials, statusCode, err := ac.awsCredentialsService.RotateAWSCredentials(credentialsID, organization.ID, updateReq)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	ac.auditService.Record(reqUser.ID, models.AuditAWSCredentialsRotated, models.AuditTargetAWSCredentials, awsCredentials.ID.String(), map[string]any{
+		"organization_id": organization.ID,
+	}, sessionMeta(c))
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    types.NewAWSCredentialsRes(awsCredentials),
+	})
+}
+
+func (ac *AWSCredentialsController) DeleteAWSCredentials(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	organization := c.MustGet("organization").(*types.OrganizationContext)
+
+	credentialsID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid credentials ID",
+		})
+
+		return
+	}
+
+	force := c.Query("force") == "true"
+
+	var statusCode int
+
+	if force {
+		statusCode, err = ac.awsCredentialsDeletionService.ForceDeleteAWSCredentials(credentialsID, organization.ID)
+	} else {
+		statusCode, err = ac.awsCredentialsService.DeleteAWSCredentials(credentialsID, organization.ID)
+	}
+
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	ac.auditService.Record(reqUser.ID, models.AuditAWSCredentialsDeleted, models.AuditTargetAWSCredentials, credentialsID.String(), map[string]any{
+		"force":           force,
+		"organization_id": organization.ID,
+	}, sessionMeta(c))
+
+	if statusCode == http.StatusAccepted {
+		c.JSON(statusCode, gin.H{
+			"success": true,
+			"message": "gateways using the credentials are being terminated, the credentials are deleted afterwards",
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+	})
+}
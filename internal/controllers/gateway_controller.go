@@ -4,27 +4,70 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"gwid.io/gwid-core/internal/middleware"
+	"gwid.io/gwid-core/internal/models"
 	"gwid.io/gwid-core/internal/services"
 	"gwid.io/gwid-core/internal/types"
 )
 
 type GatewayController struct {
 	gatewayService *services.GatewayService
+	auditService   *services.AuditService
 }
 
-func NewGatewayController(gatewayService *services.GatewayService) *GatewayController {
+func NewGatewayController(gatewayService *services.GatewayService, auditService *services.AuditService) *GatewayController {
 	return &GatewayController{
 		gatewayService: gatewayService,
+		auditService:   auditService,
 	}
 }
 
+func (gc *GatewayController) recordGatewayEvent(c *gin.Context, action string, gateway *models.Gateway) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	gc.auditService.Record(reqUser.ID, action, models.AuditTargetGateway, gateway.ID.String(), map[string]any{
+		"gateway_name":    gateway.GatewayName,
+		"organization_id": gateway.OrganizationID,
+		"provider":        gateway.Provider,
+		"region":          gateway.Region,
+	}, sessionMeta(c))
+}
+
+func (gc *GatewayController) CreateGateway(c *gin.Context) {
+	createGatewayReq := c.MustGet("validatedInput").(types.CreateGatewayReq)
+
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	organization := c.MustGet("organization").(*types.OrganizationContext)
+
+	gateway, statusCode, err := gc.gatewayService.CreateGateway(createGatewayReq, organization.ID, reqUser.ID)
+
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	gc.recordGatewayEvent(c, models.AuditGatewayCreated, gateway)
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    types.NewGatewayRes(gateway),
+	})
+}
+
 func (gc *GatewayController) CreateAWSGateway(c *gin.Context) {
 	createAWSGatewayReq := c.MustGet("validatedInput").(types.CreateGatewayWithAWSReq)
 
 	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
 
-	gateway, statusCode, err := gc.gatewayService.CreateGatewayWithAWS(createAWSGatewayReq, reqUser.ID)
+	organization := c.MustGet("organization").(*types.OrganizationContext)
+
+	gateway, statusCode, err := gc.gatewayService.CreateGatewayWithAWS(createAWSGatewayReq, organization.ID, reqUser.ID)
 
 	if err != nil {
 		c.AbortWithStatusJSON(statusCode, gin.H{
@@ -35,14 +78,63 @@ func (gc *GatewayController) CreateAWSGateway(c *gin.Context) {
 		return
 	}
 
+	gc.recordGatewayEvent(c, models.AuditGatewayCreated, gateway)
+
 	c.JSON(statusCode, gin.H{
 		"success": true,
-		"data":    gateway,
+		"data":    types.NewGatewayRes(gateway),
 	})
 }
 
-func (gc *GatewayController) GetUserGateways(c *gin.Context) {
-	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+func (gc *GatewayController) handleGatewayAction(c *gin.Context, action func(uuid.UUID, uuid.UUID) (*models.Gateway, int, error), auditAction string) {
+	organization := c.MustGet("organization").(*types.OrganizationContext)
+
+	gatewayID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid gateway ID",
+		})
+
+		return
+	}
+
+	gateway, statusCode, err := action(gatewayID, organization.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	gc.recordGatewayEvent(c, auditAction, gateway)
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    types.NewGatewayRes(gateway),
+	})
+}
+
+func (gc *GatewayController) DeleteGateway(c *gin.Context) {
+	gc.handleGatewayAction(c, gc.gatewayService.DeleteGateway, models.AuditGatewayDeleted)
+}
+
+func (gc *GatewayController) StopGateway(c *gin.Context) {
+	gc.handleGatewayAction(c, gc.gatewayService.StopGateway, models.AuditGatewayStopped)
+}
+
+func (gc *GatewayController) StartGateway(c *gin.Context) {
+	gc.handleGatewayAction(c, gc.gatewayService.StartGateway, models.AuditGatewayStarted)
+}
+
+func (gc *GatewayController) RebootGateway(c *gin.Context) {
+	gc.handleGatewayAction(c, gc.gatewayService.RebootGateway, models.AuditGatewayRebooted)
+}
+
+func (gc *GatewayController) GetOrganizationGateways(c *gin.Context) {
+	organization := c.MustGet("organization").(*types.OrganizationContext)
 
 	params, exists := middleware.GetQueryParams(c)
 	if !exists {
@@ -50,7 +142,7 @@ func (gc *GatewayController) GetUserGateways(c *gin.Context) {
 		return
 	}
 
-	data, statusCode, err := gc.gatewayService.GetUserGateways(reqUser.ID, params)
+	data, statusCode, err := gc.gatewayService.GetOrganizationGateways(organization.ID, params)
 	if err != nil {
 		c.AbortWithStatusJSON(statusCode, gin.H{
 			"success": false,
@@ -60,7 +152,7 @@ func (gc *GatewayController) GetUserGateways(c *gin.Context) {
 		return
 	}
 
-	total, err := gc.gatewayService.GetUserGatewaysCount(reqUser.ID)
+	total, err := gc.gatewayService.GetOrganizationGatewaysCount(organization.ID)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -81,7 +173,7 @@ func (gc *GatewayController) GetUserGateways(c *gin.Context) {
 
 	c.JSON(statusCode, gin.H{
 		"success":  true,
-		"data":     data,
+		"data":     types.NewGatewayResList(*data),
 		"metadata": metadata,
 	})
 }
@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gwid.io/gwid-core/internal/services"
+	"gwid.io/gwid-core/internal/types"
+)
+
+type OAuthController struct {
+	oauthService *services.OAuthService
+}
+
+func NewOAuthController(oauthService *services.OAuthService) *OAuthController {
+	return &OAuthController{
+		oauthService: oauthService,
+	}
+}
+
+func (s *OAuthController) GetProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    s.oauthService.GetProviders(),
+	})
+}
+
+func (s *OAuthController) Authorize(c *gin.Context) {
+	authURL, statusCode, err := s.oauthService.Authorize(c.Param("provider"))
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+func (s *OAuthController) Callback(c *gin.Context) {
+	oauthCallbackReq := c.MustGet("validatedInput").(types.OAuthCallbackReq)
+
+	authRes, challengeRes, statusCode, err := s.oauthService.Callback(c.Param("provider"), oauthCallbackReq, sessionMeta(c))
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	if challengeRes != nil {
+		c.JSON(statusCode, gin.H{
+			"success": true,
+			"data":    challengeRes,
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    authRes,
+	})
+}
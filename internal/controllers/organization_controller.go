@@ -0,0 +1,378 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gwid.io/gwid-core/internal/services"
+	"gwid.io/gwid-core/internal/types"
+)
+
+type OrganizationController struct {
+	organizationService *services.OrganizationService
+}
+
+func NewOrganizationController(organizationService *services.OrganizationService) *OrganizationController {
+	return &OrganizationController{
+		organizationService: organizationService,
+	}
+}
+
+func (oc *OrganizationController) CreateOrganization(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	createOrganizationReq := c.MustGet("validatedInput").(types.CreateOrganizationReq)
+
+	organization, statusCode, err := oc.organizationService.CreateOrganization(createOrganizationReq, reqUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    organization,
+	})
+}
+
+func (oc *OrganizationController) GetUserOrganizations(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	organizations, statusCode, err := oc.organizationService.GetUserOrganizations(reqUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    organizations,
+	})
+}
+
+func (oc *OrganizationController) GetOrganization(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	organizationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid organization ID",
+		})
+
+		return
+	}
+
+	organization, statusCode, err := oc.organizationService.GetOrganization(organizationID, reqUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    organization,
+	})
+}
+
+func (oc *OrganizationController) UpdateOrganization(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	updateOrganizationReq := c.MustGet("validatedInput").(types.UpdateOrganizationReq)
+
+	organizationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid organization ID",
+		})
+
+		return
+	}
+
+	organization, statusCode, err := oc.organizationService.UpdateOrganization(organizationID, reqUser.ID, updateOrganizationReq)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    organization,
+	})
+}
+
+func (oc *OrganizationController) DeleteOrganization(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	organizationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid organization ID",
+		})
+
+		return
+	}
+
+	statusCode, err := oc.organizationService.DeleteOrganization(organizationID, reqUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"message": "organization deleted",
+	})
+}
+
+func (oc *OrganizationController) GetOrganizationMembers(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	organizationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid organization ID",
+		})
+
+		return
+	}
+
+	members, statusCode, err := oc.organizationService.GetOrganizationMembers(organizationID, reqUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    members,
+	})
+}
+
+func (oc *OrganizationController) UpdateMemberRole(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	updateMemberRoleReq := c.MustGet("validatedInput").(types.UpdateMemberRoleReq)
+
+	organizationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid organization ID",
+		})
+
+		return
+	}
+
+	memberUserID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid user ID",
+		})
+
+		return
+	}
+
+	statusCode, err := oc.organizationService.UpdateMemberRole(organizationID, memberUserID, reqUser.ID, updateMemberRoleReq)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"message": "member role updated",
+	})
+}
+
+func (oc *OrganizationController) RemoveMember(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	organizationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid organization ID",
+		})
+
+		return
+	}
+
+	memberUserID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid user ID",
+		})
+
+		return
+	}
+
+	statusCode, err := oc.organizationService.RemoveMember(organizationID, memberUserID, reqUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"message": "member removed",
+	})
+}
+
+func (oc *OrganizationController) InviteMember(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	inviteMemberReq := c.MustGet("validatedInput").(types.InviteMemberReq)
+
+	organizationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid organization ID",
+		})
+
+		return
+	}
+
+	invitation, statusCode, err := oc.organizationService.InviteMember(organizationID, reqUser.ID, inviteMemberReq)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    invitation,
+	})
+}
+
+func (oc *OrganizationController) GetPendingInvitations(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	organizationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid organization ID",
+		})
+
+		return
+	}
+
+	invitations, statusCode, err := oc.organizationService.GetPendingInvitations(organizationID, reqUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    invitations,
+	})
+}
+
+func (oc *OrganizationController) RevokeInvitation(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	organizationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid organization ID",
+		})
+
+		return
+	}
+
+	invitationID, err := uuid.Parse(c.Param("invitationId"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid invitation ID",
+		})
+
+		return
+	}
+
+	statusCode, err := oc.organizationService.RevokeInvitation(organizationID, invitationID, reqUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"message": "invitation revoked",
+	})
+}
+
+func (oc *OrganizationController) AcceptInvitation(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	acceptInvitationReq := c.MustGet("validatedInput").(types.AcceptInvitationReq)
+
+	organization, statusCode, err := oc.organizationService.AcceptInvitation(acceptInvitationReq, reqUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    organization,
+	})
+}
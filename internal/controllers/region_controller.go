@@ -21,7 +21,7 @@ func NewRegionController(regionService *services.RegionService) *RegionControlle
 }
 
 func (s *RegionController) GetAWSRegions(c *gin.Context) {
-	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+	organization := c.MustGet("organization").(*types.OrganizationContext)
 
 	params, exists := middleware.GetQueryParams(c)
 	if !exists {
@@ -39,7 +39,7 @@ func (s *RegionController) GetAWSRegions(c *gin.Context) {
 		return
 	}
 
-	region, status, err := s.regionService.GetAWSRegions(reqUser.ID, credentialsID)
+	region, status, err := s.regionService.GetAWSRegions(organization.ID, credentialsID)
 	if err != nil {
 		c.AbortWithStatusJSON(status, gin.H{
 			"success": false,
@@ -4,17 +4,31 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gwid.io/gwid-core/internal/middleware"
+	"gwid.io/gwid-core/internal/models"
 	"gwid.io/gwid-core/internal/services"
 	"gwid.io/gwid-core/internal/types"
 )
 
 type UserController struct {
-	userService *services.UserService
+	userService            *services.UserService
+	sessionService         *services.SessionService
+	accountDeletionService *services.AccountDeletionService
+	auditService           *services.AuditService
 }
 
-func NewUserController(userService *services.UserService) *UserController {
+func NewUserController(
+	userService *services.UserService,
+	sessionService *services.SessionService,
+	accountDeletionService *services.AccountDeletionService,
+	auditService *services.AuditService,
+) *UserController {
 	return &UserController{
-		userService: userService,
+		userService:            userService,
+		sessionService:         sessionService,
+		accountDeletionService: accountDeletionService,
+		auditService:           auditService,
 	}
 }
 
@@ -33,7 +47,7 @@ func (s *UserController) GetCurrentUserProfile(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"data":    user,
+		"data":    types.NewUserRes(user),
 	})
 }
 
@@ -55,6 +69,150 @@ func (s *UserController) UpdateUserProfile(c *gin.Context) {
 
 	c.JSON(statusCode, gin.H{
 		"success": true,
-		"data":    user,
+		"data":    types.NewUserRes(user),
+	})
+}
+
+func (s *UserController) GetUserSessions(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	sessions, statusCode, err := s.sessionService.GetUserSessions(reqUser.ID, reqUser.SessionID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    sessions,
+	})
+}
+
+func (s *UserController) DeleteUserSession(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid session ID",
+		})
+
+		return
+	}
+
+	statusCode, err := s.sessionService.RevokeUserSession(sessionID, reqUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+	})
+}
+
+func (s *UserController) DeleteAccount(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	deleteAccountReq := c.MustGet("validatedInput").(types.DeleteAccountReq)
+
+	deletion, statusCode, err := s.accountDeletionService.RequestAccountDeletion(reqUser.ID, deleteAccountReq)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	s.auditService.Record(reqUser.ID, models.AuditAccountDeletionRequested, models.AuditTargetUser, reqUser.ID.String(), map[string]any{
+		"account_deletion_id": deletion.ID,
+	}, sessionMeta(c))
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    deletion,
+	})
+}
+
+func (s *UserController) GetAccountDeletion(c *gin.Context) {
+	deletionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid account deletion ID",
+		})
+
+		return
+	}
+
+	deletion, statusCode, err := s.accountDeletionService.GetAccountDeletion(deletionID)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": true,
+		"data":    deletion,
+	})
+}
+
+func (s *UserController) GetUserAuditEvents(c *gin.Context) {
+	reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+	params, exists := middleware.GetQueryParams(c)
+	if !exists {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"success": false, "error": "failed to get query params"})
+		return
+	}
+
+	data, statusCode, err := s.auditService.GetUserAuditEvents(reqUser.ID, params)
+	if err != nil {
+		c.AbortWithStatusJSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	total, err := s.auditService.GetUserAuditEventsCount(reqUser.ID, params)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+
+		return
+	}
+
+	metadata := &types.Metadata{
+		Total:  total,
+		Count:  len(*data),
+		Page:   params.Page,
+		Limit:  params.Limit,
+		Order:  params.Order,
+		Search: params.Search,
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success":  true,
+		"data":     types.NewAuditEventResList(*data),
+		"metadata": metadata,
 	})
 }
@@ -8,10 +8,9 @@ import (
 	"gwid.io/gwid-core/internal/config"
 	"gwid.io/gwid-core/internal/models"
 	"gwid.io/gwid-core/internal/repositories"
+	"gwid.io/gwid-core/internal/services"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	awsConfig "github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 )
@@ -42,17 +41,9 @@ func (s *EC2Cron) SyncEC2Instances() {
 		return
 	}
 
-	accessID := s.config.AwsAccessID
-	secretAccessKey := s.config.AwsSecretAccessKey
-
 	ctx := context.TODO()
 
-	creds := credentials.NewStaticCredentialsProvider(accessID, secretAccessKey, "")
-
-	cfg, err := awsConfig.LoadDefaultConfig(context.TODO(),
-		awsConfig.WithCredentialsProvider(creds),
-		awsConfig.WithRegion("eu-central-1"),
-	)
+	cfg, err := services.LoadPlatformAWSConfig(ctx, s.config, "eu-central-1")
 	if err != nil {
 		log.Fatalf("failed to load AWS config: %v", err)
 	}
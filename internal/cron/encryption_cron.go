@@ -0,0 +1,141 @@
+package cron
+
+import (
+	"log"
+
+	"github.com/google/uuid"
+	"gwid.io/gwid-core/internal/repositories"
+	"gwid.io/gwid-core/internal/services"
+)
+
+const reEncryptBatchSize = 100
+
+type EncryptionCron struct {
+	awsCredentialsRepository *repositories.AWSCredentialsRepository
+	userRepository           *repositories.UserRepository
+	encryptionService        services.EncryptionService
+}
+
+func NewEncryptionCron(
+	awsCredentialsRepository *repositories.AWSCredentialsRepository,
+	userRepository *repositories.UserRepository,
+	encryptionService services.EncryptionService,
+) *EncryptionCron {
+	return &EncryptionCron{
+		awsCredentialsRepository: awsCredentialsRepository,
+		userRepository:           userRepository,
+		encryptionService:        encryptionService,
+	}
+}
+
+// ReEncryptSecrets moves every stored secret to the active encryption key, so
+// that retired keys can be removed from the keyring once it has run.
+func (s *EncryptionCron) ReEncryptSecrets() {
+	activeKeyID := s.encryptionService.ActiveKeyID()
+	if activeKeyID == "" {
+		return
+	}
+
+	reEncrypted, failed := s.reEncryptAWSCredentials(activeKeyID)
+
+	totpReEncrypted, totpFailed := s.reEncryptTOTPSecrets(activeKeyID)
+
+	reEncrypted += totpReEncrypted
+	failed += totpFailed
+
+	if reEncrypted > 0 || failed > 0 {
+		log.Printf("Re-encrypted %d secrets with key %s, %d failed", reEncrypted, activeKeyID, failed)
+	}
+}
+
+func (s *EncryptionCron) reEncryptAWSCredentials(activeKeyID string) (reEncrypted int, failed int) {
+	afterID := uuid.Nil
+
+	for {
+		credentials, err := s.awsCredentialsRepository.GetCredentialsNotEncryptedWith(activeKeyID, afterID, reEncryptBatchSize)
+		if err != nil {
+			log.Println(err)
+
+			return reEncrypted, failed
+		}
+
+		for _, credential := range *credentials {
+			afterID = credential.ID
+
+			previous := credential.SecretAccessKey
+
+			secretAccessKey, err := s.encryptionService.ReEncryptData(previous)
+			if err != nil {
+				log.Printf("unable to re-encrypt credentials %s: %v", credential.ID, err)
+				failed++
+
+				continue
+			}
+
+			credential.SecretAccessKey = secretAccessKey
+
+			// A concurrent key rotation already wrote a secret under the
+			// active key, so a miss needs no retry.
+			if _, err := s.awsCredentialsRepository.ReplaceSecretAccessKey(&credential, previous); err != nil {
+				log.Printf("unable to save credentials %s: %v", credential.ID, err)
+				failed++
+
+				continue
+			}
+
+			reEncrypted++
+		}
+
+		if len(*credentials) < reEncryptBatchSize {
+			break
+		}
+	}
+
+	return reEncrypted, failed
+}
+
+func (s *EncryptionCron) reEncryptTOTPSecrets(activeKeyID string) (reEncrypted int, failed int) {
+	afterID := uuid.Nil
+
+	for {
+		users, err := s.userRepository.GetUsersWithTOTPNotEncryptedWith(activeKeyID, afterID, reEncryptBatchSize)
+		if err != nil {
+			log.Println(err)
+
+			return reEncrypted, failed
+		}
+
+		for _, user := range *users {
+			afterID = user.ID
+
+			previous := *user.TOTPSecret
+
+			totpSecret, err := s.encryptionService.ReEncryptData(previous)
+			if err != nil {
+				log.Printf("unable to re-encrypt TOTP secret of user %s: %v", user.ID, err)
+				failed++
+
+				continue
+			}
+
+			user.TOTPSecret = &totpSecret
+
+			// The user set up 2FA again in the meantime, which already
+			// encrypted the new secret with the active key.
+			if _, err := s.userRepository.ReplaceTOTPSecret(&user, previous); err != nil {
+				log.Printf("unable to save TOTP secret of user %s: %v", user.ID, err)
+				failed++
+
+				continue
+			}
+
+			reEncrypted++
+		}
+
+		if len(*users) < reEncryptBatchSize {
+			break
+		}
+	}
+
+	return reEncrypted, failed
+}
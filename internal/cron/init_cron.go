@@ -5,12 +5,16 @@ import (
 )
 
 type CronService struct {
-	ec2Cron *EC2Cron
+	ec2Cron        *EC2Cron
+	encryptionCron *EncryptionCron
+	oauthCron      *OAuthCron
 }
 
-func NewCronService(ec2Cron *EC2Cron) *CronService {
+func NewCronService(ec2Cron *EC2Cron, encryptionCron *EncryptionCron, oauthCron *OAuthCron) *CronService {
 	return &CronService{
-		ec2Cron: ec2Cron,
+		ec2Cron:        ec2Cron,
+		encryptionCron: encryptionCron,
+		oauthCron:      oauthCron,
 	}
 }
 
@@ -18,6 +22,10 @@ func (s *CronService) StartScheduler() *cron.Cron {
 	c := cron.New(cron.WithSeconds())
 
 	c.AddFunc("@daily", s.ec2Cron.SyncEC2Instances)
+	c.AddFunc("@hourly", s.encryptionCron.ReEncryptSecrets)
+	c.AddFunc("@hourly", s.oauthCron.DeleteExpiredOAuthStates)
+
+	go s.encryptionCron.ReEncryptSecrets()
 
 	c.Start()
 
@@ -0,0 +1,30 @@
+package cron
+
+import (
+	"log"
+
+	"gwid.io/gwid-core/internal/services"
+)
+
+type OAuthCron struct {
+	oauthService *services.OAuthService
+}
+
+func NewOAuthCron(oauthService *services.OAuthService) *OAuthCron {
+	return &OAuthCron{
+		oauthService: oauthService,
+	}
+}
+
+func (s *OAuthCron) DeleteExpiredOAuthStates() {
+	deleted, err := s.oauthService.DeleteExpiredOAuthStates()
+	if err != nil {
+		log.Println(err)
+
+		return
+	}
+
+	if deleted > 0 {
+		log.Printf("Deleted %d expired OAuth states", deleted)
+	}
+}
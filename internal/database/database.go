@@ -2,6 +2,7 @@
 package database
 
 import (
+	"fmt"
 	"log"
 
 	"gorm.io/driver/postgres"
@@ -25,19 +26,107 @@ func NewDatabase(cfg *config.Config) *gorm.DB {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	err = db.AutoMigrate(
+	if err := Migrate(db); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	log.Println("Database connected and migrated successfully")
+
+	return db
+}
+
+// Migrate brings the schema up to date and protects the audit log.
+func Migrate(db *gorm.DB) error {
+	err := db.AutoMigrate(
 		&models.User{},
 		&models.Gateway{},
 		&models.AWSCredentials{},
 		&models.AWSRegion{},
 		&models.EC2{},
 		&models.ReferralReward{},
+		&models.Session{},
+		&models.RefreshToken{},
+		&models.UserToken{},
+		&models.RecoveryCode{},
+		&models.OAuthState{},
+		&models.UserIdentity{},
+		&models.APIKey{},
+		&models.Organization{},
+		&models.OrganizationMember{},
+		&models.OrganizationInvitation{},
+		&models.AuditEvent{},
+		&models.AccountDeletion{},
 	)
 	if err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+		return err
 	}
 
-	log.Println("Database connected and migrated successfully")
+	if err := backfillOrganizations(db); err != nil {
+		return fmt.Errorf("backfill organizations: %w", err)
+	}
 
-	return db
+	if err := protectAuditEvents(db); err != nil {
+		return fmt.Errorf("protect audit events: %w", err)
+	}
+
+	return nil
+}
+
+// backfillOrganizations gives users created before organizations existed their
+// personal organization and moves their gateways and credentials into it. It
+// does nothing once every row has an organization.
+func backfillOrganizations(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		statements := []string{
+			`INSERT INTO organizations (id, name, personal_owner_id, created_at, updated_at)
+			SELECT gen_random_uuid(), 'Personal', users.id, now(), now() FROM users
+			WHERE NOT EXISTS (SELECT 1 FROM organizations WHERE organizations.personal_owner_id = users.id)`,
+			`INSERT INTO organization_members (id, organization_id, user_id, role, created_at, updated_at)
+			SELECT gen_random_uuid(), organizations.id, organizations.personal_owner_id, 'owner', now(), now() FROM organizations
+			WHERE organizations.personal_owner_id IS NOT NULL AND NOT EXISTS (
+				SELECT 1 FROM organization_members
+				WHERE organization_members.organization_id = organizations.id AND organization_members.user_id = organizations.personal_owner_id
+			)`,
+			`UPDATE gateways SET organization_id = organizations.id FROM organizations
+			WHERE gateways.organization_id IS NULL AND organizations.personal_owner_id = gateways.user_id`,
+			`UPDATE aws_credentials SET organization_id = organizations.id FROM organizations
+			WHERE aws_credentials.organization_id IS NULL AND organizations.personal_owner_id = aws_credentials.user_id`,
+		}
+
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// protectAuditEvents makes audit_events append-only for every client of the
+// database, not only for this application.
+func protectAuditEvents(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		statements := []string{
+			`CREATE OR REPLACE FUNCTION reject_audit_event_change() RETURNS trigger AS $$
+			BEGIN
+				RAISE EXCEPTION 'audit events cannot be changed or deleted';
+			END;
+			$$ LANGUAGE plpgsql`,
+			`DROP TRIGGER IF EXISTS audit_events_immutable ON audit_events`,
+			`CREATE TRIGGER audit_events_immutable BEFORE UPDATE OR DELETE ON audit_events
+			FOR EACH ROW EXECUTE FUNCTION reject_audit_event_change()`,
+			`DROP TRIGGER IF EXISTS audit_events_no_truncate ON audit_events`,
+			`CREATE TRIGGER audit_events_no_truncate BEFORE TRUNCATE ON audit_events
+			FOR EACH STATEMENT EXECUTE FUNCTION reject_audit_event_change()`,
+		}
+
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 }
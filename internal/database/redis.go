@@ -0,0 +1,26 @@
+package database
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/fx"
+	"gwid.io/gwid-core/internal/config"
+)
+
+// NewRedis connects to the Redis instance that also backs the task queue. It
+// holds state that every replica has to see, such as login throttling.
+func NewRedis(lc fx.Lifecycle, cfg *config.Config) *redis.Client {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddress,
+		Password: cfg.RedisPassword,
+	})
+
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			return client.Close()
+		},
+	})
+
+	return client
+}
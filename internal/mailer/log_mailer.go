@@ -0,0 +1,47 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"gwid.io/gwid-core/internal/config"
+)
+
+// LogMailer prints messages instead of delivering them, and appends them to
+// MAIL_LOG_FILE when set so that tests can read the links they contain.
+type LogMailer struct {
+	config config.MailConfig
+	mu     sync.Mutex
+}
+
+func NewLogMailer(cfg config.MailConfig) *LogMailer {
+	return &LogMailer{
+		config: cfg,
+	}
+}
+
+func (m *LogMailer) Send(message Message) error {
+	formatted := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", message.To, message.Subject, message.Body)
+
+	log.Printf("mail not delivered (log mailer):\n%s", formatted)
+
+	if m.config.LogFile == "" {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, err := os.OpenFile(m.config.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	_, err = file.WriteString(formatted + "----\n")
+
+	return err
+}
@@ -0,0 +1,55 @@
+// Package mailer renders transactional emails from embedded templates and
+// delivers them over SMTP or, in development, to the log
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"text/template"
+
+	"gwid.io/gwid-core/internal/config"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var templates = template.Must(template.ParseFS(templateFS, "templates/*.tmpl"))
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(message Message) error
+}
+
+func NewMailer(cfg *config.Config) Mailer {
+	if cfg.Mail.Driver == "smtp" {
+		return NewSMTPMailer(cfg.Mail)
+	}
+
+	return NewLogMailer(cfg.Mail)
+}
+
+// Render builds a message from templates/<name>.tmpl, which defines a
+// "<name>.subject" and a "<name>.body" template.
+func Render(name string, to string, data any) (Message, error) {
+	var subject, body bytes.Buffer
+
+	if err := templates.ExecuteTemplate(&subject, name+".subject", data); err != nil {
+		return Message{}, fmt.Errorf("unable to render %s subject: %w", name, err)
+	}
+
+	if err := templates.ExecuteTemplate(&body, name+".body", data); err != nil {
+		return Message{}, fmt.Errorf("unable to render %s body: %w", name, err)
+	}
+
+	return Message{
+		To:      to,
+		Subject: subject.String(),
+		Body:    body.String(),
+	}, nil
+}
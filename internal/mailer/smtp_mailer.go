@@ -0,0 +1,50 @@
+package mailer
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+
+	"gwid.io/gwid-core/internal/config"
+)
+
+type SMTPMailer struct {
+	config config.MailConfig
+}
+
+func NewSMTPMailer(cfg config.MailConfig) *SMTPMailer {
+	return &SMTPMailer{
+		config: cfg,
+	}
+}
+
+func (m *SMTPMailer) Send(message Message) error {
+	address := net.JoinHostPort(m.config.SMTPHost, m.config.SMTPPort)
+
+	var auth smtp.Auth
+	if m.config.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", m.config.SMTPUsername, m.config.SMTPPassword, m.config.SMTPHost)
+	}
+
+	var body strings.Builder
+
+	fmt.Fprintf(&body, "From: %s\r\n", m.config.From)
+	fmt.Fprintf(&body, "To: %s\r\n", message.To)
+	fmt.Fprintf(&body, "Subject: %s\r\n", message.Subject)
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n\r\n")
+	body.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+
+	from, err := mail.ParseAddress(m.config.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %w", err)
+	}
+
+	if err := smtp.SendMail(address, auth, from.Address, []string{message.To}, []byte(body.String())); err != nil {
+		return fmt.Errorf("unable to send mail: %w", err)
+	}
+
+	return nil
+}
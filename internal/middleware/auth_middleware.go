@@ -7,11 +7,26 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"gwid.io/gwid-core/internal/config"
+	"gwid.io/gwid-core/internal/models"
 	"gwid.io/gwid-core/internal/types"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// SessionValidator reports an error if the session an access token was issued
+// for has been revoked or has expired.
+type SessionValidator interface {
+	ValidateSession(sessionID uuid.UUID, userID uuid.UUID) error
+}
+
+// APIKeyValidator returns the claims and scopes of a valid API key.
+type APIKeyValidator interface {
+	ValidateAPIKey(key string) (*types.JwtCustomClaims, []string, error)
+}
+
+// AuthMiddleware accepts access tokens and API keys. Requests with an API key
+// are limited by RequireScope and SessionOnlyMiddleware.
+func AuthMiddleware(sessionValidator SessionValidator, apiKeyValidator APIKeyValidator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		bearerToken := c.GetHeader("Authorization")
 
@@ -26,6 +41,25 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		tokenString := extractToken(bearerToken)
 
+		if strings.HasPrefix(tokenString, models.APIKeyPrefix) {
+			claims, scopes, err := apiKeyValidator.ValidateAPIKey(tokenString)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+					"success": false,
+					"error":   "unauthorized",
+				})
+
+				return
+			}
+
+			c.Set("user", claims)
+			c.Set("apiKeyScopes", scopes)
+
+			c.Next()
+
+			return
+		}
+
 		token, err := jwt.ParseWithClaims(tokenString, &types.JwtCustomClaims{}, func(token *jwt.Token) (interface{}, error) {
 			return []byte(config.GetEnv("JWT_SECRET", "the-fallback-key")), nil
 		})
@@ -39,7 +73,7 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		if claims, ok := token.Claims.(*types.JwtCustomClaims); ok {
+		if claims, ok := token.Claims.(*types.JwtCustomClaims); ok && sessionValidator.ValidateSession(claims.SessionID, claims.ID) == nil {
 			c.Set("user", claims)
 		} else {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
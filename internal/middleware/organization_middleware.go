@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gwid.io/gwid-core/internal/models"
+	"gwid.io/gwid-core/internal/types"
+)
+
+// OrganizationResolver returns the organization a user acts in. A nil
+// organization ID selects the personal organization of the user.
+type OrganizationResolver interface {
+	ResolveOrganization(userID uuid.UUID, organizationID uuid.UUID) (*types.OrganizationContext, int, error)
+}
+
+// OrganizationMiddleware selects the active organization from the
+// X-Organization-ID header, falling back to the personal organization. It has
+// to run after AuthMiddleware.
+func OrganizationMiddleware(resolver OrganizationResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+		organizationID := uuid.Nil
+
+		if header := c.GetHeader("X-Organization-ID"); header != "" {
+			parsedID, err := uuid.Parse(header)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+					"success": false,
+					"error":   "invalid organization ID",
+				})
+
+				return
+			}
+
+			organizationID = parsedID
+		}
+
+		organization, statusCode, err := resolver.ResolveOrganization(reqUser.ID, organizationID)
+		if err != nil {
+			c.AbortWithStatusJSON(statusCode, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+
+			return
+		}
+
+		c.Set("organization", organization)
+
+		c.Next()
+	}
+}
+
+// RequireOrgRole limits a route to members of the active organization with at
+// least the given role. It has to run after OrganizationMiddleware.
+func RequireOrgRole(role models.OrganizationRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		organization := c.MustGet("organization").(*types.OrganizationContext)
+
+		if !organization.Role.AtLeast(role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "this action requires the " + string(role) + " role",
+			})
+
+			return
+		}
+
+		c.Next()
+	}
+}
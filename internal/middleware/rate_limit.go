@@ -1,44 +1,142 @@
 package middleware
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
-	"sync"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/time/rate"
+	"github.com/redis/go-redis/v9"
+	"gwid.io/gwid-core/internal/types"
 )
 
-var (
-	visitors = make(map[string]*rate.Limiter)
-	mu       sync.Mutex
-)
+// RateLimitPolicy allows Limit requests per Period. Requests may come all at
+// once, after which the budget refills evenly over the period.
+type RateLimitPolicy struct {
+	// Name separates the budgets of policies in Redis.
+	Name   string
+	Limit  int
+	Period time.Duration
+}
+
+// gcraScript implements the generic cell rate algorithm. The key holds the
+// theoretical arrival time (TAT) of the next request in milliseconds; a
+// request is allowed when it does not push the TAT more than one period past
+// now. It returns whether the request is allowed, the requests left, and the
+// milliseconds until the next request is allowed and until the budget is full.
+var gcraScript = redis.NewScript(`
+local emission = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local tat = tonumber(redis.call("GET", KEYS[1]))
+if not tat or tat < now then
+	tat = now
+end
+
+local new_tat = tat + emission
+if new_tat - now > period then
+	return {0, 0, new_tat - period - now, tat - now}
+end
+
+redis.call("SET", KEYS[1], new_tat, "PX", new_tat - now)
+
+return {1, math.floor((period - (new_tat - now)) / emission), 0, new_tat - now}
+`)
+
+type rateLimitResult struct {
+	allowed    bool
+	remaining  int64
+	retryAfter time.Duration
+	resetAfter time.Duration
+}
 
-func getVisitor(ip string) *rate.Limiter {
-	mu.Lock()
-	defer mu.Unlock()
+func takeRateLimit(ctx context.Context, client *redis.Client, key string, policy RateLimitPolicy) (*rateLimitResult, error) {
+	emission := policy.Period.Milliseconds() / int64(policy.Limit)
 
-	limiter, exists := visitors[ip]
-	if !exists {
-		limiter = rate.NewLimiter(1, 5)
-		visitors[ip] = limiter
+	values, err := gcraScript.Run(ctx, client, []string{key}, emission, policy.Period.Milliseconds()).Int64Slice()
+	if err != nil {
+		return nil, err
 	}
 
-	return limiter
+	return &rateLimitResult{
+		allowed:    values[0] == 1,
+		remaining:  values[1],
+		retryAfter: time.Duration(values[2]) * time.Millisecond,
+		resetAfter: time.Duration(values[3]) * time.Millisecond,
+	}, nil
 }
 
-func RateLimitMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		limiter := getVisitor(ip)
-
-		if !limiter.Allow() {
-			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
-				"success":       false,
-				"error_message": "Too many requests",
-			})
-			return
+// rateLimitSubject identifies who a request counts against: the user once
+// AuthMiddleware ran, the client address otherwise.
+func rateLimitSubject(c *gin.Context) string {
+	if user, exists := c.Get("user"); exists {
+		if claims, ok := user.(*types.JwtCustomClaims); ok {
+			return "user:" + claims.ID.String()
 		}
+	}
+
+	return "ip:" + c.ClientIP()
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+func rateLimit(c *gin.Context, client *redis.Client, policy RateLimitPolicy) {
+	key := "rate-limit:" + policy.Name + ":" + rateLimitSubject(c)
 
+	result, err := takeRateLimit(c.Request.Context(), client, key, policy)
+	if err != nil {
+		// Rather serve requests unlimited than fail them all while Redis is
+		// unavailable.
+		log.Printf("unable to apply rate limit %s: %v", policy.Name, err)
 		c.Next()
+
+		return
+	}
+
+	c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%s", policy.Limit, seconds(policy.Period)))
+	c.Header("RateLimit-Limit", strconv.Itoa(policy.Limit))
+	c.Header("RateLimit-Remaining", strconv.FormatInt(result.remaining, 10))
+	c.Header("RateLimit-Reset", seconds(result.resetAfter))
+
+	if !result.allowed {
+		c.Header("Retry-After", seconds(result.retryAfter))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+			"success":       false,
+			"error_message": "Too many requests",
+		})
+
+		return
+	}
+
+	c.Next()
+}
+
+// RateLimitMiddleware limits requests with a budget kept in Redis, so that it
+// holds across replicas. Placed after AuthMiddleware it counts per user,
+// otherwise per client address.
+func RateLimitMiddleware(client *redis.Client, policy RateLimitPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rateLimit(c, client, policy)
+	}
+}
+
+// ReadWriteRateLimitMiddleware is RateLimitMiddleware with a separate budget
+// for requests that only read.
+func ReadWriteRateLimitMiddleware(client *redis.Client, read RateLimitPolicy, write RateLimitPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			rateLimit(c, client, read)
+		default:
+			rateLimit(c, client, write)
+		}
 	}
 }
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gwid.io/gwid-core/internal/models"
+	"gwid.io/gwid-core/internal/types"
+)
+
+// RoleChecker returns the current role of a user.
+type RoleChecker interface {
+	GetUserRole(userID uuid.UUID) (models.UserRole, error)
+}
+
+// RequireRole limits a route to users with the given role. The role is read
+// from the database rather than the access token, so that a demotion takes
+// effect immediately. It has to run after AuthMiddleware.
+func RequireRole(checker RoleChecker, role models.UserRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+		userRole, err := checker.GetUserRole(reqUser.ID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "something went wrong",
+			})
+
+			return
+		}
+
+		if userRole != role {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "forbidden",
+			})
+
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireScope limits a route to API keys with the given scope. Requests with
+// an access token act with the full rights of the user and pass.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, isAPIKey := GetAPIKeyScopes(c)
+
+		if isAPIKey && !slices.Contains(scopes, scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "API key is missing the " + scope + " scope",
+			})
+
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// SessionOnlyMiddleware keeps API keys away from routes that manage the
+// account itself, such as sessions, passwords and other API keys.
+func SessionOnlyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, isAPIKey := GetAPIKeyScopes(c); isAPIKey {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "this endpoint cannot be used with an API key",
+			})
+
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// GetAPIKeyScopes returns the scopes of the API key the request was
+// authenticated with, and false for access tokens.
+func GetAPIKeyScopes(c *gin.Context) ([]string, bool) {
+	value, exists := c.Get("apiKeyScopes")
+	if !exists {
+		return nil, false
+	}
+
+	scopes, ok := value.([]string)
+
+	return scopes, ok
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gwid.io/gwid-core/internal/types"
+)
+
+// EmailVerificationChecker reports whether a user has verified their email
+// address.
+type EmailVerificationChecker interface {
+	IsEmailVerified(userID uuid.UUID) (bool, error)
+}
+
+// VerifiedEmailMiddleware guards actions that incur cost. It has to run after
+// AuthMiddleware.
+func VerifiedEmailMiddleware(checker EmailVerificationChecker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reqUser := c.MustGet("user").(*types.JwtCustomClaims)
+
+		verified, err := checker.IsEmailVerified(reqUser.ID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "something went wrong",
+			})
+
+			return
+		}
+
+		if !verified {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "email address is not verified",
+			})
+
+			return
+		}
+
+		c.Next()
+	}
+}
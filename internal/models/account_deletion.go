@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type AccountDeletionStatus string
+
+const (
+	AccountDeletionPending             AccountDeletionStatus = "pending"
+	AccountDeletionTerminatingGateways AccountDeletionStatus = "terminating_gateways"
+	AccountDeletionRemovingCredentials AccountDeletionStatus = "removing_credentials"
+	AccountDeletionCompleted           AccountDeletionStatus = "completed"
+	AccountDeletionFailed              AccountDeletionStatus = "failed"
+)
+
+// AccountDeletion tracks the background teardown of a user account. Its ID is
+// handed to the user to poll the status, since they are signed out once the
+// deletion starts.
+type AccountDeletion struct {
+	ID          uuid.UUID             `json:"id" gorm:"type:uuid;primary_key;"`
+	UserID      uuid.UUID             `json:"-" gorm:"type:uuid;not null;index"`
+	Status      AccountDeletionStatus `json:"status" gorm:"not null"`
+	Error       string                `json:"error"`
+	CompletedAt *time.Time            `json:"completed_at"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (deletion *AccountDeletion) BeforeCreate(tx *gorm.DB) (err error) {
+	deletion.ID = uuid.New()
+
+	return nil
+}
+
+func (deletion *AccountDeletion) IsFinished() bool {
+	return deletion.Status == AccountDeletionCompleted || deletion.Status == AccountDeletionFailed
+}
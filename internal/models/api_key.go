@@ -0,0 +1,58 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// APIKeyPrefix starts every API key, so that AuthMiddleware can tell them
+// apart from JWTs and secret scanners can find leaked keys.
+const APIKeyPrefix = "gwid_"
+
+const (
+	ScopeGatewaysRead     = "gateways:read"
+	ScopeGatewaysWrite    = "gateways:write"
+	ScopeCredentialsRead  = "credentials:read"
+	ScopeCredentialsWrite = "credentials:write"
+)
+
+var APIKeyScopes = []string{
+	ScopeGatewaysRead,
+	ScopeGatewaysWrite,
+	ScopeCredentialsRead,
+	ScopeCredentialsWrite,
+}
+
+// APIKey lets automation act as a user within its scopes. Only the SHA-256
+// hash of the key is stored; DisplayPrefix is kept to tell keys apart.
+type APIKey struct {
+	ID            uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;"`
+	UserID        uuid.UUID  `json:"user_id" gorm:"index;not null"`
+	Name          string     `json:"name" gorm:"not null"`
+	DisplayPrefix string     `json:"display_prefix" gorm:"not null"`
+	KeyHash       string     `json:"-" gorm:"uniqueIndex;not null"`
+	Scopes        []string   `json:"scopes" gorm:"serializer:json;not null"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+	LastUsedAt    *time.Time `json:"last_used_at"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+
+	User *User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+func (apiKey *APIKey) BeforeCreate(tx *gorm.DB) (err error) {
+	apiKey.ID = uuid.New()
+
+	return nil
+}
+
+func (apiKey *APIKey) IsExpired() bool {
+	return apiKey.ExpiresAt != nil && time.Now().After(*apiKey.ExpiresAt)
+}
+
+func (apiKey *APIKey) HasScope(scope string) bool {
+	return slices.Contains(apiKey.Scopes, scope)
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	AuditTargetUser           = "user"
+	AuditTargetGateway        = "gateway"
+	AuditTargetAWSCredentials = "aws_credentials"
+	AuditTargetAPIKey         = "api_key"
+)
+
+const (
+	AuditAuthLogin           = "auth.login"
+	AuditAuthLoginFailed     = "auth.login_failed"
+	AuditAuthAccountLocked   = "auth.account_locked"
+	AuditAuthPasswordChanged = "auth.password_changed"
+	AuditAuthPasswordReset   = "auth.password_reset"
+
+	AuditAuthTwoFactorEnabled  = "auth.two_factor_enabled"
+	AuditAuthTwoFactorDisabled = "auth.two_factor_disabled"
+
+	AuditAccountDeletionRequested = "account.deletion_requested"
+
+	AuditAPIKeyCreated = "api_key.created"
+	AuditAPIKeyRevoked = "api_key.revoked"
+
+	AuditGatewayCreated  = "gateway.created"
+	AuditGatewayDeleted  = "gateway.deleted"
+	AuditGatewayStopped  = "gateway.stopped"
+	AuditGatewayStarted  = "gateway.started"
+	AuditGatewayRebooted = "gateway.rebooted"
+
+	AuditAWSCredentialsCreated = "aws_credentials.created"
+	AuditAWSCredentialsRotated = "aws_credentials.rotated"
+	AuditAWSCredentialsDeleted = "aws_credentials.deleted"
+
+	AuditAdminUserSuspended     = "admin.user.suspended"
+	AuditAdminUserUnsuspended   = "admin.user.unsuspended"
+	AuditAdminUserRoleChanged   = "admin.user.role_changed"
+	AuditAdminUserUnlocked      = "admin.user.unlocked"
+	AuditAdminGatewayTerminated = "admin.gateway.terminated"
+)
+
+var ErrAuditEventImmutable = errors.New("audit events cannot be changed or deleted")
+
+// AuditEvent records who did what to which resource. It has no foreign keys so
+// that events outlive the users and resources they mention, and it can only be
+// inserted: the hooks below and a database trigger reject updates and deletes.
+type AuditEvent struct {
+	ID         uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;"`
+	ActorID    *uuid.UUID     `json:"actor_id" gorm:"type:uuid;index"`
+	Action     string         `json:"action" gorm:"not null;index"`
+	TargetType string         `json:"target_type" gorm:"index:idx_audit_events_target"`
+	TargetID   string         `json:"target_id" gorm:"index:idx_audit_events_target"`
+	Metadata   map[string]any `json:"metadata" gorm:"serializer:json"`
+	IPAddress  string         `json:"ip_address"`
+	UserAgent  string         `json:"user_agent"`
+	RequestID  string         `json:"request_id" gorm:"index"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+func (event *AuditEvent) BeforeCreate(tx *gorm.DB) (err error) {
+	event.ID = uuid.New()
+
+	return nil
+}
+
+func (event *AuditEvent) BeforeUpdate(tx *gorm.DB) (err error) {
+	return ErrAuditEventImmutable
+}
+
+func (event *AuditEvent) BeforeDelete(tx *gorm.DB) (err error) {
+	return ErrAuditEventImmutable
+}
@@ -7,21 +7,44 @@ import (
 	"gorm.io/gorm"
 )
 
+type AWSCredentialsKind string
+
+const (
+	// AWSCredentialsAccessKey credentials hold a long-lived access key pair.
+	AWSCredentialsAccessKey AWSCredentialsKind = "access_key"
+	// AWSCredentialsAssumeRole credentials hold the ARN of a role in the user's
+	// account that trusts the platform account with the user's external ID.
+	AWSCredentialsAssumeRole AWSCredentialsKind = "assume_role"
+)
+
 type AWSCredentials struct {
-	ID              uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
-	AccessKeyID     string    `json:"access_key_id" gorm:"not null;uniqueIndex"`
-	SecretAccessKey string    `json:"secret_access_key" gorm:"not null"`
-	RoleName        string    `json:"role_name" gorm:"not null"`
-	RoleARN         string    `json:"role_arn" gorm:"not null"`
-	ProfieName      string    `json:"profie_name" gorm:"not null"`
-	ProfileARN      string    `json:"profile_arn" gorm:"not null"`
+	ID              uuid.UUID          `json:"id" gorm:"type:uuid;primary_key;"`
+	Kind            AWSCredentialsKind `json:"kind" gorm:"default:'access_key';not null"`
+	AccessKeyID     *string            `json:"access_key_id" gorm:"uniqueIndex"`
+	SecretAccessKey string             `json:"-"`
+	AssumeRoleARN   *string            `json:"assume_role_arn"`
+	ExternalID      *string            `json:"-"`
+	RoleName        string             `json:"role_name" gorm:"not null"`
+	RoleARN         string             `json:"role_arn" gorm:"not null"`
+	ProfieName      string             `json:"profie_name" gorm:"not null"`
+	ProfileARN      string             `json:"profile_arn" gorm:"not null"`
 
 	UserID uuid.UUID `json:"user_id" gorm:"index"`
+	// OrganizationID owns the credentials, UserID is the member who added them.
+	OrganizationID uuid.UUID `json:"organization_id" gorm:"type:uuid;index"`
+	// DeletionRequestedAt is set while the gateways of the credentials are
+	// terminated ahead of a forced deletion. No new gateways can use them.
+	DeletionRequestedAt *time.Time `json:"deletion_requested_at"`
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 
-	User *User `json:"user" gorm:"foreignKey.UserID"`
+	User         *User         `json:"user" gorm:"foreignKey.UserID"`
+	Organization *Organization `json:"-" gorm:"foreignKey:OrganizationID"`
+}
+
+func (awsCredentials *AWSCredentials) IsDeletionRequested() bool {
+	return awsCredentials.DeletionRequestedAt != nil
 }
 
 func (awsCredentials *AWSCredentials) BeforeCreate(db *gorm.DB) (err error) {
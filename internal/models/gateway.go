@@ -2,6 +2,8 @@ package models
 
 import (
 	"errors"
+	"fmt"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,15 +11,61 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	ProviderAWS = "aws"
+)
+
 type GatewayStatus string
 
 const (
 	GatewayInitializing GatewayStatus = "initializing"
+	GatewayProvisioning GatewayStatus = "provisioning"
+	GatewayDeploying    GatewayStatus = "deploying"
 	GatewayRunning      GatewayStatus = "running"
 	GatewayStopped      GatewayStatus = "stopped"
 	GatewayFailed       GatewayStatus = "failed"
+	GatewayTerminating  GatewayStatus = "terminating"
+	GatewayStopping     GatewayStatus = "stopping"
+	GatewayStarting     GatewayStatus = "starting"
+	GatewayRebooting    GatewayStatus = "rebooting"
+)
+
+var (
+	ErrInvalidGatewayTransition = errors.New("invalid gateway status transition")
+	ErrGatewayStatusChanged     = errors.New("gateway status was changed by another operation")
 )
 
+// gatewayTransitions lists every status a gateway may move to from a given
+// status. Any change of Gateway.Status has to go through TransitionTo.
+var gatewayTransitions = map[GatewayStatus][]GatewayStatus{
+	// The instance has been requested and the deploy task is waiting for it.
+	GatewayInitializing: {GatewayProvisioning, GatewayFailed, GatewayTerminating},
+	// The instance is running and the deploy task is waiting for SSM.
+	GatewayProvisioning: {GatewayDeploying, GatewayFailed, GatewayTerminating},
+	// The deploy script is running. A retried deploy task starts over from the
+	// instance checks and moves the gateway back to provisioning.
+	GatewayDeploying: {GatewayRunning, GatewayProvisioning, GatewayFailed, GatewayTerminating},
+	GatewayRunning:   {GatewayStopping, GatewayRebooting, GatewayTerminating},
+	// In-progress actions fall back to the status they started from when the
+	// task could not be queued or failed, as the instance is still there.
+	GatewayStopping:  {GatewayStopped, GatewayRunning, GatewayTerminating},
+	GatewayStopped:   {GatewayStarting, GatewayTerminating},
+	GatewayStarting:  {GatewayRunning, GatewayStopped, GatewayTerminating},
+	GatewayRebooting: {GatewayRunning, GatewayTerminating},
+	// A gateway whose deployment or termination failed can only be terminated.
+	GatewayFailed:      {GatewayTerminating},
+	GatewayTerminating: {GatewayFailed},
+}
+
+func (status GatewayStatus) CanTransitionTo(next GatewayStatus) bool {
+	return slices.Contains(gatewayTransitions[status], next)
+}
+
+// IsSettled reports whether no task is working on a gateway in this status.
+func (status GatewayStatus) IsSettled() bool {
+	return status == GatewayRunning || status == GatewayStopped || status == GatewayFailed
+}
+
 type Gateway struct {
 	ID                 uuid.UUID     `json:"id" gorm:"type:uuid;primary_key;"`
 	Provider           string        `json:"provider" gorm:"not null"`
@@ -31,13 +79,18 @@ type Gateway struct {
 	ErrorStatus        string        `json:"error_status"`
 	QueueID            *string       `json:"queue_id"`
 	InstanceID         *string       `json:"instance_id"`
+	PublicIP           *string       `json:"public_ip"`
+	Subdomain          *string       `json:"subdomain"`
+	DNSZoneID          *string       `json:"dns_zone_id"`
 	UserID             uuid.UUID     `json:"user_id" gorm:"index"`
+	OrganizationID     uuid.UUID     `json:"organization_id" gorm:"type:uuid;index"`
 	AWSCredentialsID   uuid.UUID     `json:"aws_credentials_id" gorm:"index"`
 
 	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 
 	User           *User           `json:"user" gorm:"foreignKey.UserID"`
+	Organization   *Organization   `json:"-" gorm:"foreignKey:OrganizationID"`
 	AWSCredentials *AWSCredentials `json:"aws_credentials" gorm:"foreignKey.AWSCredentialsID"`
 }
 
@@ -51,6 +104,16 @@ func (gateway *Gateway) BeforeCreate(tx *gorm.DB) (err error) {
 	return nil
 }
 
+func (gateway *Gateway) TransitionTo(next GatewayStatus) error {
+	if !gateway.Status.CanTransitionTo(next) {
+		return fmt.Errorf("%w: cannot move gateway from %s to %s", ErrInvalidGatewayTransition, gateway.Status, next)
+	}
+
+	gateway.Status = next
+
+	return nil
+}
+
 func (gateway *Gateway) HashPassword(password string) error {
 	hashedPasswordbytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OAuthState remembers a login that was sent to a provider until its
+// callback. Only the hash of the state is stored; the PKCE code verifier and
+// nonce are useless without it.
+type OAuthState struct {
+	ID           uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;"`
+	Provider     string     `json:"provider" gorm:"not null"`
+	StateHash    string     `json:"-" gorm:"uniqueIndex;not null"`
+	Nonce        string     `json:"-" gorm:"not null"`
+	CodeVerifier string     `json:"-" gorm:"not null"`
+	RedirectURI  string     `json:"redirect_uri" gorm:"not null"`
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt       *time.Time `json:"used_at"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (oauthState *OAuthState) BeforeCreate(tx *gorm.DB) (err error) {
+	oauthState.ID = uuid.New()
+
+	return nil
+}
+
+func (oauthState *OAuthState) IsUsable() bool {
+	return oauthState.UsedAt == nil && time.Now().Before(oauthState.ExpiresAt)
+}
+
+// UserIdentity links a user to their account at an OAuth provider.
+type UserIdentity struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;"`
+	UserID      uuid.UUID  `json:"user_id" gorm:"index;not null"`
+	Provider    string     `json:"provider" gorm:"not null;uniqueIndex:idx_user_identities_provider_subject"`
+	Subject     string     `json:"-" gorm:"not null;uniqueIndex:idx_user_identities_provider_subject"`
+	Email       string     `json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (userIdentity *UserIdentity) BeforeCreate(tx *gorm.DB) (err error) {
+	userIdentity.ID = uuid.New()
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type OrganizationRole string
+
+// Organization roles, from most to least privileged.
+const (
+	OrgRoleOwner  OrganizationRole = "owner"
+	OrgRoleAdmin  OrganizationRole = "admin"
+	OrgRoleMember OrganizationRole = "member"
+	OrgRoleViewer OrganizationRole = "viewer"
+)
+
+var organizationRoleRanks = []OrganizationRole{
+	OrgRoleViewer,
+	OrgRoleMember,
+	OrgRoleAdmin,
+	OrgRoleOwner,
+}
+
+var ErrInvalidOrganizationRole = errors.New("invalid organization role")
+
+func (role OrganizationRole) IsValid() bool {
+	return slices.Contains(organizationRoleRanks, role)
+}
+
+// AtLeast reports whether the role has all rights of the other role.
+func (role OrganizationRole) AtLeast(other OrganizationRole) bool {
+	return slices.Index(organizationRoleRanks, role) >= slices.Index(organizationRoleRanks, other)
+}
+
+// Organization owns gateways and AWS credentials. Every user has a personal
+// organization, identified by PersonalOwnerID, that cannot be shared.
+type Organization struct {
+	ID              uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;"`
+	Name            string     `json:"name" gorm:"not null"`
+	PersonalOwnerID *uuid.UUID `json:"-" gorm:"type:uuid;uniqueIndex"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Members        []OrganizationMember     `json:"-" gorm:"foreignKey:OrganizationID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Invitations    []OrganizationInvitation `json:"-" gorm:"foreignKey:OrganizationID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Gateways       []Gateway                `json:"-" gorm:"foreignKey:OrganizationID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+	AWSCredentials []AWSCredentials         `json:"-" gorm:"foreignKey:OrganizationID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+}
+
+func (organization *Organization) BeforeCreate(tx *gorm.DB) (err error) {
+	organization.ID = uuid.New()
+
+	return nil
+}
+
+func (organization *Organization) IsPersonal() bool {
+	return organization.PersonalOwnerID != nil
+}
+
+type OrganizationMember struct {
+	ID             uuid.UUID        `json:"id" gorm:"type:uuid;primary_key;"`
+	OrganizationID uuid.UUID        `json:"organization_id" gorm:"type:uuid;not null;uniqueIndex:idx_organization_members_organization_user"`
+	UserID         uuid.UUID        `json:"user_id" gorm:"type:uuid;not null;index;uniqueIndex:idx_organization_members_organization_user"`
+	Role           OrganizationRole `json:"role" gorm:"not null"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+
+	Organization *Organization `json:"-" gorm:"foreignKey:OrganizationID"`
+	User         *User         `json:"-" gorm:"foreignKey:UserID"`
+}
+
+func (member *OrganizationMember) BeforeCreate(tx *gorm.DB) (err error) {
+	member.ID = uuid.New()
+
+	if !member.Role.IsValid() {
+		return ErrInvalidOrganizationRole
+	}
+
+	return nil
+}
+
+// OrganizationInvitation is accepted by the user with the invited email
+// address through a link containing the token. Only its SHA-256 hash is
+// stored.
+type OrganizationInvitation struct {
+	ID             uuid.UUID        `json:"id" gorm:"type:uuid;primary_key;"`
+	OrganizationID uuid.UUID        `json:"organization_id" gorm:"type:uuid;not null;index"`
+	Email          string           `json:"email" gorm:"not null"`
+	Role           OrganizationRole `json:"role" gorm:"not null"`
+	TokenHash      string           `json:"-" gorm:"uniqueIndex;not null"`
+	InvitedByID    uuid.UUID        `json:"invited_by_id" gorm:"type:uuid;not null"`
+	ExpiresAt      time.Time        `json:"expires_at" gorm:"not null"`
+	AcceptedAt     *time.Time       `json:"accepted_at"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+
+	Organization *Organization `json:"-" gorm:"foreignKey:OrganizationID"`
+}
+
+func (invitation *OrganizationInvitation) BeforeCreate(tx *gorm.DB) (err error) {
+	invitation.ID = uuid.New()
+
+	if !invitation.Role.IsValid() {
+		return ErrInvalidOrganizationRole
+	}
+
+	return nil
+}
+
+func (invitation *OrganizationInvitation) IsPending() bool {
+	return invitation.AcceptedAt == nil && time.Now().Before(invitation.ExpiresAt)
+}
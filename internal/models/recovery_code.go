@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RecoveryCode is a single-use code that replaces a TOTP code when the
+// authenticator is lost. Only its SHA-256 hash is stored.
+type RecoveryCode struct {
+	ID       uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;"`
+	UserID   uuid.UUID  `json:"user_id" gorm:"index;not null"`
+	CodeHash string     `json:"-" gorm:"not null"`
+	UsedAt   *time.Time `json:"used_at"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (recoveryCode *RecoveryCode) BeforeCreate(tx *gorm.DB) (err error) {
+	recoveryCode.ID = uuid.New()
+
+	return nil
+}
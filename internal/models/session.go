@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Session is a login on one device. All refresh tokens issued for a login
+// belong to the same session, so revoking it ends the whole token family.
+type Session struct {
+	ID            uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;"`
+	UserID        uuid.UUID  `json:"user_id" gorm:"index;not null"`
+	UserAgent     string     `json:"user_agent"`
+	IPAddress     string     `json:"ip_address"`
+	LastUsedAt    time.Time  `json:"last_used_at"`
+	ExpiresAt     time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt     *time.Time `json:"revoked_at"`
+	RevokedReason string     `json:"revoked_reason"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+
+	RefreshTokens []RefreshToken `json:"-" gorm:"foreignKey:SessionID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+func (session *Session) BeforeCreate(tx *gorm.DB) (err error) {
+	session.ID = uuid.New()
+
+	return nil
+}
+
+func (session *Session) IsActive() bool {
+	return session.RevokedAt == nil && time.Now().Before(session.ExpiresAt)
+}
+
+// RefreshToken is stored as a SHA-256 hash. A token can be used once; using
+// it again means it was stolen and revokes its session.
+type RefreshToken struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;"`
+	SessionID uuid.UUID  `json:"session_id" gorm:"index;not null"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+
+	Session *Session `json:"-" gorm:"foreignKey:SessionID"`
+}
+
+func (refreshToken *RefreshToken) BeforeCreate(tx *gorm.DB) (err error) {
+	refreshToken.ID = uuid.New()
+
+	return nil
+}
@@ -25,19 +25,47 @@ var Roles = []UserRole{
 }
 
 type User struct {
-	ID           uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
-	Name         string    `json:"name" gorm:"not null"`
-	Email        string    `json:"email" gorm:"not null;uniqueIndex"`
-	Password     string    `json:"-" gorm:"not null"`
-	Role         UserRole  `json:"role" gorm:"default:'regular'"`
-	ReferralCode string    `json:"referral_code" gorm:"uniqueIndex"`
+	ID            uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
+	Name          string    `json:"name" gorm:"not null"`
+	Email         string    `json:"email" gorm:"not null;uniqueIndex"`
+	Password      string    `json:"-" gorm:"not null"`
+	Role          UserRole  `json:"role" gorm:"default:'regular'"`
+	ReferralCode  string    `json:"referral_code" gorm:"uniqueIndex"`
+	AWSExternalID *string   `json:"-" gorm:"uniqueIndex"`
+
+	// PasswordUnset marks users who signed up through an OAuth provider and
+	// only have a random password they never saw, until they reset it.
+	PasswordUnset bool `json:"-" gorm:"not null;default:false"`
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+
+	// TOTPSecret is encrypted with EncryptionService. It is set at 2FA setup
+	// and only takes effect once TOTPEnabledAt is set by confirming a code.
+	TOTPSecret    *string    `json:"-"`
+	TOTPEnabledAt *time.Time `json:"-"`
+	// TOTPLastStep is the time step of the last accepted code, so that a code
+	// cannot be used twice.
+	TOTPLastStep int64 `json:"-" gorm:"not null;default:0"`
+
+	// SuspendedAt is set by an admin to lock the user out of their account.
+	SuspendedAt      *time.Time `json:"suspended_at"`
+	SuspensionReason string     `json:"-"`
+	// DeletionRequestedAt locks the user out while AccountDeletion tears the
+	// account down. The row is anonymized rather than deleted afterwards.
+	DeletionRequestedAt *time.Time `json:"-"`
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 
-	Gateways        []Gateway        `json:"gateways" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
-	AWSCredentials  []AWSCredentials `json:"aws_credentials" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
-	ReferralRewards []ReferralReward `json:"referral_rewards" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Gateways        []Gateway            `json:"gateways" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	AWSCredentials  []AWSCredentials     `json:"aws_credentials" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	ReferralRewards []ReferralReward     `json:"referral_rewards" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Sessions        []Session            `json:"-" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	UserTokens      []UserToken          `json:"-" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	RecoveryCodes   []RecoveryCode       `json:"-" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Identities      []UserIdentity       `json:"-" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	APIKeys         []APIKey             `json:"-" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Memberships     []OrganizationMember `json:"-" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
 }
 
 func (role *UserRole) Scan(value interface{}) error {
@@ -78,6 +106,26 @@ func (user *User) BeforeCreate(db *gorm.DB) (err error) {
 	return nil
 }
 
+// AfterCreate gives every new user a personal organization to own their
+// gateways and credentials. UserRepository.CreateUser runs it in the same
+// transaction.
+func (user *User) AfterCreate(tx *gorm.DB) (err error) {
+	organization := Organization{
+		Name:            "Personal",
+		PersonalOwnerID: &user.ID,
+	}
+
+	if err := tx.Create(&organization).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(&OrganizationMember{
+		OrganizationID: organization.ID,
+		UserID:         user.ID,
+		Role:           OrgRoleOwner,
+	}).Error
+}
+
 func (user *User) HashPassword(password string) error {
 	hashedPasswordbytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -93,6 +141,11 @@ func (user *User) CheckPassword(password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 }
 
+// HasPassword reports whether the user knows their password.
+func (user *User) HasPassword() bool {
+	return !user.PasswordUnset
+}
+
 func (user *User) HasRole(role UserRole) bool {
 	return user.Role == role
 }
@@ -100,3 +153,19 @@ func (user *User) HasRole(role UserRole) bool {
 func (user *User) IsAdmin() bool {
 	return user.Role == Admin
 }
+
+func (user *User) IsEmailVerified() bool {
+	return user.EmailVerifiedAt != nil
+}
+
+func (user *User) IsTwoFactorEnabled() bool {
+	return user.TOTPEnabledAt != nil
+}
+
+func (user *User) IsSuspended() bool {
+	return user.SuspendedAt != nil
+}
+
+func (user *User) IsDeletionRequested() bool {
+	return user.DeletionRequestedAt != nil
+}
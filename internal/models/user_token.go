@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type UserTokenPurpose string
+
+const (
+	UserTokenPasswordReset     UserTokenPurpose = "password_reset"
+	UserTokenEmailVerification UserTokenPurpose = "email_verification"
+)
+
+// UserToken is a single-use token sent to a user by email. Only its SHA-256
+// hash is stored.
+type UserToken struct {
+	ID        uuid.UUID        `json:"id" gorm:"type:uuid;primary_key;"`
+	UserID    uuid.UUID        `json:"user_id" gorm:"index;not null"`
+	Purpose   UserTokenPurpose `json:"purpose" gorm:"not null"`
+	TokenHash string           `json:"-" gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time        `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time       `json:"used_at"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (userToken *UserToken) BeforeCreate(tx *gorm.DB) (err error) {
+	userToken.ID = uuid.New()
+
+	return nil
+}
+
+func (userToken *UserToken) IsUsable() bool {
+	return userToken.UsedAt == nil && time.Now().Before(userToken.ExpiresAt)
+}
@@ -0,0 +1,122 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"gwid.io/gwid-core/internal/config"
+)
+
+const (
+	githubAuthorizeURL = "https://github.com/login/oauth/authorize"
+	githubTokenURL     = "https://github.com/login/oauth/access_token"
+	githubAPIURL       = "https://api.github.com"
+)
+
+// GitHubProvider signs in with a GitHub OAuth app. GitHub does not issue ID
+// tokens, so the identity is read from its API instead. Setting ISSUER points
+// the provider at a GitHub Enterprise Server.
+type GitHubProvider struct {
+	config       config.OAuthProviderConfig
+	httpClient   *http.Client
+	authorizeURL string
+	tokenURL     string
+	apiURL       string
+}
+
+func NewGitHubProvider(providerConfig config.OAuthProviderConfig, httpClient *http.Client) *GitHubProvider {
+	provider := &GitHubProvider{
+		config:       providerConfig,
+		httpClient:   httpClient,
+		authorizeURL: githubAuthorizeURL,
+		tokenURL:     githubTokenURL,
+		apiURL:       githubAPIURL,
+	}
+
+	if providerConfig.Issuer != "" {
+		provider.authorizeURL = providerConfig.Issuer + "/login/oauth/authorize"
+		provider.tokenURL = providerConfig.Issuer + "/login/oauth/access_token"
+		provider.apiURL = providerConfig.Issuer + "/api/v3"
+	}
+
+	return provider
+}
+
+func (p *GitHubProvider) Name() string {
+	return p.config.Name
+}
+
+func (p *GitHubProvider) AuthCodeURL(ctx context.Context, authRequest AuthRequest) (string, error) {
+	query := url.Values{}
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", authRequest.RedirectURI)
+	query.Set("scope", strings.Join(p.config.Scopes, " "))
+	query.Set("state", authRequest.State)
+	query.Set("code_challenge", CodeChallenge(authRequest.CodeVerifier))
+	query.Set("code_challenge_method", "S256")
+	query.Set("allow_signup", "false")
+
+	return p.authorizeURL + "?" + query.Encode(), nil
+}
+
+func (p *GitHubProvider) Exchange(ctx context.Context, code string, authRequest AuthRequest) (*Identity, error) {
+	form := url.Values{}
+	form.Set("code", code)
+	form.Set("redirect_uri", authRequest.RedirectURI)
+	form.Set("client_id", p.config.ClientID)
+	form.Set("client_secret", p.config.ClientSecret)
+	form.Set("code_verifier", authRequest.CodeVerifier)
+
+	tokenRes, err := exchangeCode(ctx, p.httpClient, p.tokenURL, form)
+	if err != nil {
+		return nil, err
+	}
+
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+
+	if err := getJSON(ctx, p.httpClient, p.apiURL+"/user", tokenRes.AccessToken, &user); err != nil {
+		return nil, err
+	}
+
+	if user.ID == 0 {
+		return nil, errors.New("github user has no id")
+	}
+
+	identity := &Identity{
+		Subject: strconv.FormatInt(user.ID, 10),
+		Name:    user.Name,
+	}
+
+	if identity.Name == "" {
+		identity.Name = user.Login
+	}
+
+	// The public email of the profile may be unverified, only the primary
+	// address from the emails endpoint is used.
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+
+	if err := getJSON(ctx, p.httpClient, p.apiURL+"/user/emails", tokenRes.AccessToken, &emails); err != nil {
+		return nil, err
+	}
+
+	for _, email := range emails {
+		if email.Primary {
+			identity.Email = strings.ToLower(email.Email)
+			identity.EmailVerified = email.Verified
+		}
+	}
+
+	return identity, nil
+}
@@ -0,0 +1,107 @@
+// Package oauth implements the authorization code flow with PKCE for social
+// and SSO login providers
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gwid.io/gwid-core/internal/config"
+)
+
+var ErrProviderNotFound = errors.New("oauth provider not found")
+
+// Identity is the user as reported by a provider. Subject is stable for the
+// provider, the email address may change.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// AuthRequest holds the per-login values that are sent to the provider and
+// checked again on the callback.
+type AuthRequest struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+	RedirectURI  string
+}
+
+type Provider interface {
+	Name() string
+	AuthCodeURL(ctx context.Context, authRequest AuthRequest) (string, error)
+	// Exchange redeems the authorization code and returns the verified
+	// identity of the user.
+	Exchange(ctx context.Context, code string, authRequest AuthRequest) (*Identity, error)
+}
+
+type Registry struct {
+	providers map[string]Provider
+}
+
+func NewRegistry(cfg *config.Config) *Registry {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+
+	registry := &Registry{
+		providers: make(map[string]Provider),
+	}
+
+	for name, providerConfig := range cfg.OAuthProviders {
+		switch providerConfig.Kind {
+		case "oidc":
+			registry.providers[name] = NewOIDCProvider(providerConfig, httpClient)
+		case "github":
+			registry.providers[name] = NewGitHubProvider(providerConfig, httpClient)
+		default:
+			fmt.Printf("Warning: ignoring OAuth provider %s of unknown kind %q\n", name, providerConfig.Kind)
+		}
+	}
+
+	return registry
+}
+
+func (r *Registry) Get(name string) (Provider, error) {
+	provider, ok := r.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrProviderNotFound, name)
+	}
+
+	return provider, nil
+}
+
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+
+	for name := range r.providers {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// RandomString returns n random bytes encoded as base64url, which is valid for
+// state, nonce and PKCE code verifiers.
+func RandomString(n int) (string, error) {
+	bytes := make([]byte, n)
+
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// CodeChallenge derives the S256 PKCE code challenge of a code verifier.
+func CodeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
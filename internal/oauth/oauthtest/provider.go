@@ -0,0 +1,235 @@
+// Package oauthtest runs an OpenID Connect provider in process for tests of
+// the login flow
+package oauthtest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	ClientID     = "gwid-test-client"
+	ClientSecret = "gwid-test-secret"
+	KeyID        = "test-key"
+)
+
+// User is the account that signs in at the provider.
+type User struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type authorization struct {
+	user          User
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+}
+
+// Provider serves discovery, the JWKS and the token endpoint. The
+// authorization endpoint is not served: Authorize plays the user consenting in
+// the browser instead.
+type Provider struct {
+	*httptest.Server
+
+	// IDTokenClaims, when set, can change the claims of the next id_tokens
+	// before they are signed.
+	IDTokenClaims func(claims jwt.MapClaims)
+	// SignIDToken, when set, replaces signing the id_token with the provider
+	// key.
+	SignIDToken func(claims jwt.MapClaims) (string, error)
+
+	key *rsa.PrivateKey
+
+	mu             sync.Mutex
+	authorizations map[string]authorization
+}
+
+func NewProvider() (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := &Provider{
+		key:            key,
+		authorizations: make(map[string]authorization),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", provider.handleDiscovery)
+	mux.HandleFunc("GET /jwks", provider.handleJWKS)
+	mux.HandleFunc("POST /token", provider.handleToken)
+
+	provider.Server = httptest.NewServer(mux)
+
+	return provider, nil
+}
+
+// Issuer is the issuer URL to configure the provider with.
+func (p *Provider) Issuer() string {
+	return p.URL
+}
+
+// PublicKey is the key the id_tokens are signed with.
+func (p *Provider) PublicKey() *rsa.PublicKey {
+	return &p.key.PublicKey
+}
+
+// Authorize checks the authorization request in authURL the way the provider
+// would and returns the code and state it redirects back with.
+func (p *Provider) Authorize(authURL string, user User) (string, string, error) {
+	parsedURL, err := url.Parse(authURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	query := parsedURL.Query()
+
+	switch {
+	case parsedURL.Scheme+"://"+parsedURL.Host+parsedURL.Path != p.URL+"/authorize":
+		return "", "", fmt.Errorf("unexpected authorization endpoint %s", parsedURL.Path)
+	case query.Get("response_type") != "code":
+		return "", "", errors.New("unsupported response_type")
+	case query.Get("client_id") != ClientID:
+		return "", "", errors.New("unknown client_id")
+	case query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "":
+		return "", "", errors.New("missing S256 code_challenge")
+	case query.Get("state") == "" || query.Get("nonce") == "":
+		return "", "", errors.New("missing state or nonce")
+	}
+
+	code := rand.Text()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.authorizations[code] = authorization{
+		user:          user,
+		redirectURI:   query.Get("redirect_uri"),
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+	}
+
+	return code, query.Get("state"), nil
+}
+
+func (p *Provider) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                 p.URL,
+		"authorization_endpoint": p.URL + "/authorize",
+		"token_endpoint":         p.URL + "/token",
+		"jwks_uri":               p.URL + "/jwks",
+	})
+}
+
+func (p *Provider) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": KeyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (p *Provider) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeTokenError(w, "invalid_request")
+		return
+	}
+
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		writeTokenError(w, "unsupported_grant_type")
+		return
+	}
+
+	if r.PostForm.Get("client_id") != ClientID || r.PostForm.Get("client_secret") != ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	p.mu.Lock()
+	auth, ok := p.authorizations[r.PostForm.Get("code")]
+	delete(p.authorizations, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	if !ok || auth.redirectURI != r.PostForm.Get("redirect_uri") {
+		writeTokenError(w, "invalid_grant")
+		return
+	}
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(challenge[:]) != auth.codeChallenge {
+		writeTokenError(w, "invalid_grant")
+		return
+	}
+
+	now := time.Now()
+
+	claims := jwt.MapClaims{
+		"iss":            p.URL,
+		"aud":            ClientID,
+		"sub":            auth.user.Subject,
+		"email":          auth.user.Email,
+		"email_verified": auth.user.EmailVerified,
+		"name":           auth.user.Name,
+		"nonce":          auth.nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+	}
+
+	if p.IDTokenClaims != nil {
+		p.IDTokenClaims(claims)
+	}
+
+	var idToken string
+	var err error
+
+	if p.SignIDToken != nil {
+		idToken, err = p.SignIDToken(claims)
+	} else {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = KeyID
+		idToken, err = token.SignedString(p.key)
+	}
+
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": rand.Text(),
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func writeTokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,261 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gwid.io/gwid-core/internal/config"
+	"gwid.io/gwid-core/internal/oauth/oauthtest"
+)
+
+var testUser = oauthtest.User{
+	Subject:       "user-1",
+	Email:         "Ada@Example.com",
+	EmailVerified: true,
+	Name:          "Ada",
+}
+
+func newTestOIDCProvider(t *testing.T) (*oauthtest.Provider, *OIDCProvider) {
+	t.Helper()
+
+	server, err := oauthtest.NewProvider()
+	if err != nil {
+		t.Fatalf("oauthtest.NewProvider() error = %v", err)
+	}
+
+	t.Cleanup(server.Close)
+
+	provider := NewOIDCProvider(config.OAuthProviderConfig{
+		Name:         "sso",
+		Kind:         "oidc",
+		Issuer:       server.Issuer(),
+		ClientID:     oauthtest.ClientID,
+		ClientSecret: oauthtest.ClientSecret,
+		Scopes:       []string{"openid", "email", "profile"},
+	}, &http.Client{Timeout: 5 * time.Second})
+
+	return server, provider
+}
+
+func newTestAuthRequest(t *testing.T) AuthRequest {
+	t.Helper()
+
+	var authRequest AuthRequest
+
+	for _, value := range []*string{&authRequest.State, &authRequest.Nonce, &authRequest.CodeVerifier} {
+		var err error
+		if *value, err = RandomString(32); err != nil {
+			t.Fatalf("RandomString() error = %v", err)
+		}
+	}
+
+	authRequest.RedirectURI = "https://app.example.com/oauth/callback"
+
+	return authRequest
+}
+
+// login runs the flow up to the token exchange, sending the callback back
+// with the given auth request.
+func login(t *testing.T, server *oauthtest.Provider, provider *OIDCProvider, authRequest AuthRequest, callbackRequest AuthRequest) (*Identity, error) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	authURL, err := provider.AuthCodeURL(ctx, authRequest)
+	if err != nil {
+		t.Fatalf("AuthCodeURL() error = %v", err)
+	}
+
+	code, state, err := server.Authorize(authURL, testUser)
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+
+	if state != authRequest.State {
+		t.Fatalf("provider returned state %q, want %q", state, authRequest.State)
+	}
+
+	return provider.Exchange(ctx, code, callbackRequest)
+}
+
+func TestOIDCProviderAuthCodeURL(t *testing.T) {
+	_, provider := newTestOIDCProvider(t)
+	authRequest := newTestAuthRequest(t)
+
+	authURL, err := provider.AuthCodeURL(context.Background(), authRequest)
+	if err != nil {
+		t.Fatalf("AuthCodeURL() error = %v", err)
+	}
+
+	parsedURL, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("url.Parse() error = %v", err)
+	}
+
+	query := parsedURL.Query()
+
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             oauthtest.ClientID,
+		"redirect_uri":          authRequest.RedirectURI,
+		"scope":                 "openid email profile",
+		"state":                 authRequest.State,
+		"nonce":                 authRequest.Nonce,
+		"code_challenge":        CodeChallenge(authRequest.CodeVerifier),
+		"code_challenge_method": "S256",
+	}
+
+	for key, value := range want {
+		if got := query.Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+
+	if query.Has("code_verifier") || strings.Contains(authURL, authRequest.CodeVerifier) {
+		t.Error("authorization URL leaks the code verifier")
+	}
+}
+
+func TestOIDCProviderExchange(t *testing.T) {
+	server, provider := newTestOIDCProvider(t)
+	authRequest := newTestAuthRequest(t)
+
+	identity, err := login(t, server, provider, authRequest, authRequest)
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+
+	want := Identity{Subject: "user-1", Email: "ada@example.com", EmailVerified: true, Name: "Ada"}
+	if *identity != want {
+		t.Errorf("Exchange() = %+v, want %+v", *identity, want)
+	}
+}
+
+func TestOIDCProviderExchangeRejectsWrongCodeVerifier(t *testing.T) {
+	server, provider := newTestOIDCProvider(t)
+	authRequest := newTestAuthRequest(t)
+
+	callbackRequest := authRequest
+	callbackRequest.CodeVerifier = newTestAuthRequest(t).CodeVerifier
+
+	if _, err := login(t, server, provider, authRequest, callbackRequest); err == nil {
+		t.Fatal("Exchange() succeeded with another code verifier")
+	}
+}
+
+func TestOIDCProviderExchangeRejectsInvalidIDToken(t *testing.T) {
+	tests := map[string]func(claims jwt.MapClaims){
+		"wrong issuer":           func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example.com" },
+		"wrong audience":         func(claims jwt.MapClaims) { claims["aud"] = "another-client" },
+		"expired":                func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-5 * time.Minute).Unix() },
+		"missing expiry":         func(claims jwt.MapClaims) { delete(claims, "exp") },
+		"issued in the future":   func(claims jwt.MapClaims) { claims["iat"] = time.Now().Add(time.Hour).Unix() },
+		"missing subject":        func(claims jwt.MapClaims) { delete(claims, "sub") },
+		"nonce of another login": func(claims jwt.MapClaims) { claims["nonce"] = "replayed" },
+		"missing nonce":          func(claims jwt.MapClaims) { delete(claims, "nonce") },
+	}
+
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			server, provider := newTestOIDCProvider(t)
+			server.IDTokenClaims = mutate
+
+			authRequest := newTestAuthRequest(t)
+
+			if _, err := login(t, server, provider, authRequest, authRequest); err == nil {
+				t.Fatal("Exchange() accepted the id_token")
+			}
+		})
+	}
+}
+
+func TestOIDCProviderExchangeRestrictsAlgorithms(t *testing.T) {
+	tests := map[string]func(server *oauthtest.Provider) func(claims jwt.MapClaims) (string, error){
+		"none": func(server *oauthtest.Provider) func(claims jwt.MapClaims) (string, error) {
+			return func(claims jwt.MapClaims) (string, error) {
+				token := jwt.NewWithClaims(jwt.SigningMethodNone, claims)
+				token.Header["kid"] = oauthtest.KeyID
+
+				return token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+			}
+		},
+		"HS256 with the public key": func(server *oauthtest.Provider) func(claims jwt.MapClaims) (string, error) {
+			return func(claims jwt.MapClaims) (string, error) {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+				token.Header["kid"] = oauthtest.KeyID
+
+				return token.SignedString(server.PublicKey().N.Bytes())
+			}
+		},
+		"unknown key": func(server *oauthtest.Provider) func(claims jwt.MapClaims) (string, error) {
+			return func(claims jwt.MapClaims) (string, error) {
+				key, err := rsa.GenerateKey(rand.Reader, 2048)
+				if err != nil {
+					return "", err
+				}
+
+				token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+				token.Header["kid"] = "other-key"
+
+				return token.SignedString(key)
+			}
+		},
+	}
+
+	for name, signer := range tests {
+		t.Run(name, func(t *testing.T) {
+			server, provider := newTestOIDCProvider(t)
+			server.SignIDToken = signer(server)
+
+			authRequest := newTestAuthRequest(t)
+
+			if _, err := login(t, server, provider, authRequest, authRequest); err == nil {
+				t.Fatal("Exchange() accepted the id_token")
+			}
+		})
+	}
+}
+
+func TestOIDCProviderEmailVerified(t *testing.T) {
+	tests := map[string]struct {
+		value any
+		want  bool
+	}{
+		"true":         {value: true, want: true},
+		"string true":  {value: "true", want: true},
+		"false":        {value: false, want: false},
+		"string false": {value: "false", want: false},
+		"missing":      {value: nil, want: false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server, provider := newTestOIDCProvider(t)
+			server.IDTokenClaims = func(claims jwt.MapClaims) {
+				if tt.value == nil {
+					delete(claims, "email_verified")
+				} else {
+					claims["email_verified"] = tt.value
+				}
+			}
+
+			authRequest := newTestAuthRequest(t)
+
+			identity, err := login(t, server, provider, authRequest, authRequest)
+			if err != nil {
+				t.Fatalf("Exchange() error = %v", err)
+			}
+
+			if identity.EmailVerified != tt.want {
+				t.Errorf("EmailVerified = %v, want %v", identity.EmailVerified, tt.want)
+			}
+		})
+	}
+}
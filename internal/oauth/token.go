@@ -0,0 +1,94 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type tokenRes struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// exchangeCode redeems an authorization code at the token endpoint of a
+// provider.
+func exchangeCode(ctx context.Context, httpClient *http.Client, tokenURL string, form url.Values) (*tokenRes, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var res tokenRes
+
+	statusCode, err := doJSON(httpClient, req, &res)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+
+	if res.Error != "" {
+		return nil, fmt.Errorf("token request failed: %s %s", res.Error, res.ErrorDescription)
+	}
+
+	if statusCode != http.StatusOK || res.AccessToken == "" {
+		return nil, fmt.Errorf("token request failed with status %d", statusCode)
+	}
+
+	return &res, nil
+}
+
+// doJSON sends the request and decodes the response body into res whatever
+// the status code, so that error bodies can be inspected.
+func doJSON(httpClient *http.Client, req *http.Request, res any) (int, error) {
+	httpRes, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer httpRes.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(httpRes.Body, 1<<20))
+	if err != nil {
+		return httpRes.StatusCode, err
+	}
+
+	if err := json.Unmarshal(body, res); err != nil {
+		return httpRes.StatusCode, fmt.Errorf("unexpected response with status %d", httpRes.StatusCode)
+	}
+
+	return httpRes.StatusCode, nil
+}
+
+func getJSON(ctx context.Context, httpClient *http.Client, url string, bearerToken string, res any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	if bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+bearerToken)
+	}
+
+	statusCode, err := doJSON(httpClient, req, res)
+	if err != nil {
+		return fmt.Errorf("GET %s failed: %w", url, err)
+	}
+
+	if statusCode != http.StatusOK {
+		return fmt.Errorf("GET %s failed with status %d", url, statusCode)
+	}
+
+	return nil
+}
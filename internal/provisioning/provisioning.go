@@ -0,0 +1,161 @@
+// Package provisioning renders the scripts that install a gateway on a
+// provisioned instance and parses what those scripts report back
+package provisioning
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"gwid.io/gwid-core/internal/types"
+)
+
+// GatewayDeployScriptVersion is bumped whenever deploy_gateway.sh.tmpl changes
+// in a way that matters to the instances it produces.
+const GatewayDeployScriptVersion = "1.0.0"
+
+//go:embed scripts/*.tmpl
+var scriptFS embed.FS
+
+var deployGatewayTemplate = template.Must(
+	template.New("deploy_gateway.sh.tmpl").
+		Funcs(template.FuncMap{"shellQuote": shellQuote}).
+		ParseFS(scriptFS, "scripts/deploy_gateway.sh.tmpl"),
+)
+
+type transcodingProfile struct {
+	Name    string `json:"name"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	Bitrate int    `json:"bitrate"`
+	FPS     int    `json:"fps"`
+	Profile string `json:"profile"`
+}
+
+// transcodingLadders maps the profiles offered in the API to the renditions
+// the gateway asks orchestrators for.
+var transcodingLadders = map[string][]transcodingProfile{
+	"480p": {
+		{Name: "240p0", Width: 426, Height: 240, Bitrate: 250000, FPS: 30, Profile: "H264ConstrainedHigh"},
+		{Name: "360p0", Width: 640, Height: 360, Bitrate: 500000, FPS: 30, Profile: "H264ConstrainedHigh"},
+		{Name: "480p0", Width: 854, Height: 480, Bitrate: 1000000, FPS: 30, Profile: "H264ConstrainedHigh"},
+	},
+	"720p": {
+		{Name: "360p0", Width: 640, Height: 360, Bitrate: 500000, FPS: 30, Profile: "H264ConstrainedHigh"},
+		{Name: "480p0", Width: 854, Height: 480, Bitrate: 1000000, FPS: 30, Profile: "H264ConstrainedHigh"},
+		{Name: "720p0", Width: 1280, Height: 720, Bitrate: 3000000, FPS: 30, Profile: "H264ConstrainedHigh"},
+	},
+	"1080p": {
+		{Name: "360p0", Width: 640, Height: 360, Bitrate: 500000, FPS: 30, Profile: "H264ConstrainedHigh"},
+		{Name: "720p0", Width: 1280, Height: 720, Bitrate: 3000000, FPS: 30, Profile: "H264ConstrainedHigh"},
+		{Name: "1080p0", Width: 1920, Height: 1080, Bitrate: 6500000, FPS: 30, Profile: "H264ConstrainedHigh"},
+	},
+}
+
+type deployGatewayTemplateData struct {
+	types.GatewayDeployScriptParams
+	ScriptVersion          string
+	TranscodingOptionsJSON string
+}
+
+// RenderGatewayDeployScript builds the shell script that installs go-livepeer
+// for the given gateway. It has no side effects, so it can be exercised without
+// any cloud account.
+func RenderGatewayDeployScript(params types.GatewayDeployScriptParams) (string, error) {
+	if err := validateScriptParams(params); err != nil {
+		return "", err
+	}
+
+	data := deployGatewayTemplateData{
+		GatewayDeployScriptParams: params,
+		ScriptVersion:             GatewayDeployScriptVersion,
+	}
+
+	if params.GatewayType == "transcoding" {
+		ladder, ok := transcodingLadders[params.TranscodingProfile]
+		if !ok {
+			return "", fmt.Errorf("unsupported transcoding profile %q", params.TranscodingProfile)
+		}
+
+		profilesJSON, err := json.Marshal(ladder)
+		if err != nil {
+			return "", err
+		}
+
+		data.TranscodingOptionsJSON = string(profilesJSON)
+	}
+
+	var script bytes.Buffer
+
+	if err := deployGatewayTemplate.Execute(&script, data); err != nil {
+		return "", fmt.Errorf("unable to render deploy script: %w", err)
+	}
+
+	return script.String(), nil
+}
+
+// ParseGatewayDeployResult extracts the JSON status line the deploy script
+// prints last. Any other output before it, such as installer logs, is ignored.
+func ParseGatewayDeployResult(stdout string) (*types.GatewayDeployResult, error) {
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+
+		var result types.GatewayDeployResult
+
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			return nil, fmt.Errorf("invalid deploy result: %w", err)
+		}
+
+		return &result, nil
+	}
+
+	return nil, errors.New("deploy script did not report a result")
+}
+
+func validateScriptParams(params types.GatewayDeployScriptParams) error {
+	switch {
+	case params.GatewayName == "":
+		return errors.New("gateway name is required")
+	case params.LivepeerVersion == "":
+		return errors.New("livepeer version is required")
+	case params.GatewayType != "transcoding" && params.GatewayType != "ai":
+		return fmt.Errorf("unsupported gateway type %q", params.GatewayType)
+	}
+
+	// Values are written into heredocs and a line based config file, so a line
+	// break would let them escape into the surrounding script.
+	for name, value := range map[string]string{
+		"gateway name":     params.GatewayName,
+		"rpc url":          params.RPCURL,
+		"password":         params.Password,
+		"livepeer version": params.LivepeerVersion,
+	} {
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("%s must not contain line breaks", name)
+		}
+	}
+
+	if strings.ContainsAny(params.RPCURL, " \t") {
+		return errors.New("rpc url must not contain whitespace")
+	}
+
+	if params.Password == "GWID_PASSWORD" {
+		return errors.New("password is not allowed")
+	}
+
+	return nil
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
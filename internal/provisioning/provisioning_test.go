@@ -0,0 +1,182 @@
+package provisioning
+
+import (
+	"strings"
+	"testing"
+
+	"gwid.io/gwid-core/internal/types"
+)
+
+func validParams() types.GatewayDeployScriptParams {
+	return types.GatewayDeployScriptParams{
+		GatewayName:        "my-gateway",
+		GatewayType:        "transcoding",
+		RPCURL:             "https://arb1.example.com/rpc",
+		Password:           "s3cret",
+		TranscodingProfile: "720p",
+		LivepeerVersion:    "v0.8.0",
+	}
+}
+
+func TestRenderGatewayDeployScriptQuotesValues(t *testing.T) {
+	params := validParams()
+	params.GatewayName = "it's $(reboot)"
+
+	script, err := RenderGatewayDeployScript(params)
+	if err != nil {
+		t.Fatalf("RenderGatewayDeployScript() error = %v", err)
+	}
+
+	want := `GATEWAY_NAME='it'\''s $(reboot)'`
+	if !strings.Contains(script, want) {
+		t.Errorf("script does not contain %s", want)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"":            `''`,
+		"plain":       `'plain'`,
+		"it's":        `'it'\''s'`,
+		"$HOME `id`":  "'$HOME `id`'",
+		"a'b'c":       `'a'\''b'\''c'`,
+		"with spaces": `'with spaces'`,
+	}
+
+	for value, want := range tests {
+		if got := shellQuote(value); got != want {
+			t.Errorf("shellQuote(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
+
+func TestRenderGatewayDeployScriptRejectsLineBreaks(t *testing.T) {
+	tests := map[string]func(*types.GatewayDeployScriptParams){
+		"gateway name":     func(p *types.GatewayDeployScriptParams) { p.GatewayName = "gw\nrm -rf /" },
+		"rpc url":          func(p *types.GatewayDeployScriptParams) { p.RPCURL = "https://rpc\nmonitor false" },
+		"password":         func(p *types.GatewayDeployScriptParams) { p.Password = "pass\nGWID_PASSWORD\nreboot" },
+		"livepeer version": func(p *types.GatewayDeployScriptParams) { p.LivepeerVersion = "v0.8.0\r" },
+	}
+
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			params := validParams()
+			mutate(&params)
+
+			if _, err := RenderGatewayDeployScript(params); err == nil {
+				t.Fatal("RenderGatewayDeployScript() accepted a line break")
+			}
+		})
+	}
+}
+
+func TestRenderGatewayDeployScriptRejectsInvalidParams(t *testing.T) {
+	tests := map[string]func(*types.GatewayDeployScriptParams){
+		"missing name":          func(p *types.GatewayDeployScriptParams) { p.GatewayName = "" },
+		"missing version":       func(p *types.GatewayDeployScriptParams) { p.LivepeerVersion = "" },
+		"unknown type":          func(p *types.GatewayDeployScriptParams) { p.GatewayType = "relay" },
+		"unknown profile":       func(p *types.GatewayDeployScriptParams) { p.TranscodingProfile = "4k" },
+		"whitespace in rpc url": func(p *types.GatewayDeployScriptParams) { p.RPCURL = "https://rpc monitor" },
+		"heredoc terminator":    func(p *types.GatewayDeployScriptParams) { p.Password = "GWID_PASSWORD" },
+	}
+
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			params := validParams()
+			mutate(&params)
+
+			if _, err := RenderGatewayDeployScript(params); err == nil {
+				t.Fatal("RenderGatewayDeployScript() accepted invalid params")
+			}
+		})
+	}
+}
+
+func TestRenderGatewayDeployScriptPinsVersions(t *testing.T) {
+	script, err := RenderGatewayDeployScript(validParams())
+	if err != nil {
+		t.Fatalf("RenderGatewayDeployScript() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"# gwid gateway deploy script v" + GatewayDeployScriptVersion,
+		"SCRIPT_VERSION='" + GatewayDeployScriptVersion + "'",
+		"LIVEPEER_VERSION='v0.8.0'",
+		"releases/download/${LIVEPEER_VERSION}/",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script does not contain %q", want)
+		}
+	}
+
+	if strings.Contains(script, "latest") {
+		t.Error("script refers to an unpinned latest release")
+	}
+}
+
+func TestRenderGatewayDeployScriptGatewayTypes(t *testing.T) {
+	transcoding, err := RenderGatewayDeployScript(validParams())
+	if err != nil {
+		t.Fatalf("RenderGatewayDeployScript() error = %v", err)
+	}
+
+	if !strings.Contains(transcoding, "transcodingOptions /etc/livepeer/transcoding-options.json") ||
+		!strings.Contains(transcoding, `"name":"720p0"`) {
+		t.Error("transcoding script does not configure the transcoding ladder")
+	}
+
+	params := validParams()
+	params.GatewayType = "ai"
+	params.TranscodingProfile = ""
+
+	ai, err := RenderGatewayDeployScript(params)
+	if err != nil {
+		t.Fatalf("RenderGatewayDeployScript() error = %v", err)
+	}
+
+	if strings.Contains(ai, "transcodingOptions") || !strings.Contains(ai, "httpIngest true") {
+		t.Error("ai script is not configured for http ingest")
+	}
+}
+
+func TestParseGatewayDeployResult(t *testing.T) {
+	t.Run("success after installer output", func(t *testing.T) {
+		stdout := "Reading package lists...\nDone\n" +
+			`{"success":true,"script_version":"1.0.0","livepeer_version":"v0.8.0","step":"done","error":""}` + "\n"
+
+		result, err := ParseGatewayDeployResult(stdout)
+		if err != nil {
+			t.Fatalf("ParseGatewayDeployResult() error = %v", err)
+		}
+
+		if !result.Success || result.Step != "done" || result.ScriptVersion != "1.0.0" || result.LivepeerVersion != "v0.8.0" {
+			t.Errorf("ParseGatewayDeployResult() = %+v", result)
+		}
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		stdout := "curl: (22) The requested URL returned error: 404\n" +
+			`{"success":false,"script_version":"1.0.0","livepeer_version":"v9.9.9","step":"download_livepeer","error":"deployment failed"}`
+
+		result, err := ParseGatewayDeployResult(stdout)
+		if err != nil {
+			t.Fatalf("ParseGatewayDeployResult() error = %v", err)
+		}
+
+		if result.Success || result.Step != "download_livepeer" || result.Error != "deployment failed" {
+			t.Errorf("ParseGatewayDeployResult() = %+v", result)
+		}
+	})
+
+	t.Run("no result", func(t *testing.T) {
+		if _, err := ParseGatewayDeployResult("E: Unable to locate package curl\n"); err == nil {
+			t.Fatal("ParseGatewayDeployResult() accepted output without a result")
+		}
+	})
+
+	t.Run("malformed result", func(t *testing.T) {
+		if _, err := ParseGatewayDeployResult(`{"success":tru`); err == nil {
+			t.Fatal("ParseGatewayDeployResult() accepted a malformed result")
+		}
+	})
+}
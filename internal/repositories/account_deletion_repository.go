@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gwid.io/gwid-core/internal/models"
+)
+
+type AccountDeletionRepository struct {
+	db *gorm.DB
+}
+
+func NewAccountDeletionRepository(db *gorm.DB) *AccountDeletionRepository {
+	return &AccountDeletionRepository{
+		db: db,
+	}
+}
+
+// CreateAccountDeletion records the deletion and locks the user out in one
+// transaction.
+func (repo *AccountDeletionRepository) CreateAccountDeletion(deletion *models.AccountDeletion, user *models.User) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Select("deletion_requested_at").Updates(user).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(deletion).Error
+	})
+}
+
+func (repo *AccountDeletionRepository) GetAccountDeletionByID(id uuid.UUID) (*models.AccountDeletion, *gorm.DB) {
+	var deletion models.AccountDeletion
+
+	result := repo.db.Where(&models.AccountDeletion{ID: id}).First(&deletion)
+
+	return &deletion, result
+}
+
+func (repo *AccountDeletionRepository) UpdateAccountDeletionColumns(deletion *models.AccountDeletion, columns ...string) error {
+	result := repo.db.Model(deletion).Select(columns).Updates(deletion)
+
+	return result.Error
+}
@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gwid.io/gwid-core/internal/models"
+)
+
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) *APIKeyRepository {
+	return &APIKeyRepository{
+		db: db,
+	}
+}
+
+func (repo *APIKeyRepository) CreateAPIKey(apiKey *models.APIKey) error {
+	result := repo.db.Create(apiKey)
+
+	return result.Error
+}
+
+func (repo *APIKeyRepository) GetUserAPIKeys(userID uuid.UUID) (*[]models.APIKey, error) {
+	var apiKeys []models.APIKey
+
+	result := repo.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&apiKeys)
+
+	return &apiKeys, result.Error
+}
+
+func (repo *APIKeyRepository) CountUserAPIKeys(userID uuid.UUID) (int64, error) {
+	var count int64
+
+	result := repo.db.Model(&models.APIKey{}).Where("user_id = ?", userID).Count(&count)
+
+	return count, result.Error
+}
+
+func (repo *APIKeyRepository) GetAPIKeyByHash(keyHash string) (*models.APIKey, *gorm.DB) {
+	var apiKey models.APIKey
+
+	result := repo.db.Preload("User").Where(&models.APIKey{KeyHash: keyHash}).First(&apiKey)
+
+	return &apiKey, result
+}
+
+func (repo *APIKeyRepository) DeleteAPIKey(id uuid.UUID, userID uuid.UUID) *gorm.DB {
+	result := repo.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.APIKey{})
+
+	return result
+}
+
+// TouchAPIKey records that the key was used, at most once per interval so that
+// busy keys do not write on every request.
+func (repo *APIKeyRepository) TouchAPIKey(apiKey *models.APIKey, interval time.Duration) error {
+	now := time.Now()
+
+	result := repo.db.Model(apiKey).
+		Where("last_used_at IS NULL OR last_used_at < ?", now.Add(-interval)).
+		Update("last_used_at", now)
+
+	return result.Error
+}
@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gwid.io/gwid-core/internal/middleware"
+	"gwid.io/gwid-core/internal/models"
+)
+
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{
+		db: db,
+	}
+}
+
+func (repo *AuditRepository) CreateAuditEvent(event *models.AuditEvent) error {
+	result := repo.db.Create(event)
+
+	return result.Error
+}
+
+// filterAuditEvents applies the search on the action and the actor, action,
+// target, request, IP and time range filters. The time bounds have to be
+// validated by the caller.
+func filterAuditEvents(params *middleware.QueryParams) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if params.Search != "" {
+			db = db.Where("action ILIKE ?", params.Search+"%")
+		}
+
+		for _, column := range []string{"actor_id", "action", "target_type", "target_id", "request_id", "ip_address"} {
+			if value := params.Filters[column]; value != "" {
+				db = db.Where(column+" = ?", value)
+			}
+		}
+
+		if from := params.Filters["from"]; from != "" {
+			db = db.Where("created_at >= ?", from)
+		}
+
+		if to := params.Filters["to"]; to != "" {
+			db = db.Where("created_at < ?", to)
+		}
+
+		return db
+	}
+}
+
+// GetAuditEvents lists events newest first. The ID breaks ties so that paging
+// through events created in the same instant is stable.
+func (repo *AuditRepository) GetAuditEvents(params *middleware.QueryParams) (*[]models.AuditEvent, error) {
+	var events []models.AuditEvent
+
+	result := repo.db.Scopes(filterAuditEvents(params)).Offset(params.Offset).Limit(params.Limit).Order("created_at " + params.Order).Order("id " + params.Order).Find(&events)
+
+	return &events, result.Error
+}
+
+func (repo *AuditRepository) GetAuditEventsCount(params *middleware.QueryParams) (int64, error) {
+	var count int64
+
+	result := repo.db.Model(&models.AuditEvent{}).Scopes(filterAuditEvents(params)).Count(&count)
+
+	return count, result.Error
+}
+
+// involvingUser keeps the events of actions taken by the user and of actions
+// taken on them, such as failed sign-ins and admin suspensions.
+func involvingUser(userID uuid.UUID) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("actor_id = ? OR (target_type = ? AND target_id = ?)", userID, models.AuditTargetUser, userID.String())
+	}
+}
+
+func (repo *AuditRepository) GetUserAuditEvents(userID uuid.UUID, params *middleware.QueryParams) (*[]models.AuditEvent, error) {
+	var events []models.AuditEvent
+
+	result := repo.db.Scopes(involvingUser(userID), filterAuditEvents(params)).Offset(params.Offset).Limit(params.Limit).Order("created_at " + params.Order).Order("id " + params.Order).Find(&events)
+
+	return &events, result.Error
+}
+
+func (repo *AuditRepository) GetUserAuditEventsCount(userID uuid.UUID, params *middleware.QueryParams) (int64, error) {
+	var count int64
+
+	result := repo.db.Model(&models.AuditEvent{}).Scopes(involvingUser(userID), filterAuditEvents(params)).Count(&count)
+
+	return count, result.Error
+}
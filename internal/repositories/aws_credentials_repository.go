@@ -26,31 +26,85 @@ func (repo *AWSCredentialsRepository) CreateAWSCredentials(awsCredentials *model
 func (repo *AWSCredentialsRepository) GetCredentialsByAccessKeyID(accessKeyID string) (*models.AWSCredentials, *gorm.DB) {
 	var credentials models.AWSCredentials
 
-	result := repo.db.Where(models.AWSCredentials{AccessKeyID: accessKeyID}).First(&credentials)
+	result := repo.db.Where(models.AWSCredentials{AccessKeyID: &accessKeyID}).First(&credentials)
 
 	return &credentials, result
 }
 
-func (repo *AWSCredentialsRepository) GetCredentialsByID(id uuid.UUID, userID uuid.UUID) (*models.AWSCredentials, *gorm.DB) {
+func (repo *AWSCredentialsRepository) GetCredentialsByAssumeRoleARN(assumeRoleARN string, organizationID uuid.UUID) (*models.AWSCredentials, *gorm.DB) {
 	var credentials models.AWSCredentials
 
-	result := repo.db.Where(models.AWSCredentials{ID: id, UserID: userID}).First(&credentials)
+	result := repo.db.Where(models.AWSCredentials{AssumeRoleARN: &assumeRoleARN, OrganizationID: organizationID}).First(&credentials)
 
 	return &credentials, result
 }
 
-func (repo *AWSCredentialsRepository) GetUserCredentials(userID uuid.UUID, params *middleware.QueryParams) (*[]models.AWSCredentials, error) {
+func (repo *AWSCredentialsRepository) GetCredentialsByID(id uuid.UUID, organizationID uuid.UUID) (*models.AWSCredentials, *gorm.DB) {
+	var credentials models.AWSCredentials
+
+	result := repo.db.Where(models.AWSCredentials{ID: id, OrganizationID: organizationID}).First(&credentials)
+
+	return &credentials, result
+}
+
+func (repo *AWSCredentialsRepository) GetOrganizationCredentials(organizationID uuid.UUID, params *middleware.QueryParams) (*[]models.AWSCredentials, error) {
 	var credentials []models.AWSCredentials
 
-	result := repo.db.Offset(params.Offset).Limit(params.Limit).Order(params.Sort + " " + params.Order).Where(&models.AWSCredentials{UserID: userID}).Find(&credentials)
+	result := repo.db.Offset(params.Offset).Limit(params.Limit).Order(params.Sort + " " + params.Order).Where(&models.AWSCredentials{OrganizationID: organizationID}).Find(&credentials)
 
 	return &credentials, result.Error
 }
 
-func (repo *AWSCredentialsRepository) GetUserCredentialsCount(userID uuid.UUID) (int64, error) {
+func (repo *AWSCredentialsRepository) GetOrganizationCredentialsCount(organizationID uuid.UUID) (int64, error) {
 	var count int64
 
-	result := repo.db.Model(&models.AWSCredentials{}).Where(&models.AWSCredentials{UserID: userID}).Count(&count)
+	result := repo.db.Model(&models.AWSCredentials{}).Where(&models.AWSCredentials{OrganizationID: organizationID}).Count(&count)
 
 	return count, result.Error
 }
+
+func (repo *AWSCredentialsRepository) GetAllOrganizationCredentials(organizationID uuid.UUID) (*[]models.AWSCredentials, error) {
+	var credentials []models.AWSCredentials
+
+	result := repo.db.Where(&models.AWSCredentials{OrganizationID: organizationID}).Find(&credentials)
+
+	return &credentials, result.Error
+}
+
+func (repo *AWSCredentialsRepository) UpdateAWSCredentialsColumns(awsCredentials *models.AWSCredentials, columns ...string) error {
+	result := repo.db.Model(awsCredentials).Select(columns).Updates(awsCredentials)
+
+	return result.Error
+}
+
+func (repo *AWSCredentialsRepository) DeleteAWSCredentials(awsCredentials *models.AWSCredentials) error {
+	result := repo.db.Delete(awsCredentials)
+
+	return result.Error
+}
+
+// GetCredentialsNotEncryptedWith returns up to limit access key credentials,
+// ordered by ID and starting after afterID, whose secret is not prefixed with
+// the given encryption key ID.
+func (repo *AWSCredentialsRepository) GetCredentialsNotEncryptedWith(keyID string, afterID uuid.UUID, limit int) (*[]models.AWSCredentials, error) {
+	var credentials []models.AWSCredentials
+
+	prefix := keyID + ":"
+
+	result := repo.db.
+		Where("kind = ? AND secret_access_key <> '' AND left(secret_access_key, ?) <> ?", models.AWSCredentialsAccessKey, len(prefix), prefix).
+		Where("id > ?", afterID).
+		Order("id").
+		Limit(limit).
+		Find(&credentials)
+
+	return &credentials, result.Error
+}
+
+// ReplaceSecretAccessKey saves the secret of the credentials unless it was
+// changed since previous was read.
+func (repo *AWSCredentialsRepository) ReplaceSecretAccessKey(awsCredentials *models.AWSCredentials, previous string) (bool, error) {
+	result := repo.db.Model(awsCredentials).Where("secret_access_key = ?", previous).Update("secret_access_key", awsCredentials.SecretAccessKey)
+
+	return result.RowsAffected > 0, result.Error
+}
@@ -23,23 +23,47 @@ func (repo *GatewayRepository) CreateGateway(gateway *models.Gateway) error {
 	return result.Error
 }
 
-func (repo *GatewayRepository) GetUserGateways(userID uuid.UUID, params *middleware.QueryParams) (*[]models.Gateway, error) {
+func (repo *GatewayRepository) GetOrganizationGateways(organizationID uuid.UUID, params *middleware.QueryParams) (*[]models.Gateway, error) {
 	var gateways []models.Gateway
 
-	result := repo.db.Offset(params.Offset).Limit(params.Limit).Order(params.Sort + " " + params.Order).Where(&models.Gateway{UserID: userID}).Find(&gateways)
+	result := repo.db.Offset(params.Offset).Limit(params.Limit).Order(params.Sort + " " + params.Order).Where(&models.Gateway{OrganizationID: organizationID}).Find(&gateways)
 
 	return &gateways, result.Error
 }
 
-func (repo *GatewayRepository) GetUserGatewaysCount(userID uuid.UUID) (int64, error) {
+func (repo *GatewayRepository) GetOrganizationGatewaysCount(organizationID uuid.UUID) (int64, error) {
 	var count int64
 
-	result := repo.db.Model(&models.Gateway{}).Where(&models.Gateway{UserID: userID}).Count(&count)
+	result := repo.db.Model(&models.Gateway{}).Where(&models.Gateway{OrganizationID: organizationID}).Count(&count)
 
 	return count, result.Error
 }
 
-func (repo *GatewayRepository) GetGateway(id uuid.UUID) {}
+func (repo *GatewayRepository) GetAllOrganizationGateways(organizationID uuid.UUID) (*[]models.Gateway, error) {
+	var gateways []models.Gateway
+
+	result := repo.db.Where(&models.Gateway{OrganizationID: organizationID}).Find(&gateways)
+
+	return &gateways, result.Error
+}
+
+func (repo *GatewayRepository) GetGatewayByID(id uuid.UUID, organizationID uuid.UUID) (*models.Gateway, *gorm.DB) {
+	var gateway models.Gateway
+
+	result := repo.db.Where(&models.Gateway{ID: id, OrganizationID: organizationID}).First(&gateway)
+
+	return &gateway, result
+}
+
+// GetAnyGatewayByID looks a gateway up regardless of its organization, for
+// admins.
+func (repo *GatewayRepository) GetAnyGatewayByID(id uuid.UUID) (*models.Gateway, *gorm.DB) {
+	var gateway models.Gateway
+
+	result := repo.db.Where(&models.Gateway{ID: id}).First(&gateway)
+
+	return &gateway, result
+}
 
 func (repo *GatewayRepository) GetGatewayByName(name string) (*models.Gateway, *gorm.DB) {
 	var gateway models.Gateway
@@ -57,8 +81,86 @@ func (repo *GatewayRepository) GetGatewayByInstanceID(instanceID string) (*model
 	return &gateway, result
 }
 
-func (repo *GatewayRepository) UpdateGateway(gateway *models.Gateway) error {
-	result := repo.db.Updates(&gateway)
+func (repo *GatewayRepository) GetGatewaysByCredentialsID(credentialsID uuid.UUID, organizationID uuid.UUID) (*[]models.Gateway, error) {
+	var gateways []models.Gateway
+
+	result := repo.db.Where(&models.Gateway{AWSCredentialsID: credentialsID, OrganizationID: organizationID}).Find(&gateways)
+
+	return &gateways, result.Error
+}
+
+// UpdateGatewayStatus moves the gateway to the next status and saves it along
+// with the given columns. The write only goes through if the stored status is
+// still the one the transition was checked against.
+func (repo *GatewayRepository) UpdateGatewayStatus(gateway *models.Gateway, next models.GatewayStatus, columns ...string) error {
+	current := gateway.Status
+
+	if err := gateway.TransitionTo(next); err != nil {
+		return err
+	}
+
+	result := repo.db.Model(gateway).Where("status = ?", current).Select(append(columns, "status")).Updates(gateway)
+
+	if result.Error != nil {
+		gateway.Status = current
+
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		gateway.Status = current
+
+		return models.ErrGatewayStatusChanged
+	}
+
+	return nil
+}
+
+// UpdateGatewayColumns saves the given columns. Status changes must use
+// UpdateGatewayStatus instead.
+func (repo *GatewayRepository) UpdateGatewayColumns(gateway *models.Gateway, columns ...string) error {
+	result := repo.db.Model(gateway).Select(columns).Updates(gateway)
 
 	return result.Error
 }
+
+func (repo *GatewayRepository) DeleteGateway(gateway *models.Gateway) error {
+	result := repo.db.Delete(gateway)
+
+	return result.Error
+}
+
+// filterGateways applies the search and the status, provider, organization and
+// user filters of the admin gateway list.
+func filterGateways(params *middleware.QueryParams) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if params.Search != "" {
+			db = db.Where("gateway_name ILIKE ?", "%"+params.Search+"%")
+		}
+
+		for _, column := range []string{"status", "provider", "region", "organization_id", "user_id"} {
+			if value := params.Filters[column]; value != "" {
+				db = db.Where(column+" = ?", value)
+			}
+		}
+
+		return db
+	}
+}
+
+// GetGateways lists gateways across all organizations.
+func (repo *GatewayRepository) GetGateways(params *middleware.QueryParams) (*[]models.Gateway, error) {
+	var gateways []models.Gateway
+
+	result := repo.db.Scopes(filterGateways(params)).Offset(params.Offset).Limit(params.Limit).Order(params.Sort + " " + params.Order).Find(&gateways)
+
+	return &gateways, result.Error
+}
+
+func (repo *GatewayRepository) GetGatewaysCount(params *middleware.QueryParams) (int64, error) {
+	var count int64
+
+	result := repo.db.Model(&models.Gateway{}).Scopes(filterGateways(params)).Count(&count)
+
+	return count, result.Error
+}
@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gwid.io/gwid-core/internal/models"
+)
+
+type OAuthRepository struct {
+	db *gorm.DB
+}
+
+func NewOAuthRepository(db *gorm.DB) *OAuthRepository {
+	return &OAuthRepository{
+		db: db,
+	}
+}
+
+func (repo *OAuthRepository) CreateOAuthState(oauthState *models.OAuthState) error {
+	result := repo.db.Create(oauthState)
+
+	return result.Error
+}
+
+func (repo *OAuthRepository) GetOAuthStateByHash(stateHash string) (*models.OAuthState, *gorm.DB) {
+	var oauthState models.OAuthState
+
+	result := repo.db.Where(&models.OAuthState{StateHash: stateHash}).First(&oauthState)
+
+	return &oauthState, result
+}
+
+// ConsumeOAuthState marks the state used and reports false if it already was.
+func (repo *OAuthRepository) ConsumeOAuthState(oauthState *models.OAuthState) (bool, error) {
+	now := time.Now()
+
+	result := repo.db.Model(oauthState).Where("used_at IS NULL").Update("used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	oauthState.UsedAt = &now
+
+	return true, nil
+}
+
+// DeleteExpiredOAuthStates removes states of logins that were abandoned or
+// completed before the given time.
+func (repo *OAuthRepository) DeleteExpiredOAuthStates(before time.Time) (int64, error) {
+	result := repo.db.Where("expires_at < ?", before).Delete(&models.OAuthState{})
+
+	return result.RowsAffected, result.Error
+}
+
+func (repo *OAuthRepository) GetUserIdentity(provider string, subject string) (*models.UserIdentity, *gorm.DB) {
+	var userIdentity models.UserIdentity
+
+	result := repo.db.Where(&models.UserIdentity{Provider: provider, Subject: subject}).First(&userIdentity)
+
+	return &userIdentity, result
+}
+
+func (repo *OAuthRepository) CreateUserIdentity(userIdentity *models.UserIdentity) error {
+	result := repo.db.Create(userIdentity)
+
+	return result.Error
+}
+
+func (repo *OAuthRepository) UpdateUserIdentityColumns(userIdentity *models.UserIdentity, columns ...string) error {
+	result := repo.db.Model(userIdentity).Select(columns).Updates(userIdentity)
+
+	return result.Error
+}
@@ -0,0 +1,165 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gwid.io/gwid-core/internal/models"
+)
+
+type OrganizationRepository struct {
+	db *gorm.DB
+}
+
+func NewOrganizationRepository(db *gorm.DB) *OrganizationRepository {
+	return &OrganizationRepository{
+		db: db,
+	}
+}
+
+// CreateOrganization creates the organization with the given user as its
+// owner.
+func (repo *OrganizationRepository) CreateOrganization(organization *models.Organization, ownerID uuid.UUID) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(organization).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.OrganizationMember{
+			OrganizationID: organization.ID,
+			UserID:         ownerID,
+			Role:           models.OrgRoleOwner,
+		}).Error
+	})
+}
+
+func (repo *OrganizationRepository) UpdateOrganizationColumns(organization *models.Organization, columns ...string) error {
+	result := repo.db.Model(organization).Select(columns).Updates(organization)
+
+	return result.Error
+}
+
+func (repo *OrganizationRepository) DeleteOrganization(organization *models.Organization) error {
+	result := repo.db.Delete(organization)
+
+	return result.Error
+}
+
+func (repo *OrganizationRepository) GetPersonalOrganization(userID uuid.UUID) (*models.Organization, *gorm.DB) {
+	var organization models.Organization
+
+	result := repo.db.Where("personal_owner_id = ?", userID).First(&organization)
+
+	return &organization, result
+}
+
+// GetMembership returns the membership of the user with its organization.
+func (repo *OrganizationRepository) GetMembership(organizationID uuid.UUID, userID uuid.UUID) (*models.OrganizationMember, *gorm.DB) {
+	var member models.OrganizationMember
+
+	result := repo.db.Preload("Organization").Where(&models.OrganizationMember{OrganizationID: organizationID, UserID: userID}).First(&member)
+
+	return &member, result
+}
+
+func (repo *OrganizationRepository) GetUserMemberships(userID uuid.UUID) (*[]models.OrganizationMember, error) {
+	var members []models.OrganizationMember
+
+	result := repo.db.Preload("Organization").Where(&models.OrganizationMember{UserID: userID}).Order("created_at").Find(&members)
+
+	return &members, result.Error
+}
+
+func (repo *OrganizationRepository) GetOrganizationMembers(organizationID uuid.UUID) (*[]models.OrganizationMember, error) {
+	var members []models.OrganizationMember
+
+	result := repo.db.Preload("User").Where(&models.OrganizationMember{OrganizationID: organizationID}).Order("created_at").Find(&members)
+
+	return &members, result.Error
+}
+
+func (repo *OrganizationRepository) CountOwners(organizationID uuid.UUID) (int64, error) {
+	var count int64
+
+	result := repo.db.Model(&models.OrganizationMember{}).
+		Where(&models.OrganizationMember{OrganizationID: organizationID, Role: models.OrgRoleOwner}).
+		Count(&count)
+
+	return count, result.Error
+}
+
+func (repo *OrganizationRepository) UpdateMemberColumns(member *models.OrganizationMember, columns ...string) error {
+	result := repo.db.Model(member).Select(columns).Updates(member)
+
+	return result.Error
+}
+
+func (repo *OrganizationRepository) DeleteMember(member *models.OrganizationMember) error {
+	result := repo.db.Delete(member)
+
+	return result.Error
+}
+
+// CreateInvitation replaces any pending invitation of the same email address
+// to the organization.
+func (repo *OrganizationRepository) CreateInvitation(invitation *models.OrganizationInvitation) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("organization_id = ? AND lower(email) = lower(?) AND accepted_at IS NULL", invitation.OrganizationID, invitation.Email).
+			Delete(&models.OrganizationInvitation{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(invitation).Error
+	})
+}
+
+func (repo *OrganizationRepository) GetPendingInvitations(organizationID uuid.UUID) (*[]models.OrganizationInvitation, error) {
+	var invitations []models.OrganizationInvitation
+
+	result := repo.db.Where("organization_id = ? AND accepted_at IS NULL AND expires_at > ?", organizationID, time.Now()).
+		Order("created_at DESC").
+		Find(&invitations)
+
+	return &invitations, result.Error
+}
+
+func (repo *OrganizationRepository) GetInvitationByHash(tokenHash string) (*models.OrganizationInvitation, *gorm.DB) {
+	var invitation models.OrganizationInvitation
+
+	result := repo.db.Preload("Organization").Where(&models.OrganizationInvitation{TokenHash: tokenHash}).First(&invitation)
+
+	return &invitation, result
+}
+
+func (repo *OrganizationRepository) DeleteInvitation(id uuid.UUID, organizationID uuid.UUID) *gorm.DB {
+	result := repo.db.Where("id = ? AND organization_id = ? AND accepted_at IS NULL", id, organizationID).Delete(&models.OrganizationInvitation{})
+
+	return result
+}
+
+// AcceptInvitation marks the invitation accepted and adds the member. It
+// reports false if the invitation was accepted in the meantime.
+func (repo *OrganizationRepository) AcceptInvitation(invitation *models.OrganizationInvitation, member *models.OrganizationMember) (bool, error) {
+	accepted := false
+
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		result := tx.Model(invitation).Where("accepted_at IS NULL").Update("accepted_at", now)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		if err := tx.Create(member).Error; err != nil {
+			return err
+		}
+
+		invitation.AcceptedAt = &now
+		accepted = true
+
+		return nil
+	})
+
+	return accepted, err
+}
@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gwid.io/gwid-core/internal/models"
+)
+
+type RecoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+func NewRecoveryCodeRepository(db *gorm.DB) *RecoveryCodeRepository {
+	return &RecoveryCodeRepository{
+		db: db,
+	}
+}
+
+// ReplaceRecoveryCodes deletes every recovery code of the user and stores the
+// new ones.
+func (repo *RecoveryCodeRepository) ReplaceRecoveryCodes(userID uuid.UUID, recoveryCodes []models.RecoveryCode) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		if len(recoveryCodes) == 0 {
+			return nil
+		}
+
+		return tx.Create(&recoveryCodes).Error
+	})
+}
+
+// ConsumeRecoveryCode marks an unused code of the user as used and reports
+// false if there was none.
+func (repo *RecoveryCodeRepository) ConsumeRecoveryCode(userID uuid.UUID, codeHash string) (bool, error) {
+	result := repo.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+
+	return result.RowsAffected > 0, result.Error
+}
+
+func (repo *RecoveryCodeRepository) CountUnusedRecoveryCodes(userID uuid.UUID) (int64, error) {
+	var count int64
+
+	result := repo.db.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count)
+
+	return count, result.Error
+}
@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gwid.io/gwid-core/internal/models"
+)
+
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) *SessionRepository {
+	return &SessionRepository{
+		db: db,
+	}
+}
+
+func (repo *SessionRepository) CreateSession(session *models.Session) error {
+	result := repo.db.Create(session)
+
+	return result.Error
+}
+
+func (repo *SessionRepository) GetSessionByID(id uuid.UUID, userID uuid.UUID) (*models.Session, *gorm.DB) {
+	var session models.Session
+
+	result := repo.db.Where(&models.Session{ID: id, UserID: userID}).First(&session)
+
+	return &session, result
+}
+
+// GetSessionOfActiveUser returns the session only if its user is neither
+// suspended nor being deleted.
+func (repo *SessionRepository) GetSessionOfActiveUser(id uuid.UUID, userID uuid.UUID) (*models.Session, *gorm.DB) {
+	var session models.Session
+
+	result := repo.db.
+		Joins("JOIN users ON users.id = sessions.user_id").
+		Where("sessions.id = ? AND sessions.user_id = ?", id, userID).
+		Where("users.suspended_at IS NULL AND users.deletion_requested_at IS NULL").
+		First(&session)
+
+	return &session, result
+}
+
+// GetActiveUserSessions returns the sessions of the user that are neither
+// revoked nor expired, most recently used first.
+func (repo *SessionRepository) GetActiveUserSessions(userID uuid.UUID) (*[]models.Session, error) {
+	var sessions []models.Session
+
+	result := repo.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).Order("last_used_at desc").Find(&sessions)
+
+	return &sessions, result.Error
+}
+
+// RevokeUserSessions revokes every active session of the user except the one
+// with exceptID, which may be uuid.Nil.
+func (repo *SessionRepository) RevokeUserSessions(userID uuid.UUID, exceptID uuid.UUID, reason string) error {
+	result := repo.db.Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, exceptID).
+		Updates(map[string]any{
+			"revoked_at":     time.Now(),
+			"revoked_reason": reason,
+		})
+
+	return result.Error
+}
+
+func (repo *SessionRepository) UpdateSessionColumns(session *models.Session, columns ...string) error {
+	result := repo.db.Model(session).Select(columns).Updates(session)
+
+	return result.Error
+}
+
+// RevokeSession revokes the session unless it already is.
+func (repo *SessionRepository) RevokeSession(session *models.Session, reason string) error {
+	now := time.Now()
+
+	result := repo.db.Model(session).Where("revoked_at IS NULL").Updates(map[string]any{
+		"revoked_at":     now,
+		"revoked_reason": reason,
+	})
+
+	if result.Error == nil && result.RowsAffected > 0 {
+		session.RevokedAt = &now
+		session.RevokedReason = reason
+	}
+
+	return result.Error
+}
+
+func (repo *SessionRepository) CreateRefreshToken(refreshToken *models.RefreshToken) error {
+	result := repo.db.Create(refreshToken)
+
+	return result.Error
+}
+
+func (repo *SessionRepository) GetRefreshTokenByHash(tokenHash string) (*models.RefreshToken, *gorm.DB) {
+	var refreshToken models.RefreshToken
+
+	result := repo.db.Preload("Session").Where(&models.RefreshToken{TokenHash: tokenHash}).First(&refreshToken)
+
+	return &refreshToken, result
+}
+
+// MarkRefreshTokenUsed reports false if the token had already been used, which
+// includes losing a race against a concurrent refresh with the same token.
+func (repo *SessionRepository) MarkRefreshTokenUsed(refreshToken *models.RefreshToken) (bool, error) {
+	now := time.Now()
+
+	result := repo.db.Model(refreshToken).Where("used_at IS NULL").Update("used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	refreshToken.UsedAt = &now
+
+	return true, nil
+}
@@ -4,6 +4,7 @@ package repositories
 import (
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gwid.io/gwid-core/internal/middleware"
 	"gwid.io/gwid-core/internal/models"
 )
 
@@ -15,10 +16,12 @@ func NewUserRepository(db *gorm.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
+// CreateUser runs in a transaction so that the user is never left without the
+// personal organization created by its AfterCreate hook.
 func (repo *UserRepository) CreateUser(user *models.User) error {
-	result := repo.db.Create(&user)
-
-	return result.Error
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(user).Error
+	})
 }
 
 func (repo *UserRepository) FindByEmail(email string) (*models.User, *gorm.DB) {
@@ -50,3 +53,113 @@ func (repo *UserRepository) UpdateUser(user *models.User) *gorm.DB {
 
 	return result
 }
+
+func (repo *UserRepository) UpdateUserColumns(user *models.User, columns ...string) error {
+	result := repo.db.Model(user).Select(columns).Updates(user)
+
+	return result.Error
+}
+
+// AdvanceTOTPStep records step as the last accepted TOTP step and reports
+// false if it is not newer than the one recorded, i.e. the code was replayed.
+func (repo *UserRepository) AdvanceTOTPStep(user *models.User, step int64) (bool, error) {
+	result := repo.db.Model(user).Where("totp_last_step < ?", step).Update("totp_last_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	user.TOTPLastStep = step
+
+	return true, nil
+}
+
+func (repo *UserRepository) GetUsersWithTOTPNotEncryptedWith(keyID string, afterID uuid.UUID, limit int) (*[]models.User, error) {
+	var users []models.User
+
+	prefix := keyID + ":"
+
+	result := repo.db.
+		Where("totp_secret IS NOT NULL AND left(totp_secret, ?) <> ?", len(prefix), prefix).
+		Where("id > ?", afterID).
+		Order("id").
+		Limit(limit).
+		Find(&users)
+
+	return &users, result.Error
+}
+
+// ReplaceTOTPSecret saves the TOTP secret of the user unless it was changed
+// since previous was read.
+func (repo *UserRepository) ReplaceTOTPSecret(user *models.User, previous string) (bool, error) {
+	result := repo.db.Model(user).Where("totp_secret = ?", previous).Update("totp_secret", user.TOTPSecret)
+
+	return result.RowsAffected > 0, result.Error
+}
+
+// filterUsers applies the search and the role and status filters of the admin
+// user list.
+func filterUsers(params *middleware.QueryParams) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if params.Search != "" {
+			pattern := "%" + params.Search + "%"
+			db = db.Where("name ILIKE ? OR email ILIKE ?", pattern, pattern)
+		}
+
+		if role := params.Filters["role"]; role != "" {
+			db = db.Where("role = ?", role)
+		}
+
+		switch params.Filters["status"] {
+		case "active":
+			db = db.Where("suspended_at IS NULL")
+		case "suspended":
+			db = db.Where("suspended_at IS NOT NULL")
+		}
+
+		return db
+	}
+}
+
+func (repo *UserRepository) GetUsers(params *middleware.QueryParams) (*[]models.User, error) {
+	var users []models.User
+
+	result := repo.db.Scopes(filterUsers(params)).Offset(params.Offset).Limit(params.Limit).Order(params.Sort + " " + params.Order).Find(&users)
+
+	return &users, result.Error
+}
+
+func (repo *UserRepository) GetUsersCount(params *middleware.QueryParams) (int64, error) {
+	var count int64
+
+	result := repo.db.Model(&models.User{}).Scopes(filterUsers(params)).Count(&count)
+
+	return count, result.Error
+}
+
+// AnonymizeUser removes everything that identifies the user and their ways to
+// sign in, keeping the row so that references from shared organizations and
+// audit events stay valid.
+func (repo *UserRepository) AnonymizeUser(user *models.User) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []any{
+			&models.Session{},
+			&models.UserToken{},
+			&models.RecoveryCode{},
+			&models.UserIdentity{},
+			&models.APIKey{},
+			&models.OrganizationMember{},
+		} {
+			if err := tx.Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(user).Select(
+			"name", "email", "password", "aws_external_id", "email_verified_at", "totp_secret", "totp_enabled_at",
+		).Updates(user).Error
+	})
+}
@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gwid.io/gwid-core/internal/models"
+)
+
+type UserTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewUserTokenRepository(db *gorm.DB) *UserTokenRepository {
+	return &UserTokenRepository{
+		db: db,
+	}
+}
+
+func (repo *UserTokenRepository) CreateUserToken(userToken *models.UserToken) error {
+	result := repo.db.Create(userToken)
+
+	return result.Error
+}
+
+func (repo *UserTokenRepository) GetUserTokenByHash(tokenHash string, purpose models.UserTokenPurpose) (*models.UserToken, *gorm.DB) {
+	var userToken models.UserToken
+
+	result := repo.db.Where(&models.UserToken{TokenHash: tokenHash, Purpose: purpose}).First(&userToken)
+
+	return &userToken, result
+}
+
+// ConsumeUserToken marks the token used and reports false if it already was.
+func (repo *UserTokenRepository) ConsumeUserToken(userToken *models.UserToken) (bool, error) {
+	now := time.Now()
+
+	result := repo.db.Model(userToken).Where("used_at IS NULL").Update("used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	userToken.UsedAt = &now
+
+	return true, nil
+}
+
+// InvalidateUserTokens marks every unused token of the user for the purpose as
+// used.
+func (repo *UserTokenRepository) InvalidateUserTokens(userID uuid.UUID, purpose models.UserTokenPurpose) error {
+	result := repo.db.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now())
+
+	return result.Error
+}
@@ -10,26 +10,49 @@ import (
 	"github.com/dvwright/xss-mw"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 	"gwid.io/gwid-core/internal/config"
 	"gwid.io/gwid-core/internal/controllers"
 	"gwid.io/gwid-core/internal/middleware"
+	"gwid.io/gwid-core/internal/models"
 	"gwid.io/gwid-core/internal/types"
 )
 
+var (
+	// globalRateLimit only stops floods from a single address, the groups
+	// below have their own, tighter budgets.
+	globalRateLimit = middleware.RateLimitPolicy{Name: "global", Limit: 600, Period: time.Minute}
+	// authRateLimit counts per address, as most of these routes are used
+	// before signing in.
+	authRateLimit  = middleware.RateLimitPolicy{Name: "auth", Limit: 20, Period: time.Minute}
+	readRateLimit  = middleware.RateLimitPolicy{Name: "read", Limit: 300, Period: time.Minute}
+	writeRateLimit = middleware.RateLimitPolicy{Name: "write", Limit: 60, Period: time.Minute}
+)
+
 func NewRouter(
 	cfg *config.Config,
 	authController *controllers.AuthController,
+	oauthController *controllers.OAuthController,
 	userController *controllers.UserController,
 	gatewayController *controllers.GatewayController,
 	regionController *controllers.RegionController,
 	awsCredentialsController *controllers.AWSCredentialsController,
 	ec2Controller *controllers.EC2Controller,
+	apiKeyController *controllers.APIKeyController,
+	organizationController *controllers.OrganizationController,
+	adminController *controllers.AdminController,
+	sessionValidator middleware.SessionValidator,
+	emailVerificationChecker middleware.EmailVerificationChecker,
+	apiKeyValidator middleware.APIKeyValidator,
+	organizationResolver middleware.OrganizationResolver,
+	roleChecker middleware.RoleChecker,
+	redisClient *redis.Client,
 ) *gin.Engine {
 	router := gin.Default()
 
 	gin.SetMode(cfg.GinMode)
 
-	setupRouteConfig(router)
+	setupRouteConfig(router, redisClient)
 
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -39,42 +62,117 @@ func NewRouter(
 		})
 	})
 
+	authMiddleware := middleware.AuthMiddleware(sessionValidator, apiKeyValidator)
+	sessionOnlyMiddleware := middleware.SessionOnlyMiddleware()
+	verifiedEmailMiddleware := middleware.VerifiedEmailMiddleware(emailVerificationChecker)
+	organizationMiddleware := middleware.OrganizationMiddleware(organizationResolver)
+	apiRateLimitMiddleware := middleware.ReadWriteRateLimitMiddleware(redisClient, readRateLimit, writeRateLimit)
+
 	auth := router.Group("/api/v1/auth")
+	auth.Use(middleware.RateLimitMiddleware(redisClient, authRateLimit))
 	{
 		auth.POST("/signup", middleware.ValidateRequestMiddleware[types.SignupReq](), authController.SignUp)
 		auth.POST("/login", middleware.ValidateRequestMiddleware[types.LoginReq](), authController.Login)
-		auth.PATCH("/change-password", middleware.AuthMiddleware(), middleware.ValidateRequestMiddleware[types.ChangePasswordReq](), authController.ChangePassword)
+		auth.POST("/refresh", middleware.ValidateRequestMiddleware[types.RefreshTokenReq](), authController.Refresh)
+		auth.POST("/logout", authMiddleware, sessionOnlyMiddleware, authController.Logout)
+		auth.POST("/logout-all", authMiddleware, sessionOnlyMiddleware, authController.LogoutAll)
+		auth.POST("/forgot-password", middleware.ValidateRequestMiddleware[types.ForgotPasswordReq](), authController.ForgotPassword)
+		auth.POST("/reset-password", middleware.ValidateRequestMiddleware[types.ResetPasswordReq](), authController.ResetPassword)
+		auth.POST("/verify-email", middleware.ValidateRequestMiddleware[types.VerifyEmailReq](), authController.VerifyEmail)
+		auth.POST("/resend-verification", authMiddleware, sessionOnlyMiddleware, authController.ResendVerificationEmail)
+		auth.POST("/2fa/verify", middleware.ValidateRequestMiddleware[types.TwoFactorLoginReq](), authController.VerifyTwoFactorLogin)
+		auth.POST("/2fa/setup", authMiddleware, sessionOnlyMiddleware, authController.SetupTwoFactor)
+		auth.POST("/2fa/confirm", authMiddleware, sessionOnlyMiddleware, middleware.ValidateRequestMiddleware[types.TwoFactorCodeReq](), authController.ConfirmTwoFactor)
+		auth.POST("/2fa/disable", authMiddleware, sessionOnlyMiddleware, middleware.ValidateRequestMiddleware[types.DisableTwoFactorReq](), authController.DisableTwoFactor)
+		auth.POST("/2fa/recovery-codes", authMiddleware, sessionOnlyMiddleware, middleware.ValidateRequestMiddleware[types.TwoFactorCodeReq](), authController.RegenerateRecoveryCodes)
+		auth.GET("/oauth/providers", oauthController.GetProviders)
+		auth.GET("/oauth/:provider/authorize", oauthController.Authorize)
+		auth.POST("/oauth/:provider/callback", middleware.ValidateRequestMiddleware[types.OAuthCallbackReq](), oauthController.Callback)
+		auth.PATCH("/change-password", authMiddleware, sessionOnlyMiddleware, middleware.ValidateRequestMiddleware[types.ChangePasswordReq](), authController.ChangePassword)
 	}
 
 	user := router.Group("/api/v1/user")
-	user.Use(middleware.AuthMiddleware())
+	user.Use(authMiddleware, apiRateLimitMiddleware)
 	{
-		user.GET("/profile", userController.GetCurrentUserProfile)
-		user.PATCH("/profile", middleware.ValidateRequestMiddleware[types.UpdateProfileReq](), userController.UpdateUserProfile)
-		user.GET("/gateway", middleware.QueryMiddleware(), gatewayController.GetUserGateways)
+		user.GET("/profile", sessionOnlyMiddleware, userController.GetCurrentUserProfile)
+		user.DELETE("", sessionOnlyMiddleware, middleware.ValidateRequestMiddleware[types.DeleteAccountReq](), userController.DeleteAccount)
+		user.PATCH("/profile", sessionOnlyMiddleware, middleware.ValidateRequestMiddleware[types.UpdateProfileReq](), userController.UpdateUserProfile)
+		user.GET("/gateway", middleware.RequireScope(models.ScopeGatewaysRead), organizationMiddleware, middleware.QueryMiddleware(), gatewayController.GetOrganizationGateways)
+		user.GET("/sessions", sessionOnlyMiddleware, userController.GetUserSessions)
+		user.DELETE("/sessions/:id", sessionOnlyMiddleware, userController.DeleteUserSession)
+		user.POST("/api-keys", sessionOnlyMiddleware, middleware.ValidateRequestMiddleware[types.CreateAPIKeyReq](), apiKeyController.CreateAPIKey)
+		user.GET("/api-keys", sessionOnlyMiddleware, apiKeyController.GetUserAPIKeys)
+		user.DELETE("/api-keys/:id", sessionOnlyMiddleware, apiKeyController.DeleteAPIKey)
+		user.GET("/audit", sessionOnlyMiddleware, middleware.QueryMiddleware(), userController.GetUserAuditEvents)
 	}
 
+	// The ID of an account deletion is only known to the user who requested
+	// it, who is signed out by then.
+	router.GET("/api/v1/account-deletions/:id", apiRateLimitMiddleware, userController.GetAccountDeletion)
+
 	gateway := router.Group("/api/v1/gateway")
-	gateway.Use(middleware.AuthMiddleware())
+	gateway.Use(authMiddleware, apiRateLimitMiddleware, middleware.RequireScope(models.ScopeGatewaysWrite), organizationMiddleware, middleware.RequireOrgRole(models.OrgRoleMember))
 	{
-		gateway.POST("/aws", middleware.ValidateRequestMiddleware[types.CreateGatewayWithAWSReq](), gatewayController.CreateAWSGateway)
+		gateway.POST("", verifiedEmailMiddleware, middleware.ValidateRequestMiddleware[types.CreateGatewayReq](), gatewayController.CreateGateway)
+		gateway.POST("/aws", verifiedEmailMiddleware, middleware.ValidateRequestMiddleware[types.CreateGatewayWithAWSReq](), gatewayController.CreateAWSGateway)
+		gateway.DELETE("/:id", gatewayController.DeleteGateway)
+		gateway.POST("/:id/stop", gatewayController.StopGateway)
+		gateway.POST("/:id/start", gatewayController.StartGateway)
+		gateway.POST("/:id/reboot", gatewayController.RebootGateway)
 	}
 
 	region := router.Group("/api/v1/region")
-	region.Use(middleware.AuthMiddleware())
+	region.Use(authMiddleware, apiRateLimitMiddleware, middleware.RequireScope(models.ScopeGatewaysRead), organizationMiddleware)
 	{
 		region.GET("/aws", middleware.QueryMiddleware(), regionController.GetAWSRegions)
 	}
 
 	awsCredentials := router.Group("/api/v1/aws-credentials")
-	awsCredentials.Use(middleware.AuthMiddleware())
+	awsCredentials.Use(authMiddleware, apiRateLimitMiddleware, organizationMiddleware)
 	{
-		awsCredentials.POST("", middleware.ValidateRequestMiddleware[types.AWSCredentialsReq](), awsCredentialsController.CreateAWSCredentials)
-		awsCredentials.GET("", middleware.QueryMiddleware(), awsCredentialsController.GetUserAWSCredentials)
+		awsCredentials.POST("", middleware.RequireScope(models.ScopeCredentialsWrite), middleware.RequireOrgRole(models.OrgRoleAdmin), verifiedEmailMiddleware, middleware.ValidateRequestMiddleware[types.AWSCredentialsReq](), awsCredentialsController.CreateAWSCredentials)
+		awsCredentials.GET("", middleware.RequireScope(models.ScopeCredentialsRead), middleware.QueryMiddleware(), awsCredentialsController.GetOrganizationAWSCredentials)
+		awsCredentials.GET("/external-id", middleware.RequireScope(models.ScopeCredentialsRead), awsCredentialsController.GetAWSExternalID)
+		awsCredentials.PUT("/:id", middleware.RequireScope(models.ScopeCredentialsWrite), middleware.RequireOrgRole(models.OrgRoleAdmin), middleware.ValidateRequestMiddleware[types.UpdateAWSCredentialsReq](), awsCredentialsController.UpdateAWSCredentials)
+		awsCredentials.DELETE("/:id", middleware.RequireScope(models.ScopeCredentialsWrite), middleware.RequireOrgRole(models.OrgRoleAdmin), awsCredentialsController.DeleteAWSCredentials)
+	}
+
+	organizations := router.Group("/api/v1/organizations")
+	organizations.Use(authMiddleware, apiRateLimitMiddleware, sessionOnlyMiddleware)
+	{
+		organizations.POST("", middleware.ValidateRequestMiddleware[types.CreateOrganizationReq](), organizationController.CreateOrganization)
+		organizations.GET("", organizationController.GetUserOrganizations)
+		organizations.POST("/invitations/accept", middleware.ValidateRequestMiddleware[types.AcceptInvitationReq](), organizationController.AcceptInvitation)
+		organizations.GET("/:id", organizationController.GetOrganization)
+		organizations.PATCH("/:id", middleware.ValidateRequestMiddleware[types.UpdateOrganizationReq](), organizationController.UpdateOrganization)
+		organizations.DELETE("/:id", organizationController.DeleteOrganization)
+		organizations.GET("/:id/members", organizationController.GetOrganizationMembers)
+		organizations.PATCH("/:id/members/:userId", middleware.ValidateRequestMiddleware[types.UpdateMemberRoleReq](), organizationController.UpdateMemberRole)
+		organizations.DELETE("/:id/members/:userId", organizationController.RemoveMember)
+		organizations.POST("/:id/invitations", middleware.ValidateRequestMiddleware[types.InviteMemberReq](), organizationController.InviteMember)
+		organizations.GET("/:id/invitations", organizationController.GetPendingInvitations)
+		organizations.DELETE("/:id/invitations/:invitationId", organizationController.RevokeInvitation)
+	}
+
+	admin := router.Group("/api/v1/admin")
+	admin.Use(authMiddleware, apiRateLimitMiddleware, sessionOnlyMiddleware, middleware.RequireRole(roleChecker, models.Admin))
+	{
+		admin.GET("/users", middleware.QueryMiddleware(), adminController.GetUsers)
+		admin.GET("/users/:id", adminController.GetUser)
+		admin.POST("/users/:id/suspend", middleware.ValidateRequestMiddleware[types.SuspendUserReq](), adminController.SuspendUser)
+		admin.POST("/users/:id/unsuspend", adminController.UnsuspendUser)
+		admin.PATCH("/users/:id/role", middleware.ValidateRequestMiddleware[types.UpdateUserRoleReq](), adminController.UpdateUserRole)
+		admin.GET("/users/:id/lockout", adminController.GetUserLockout)
+		admin.DELETE("/users/:id/lockout", adminController.ClearUserLockout)
+		admin.GET("/gateways", middleware.QueryMiddleware(), adminController.GetGateways)
+		admin.POST("/gateways/:id/terminate", adminController.ForceTerminateGateway)
+		admin.GET("/queues", adminController.GetQueueHealth)
+		admin.GET("/audit", middleware.QueryMiddleware(), adminController.GetAuditEvents)
+		admin.GET("/audit/export", middleware.QueryMiddleware(), adminController.ExportAuditEvents)
 	}
 
 	ec2 := router.Group("/api/v1/ec2")
-	ec2.Use(middleware.AuthMiddleware())
+	ec2.Use(authMiddleware, apiRateLimitMiddleware, middleware.RequireScope(models.ScopeGatewaysRead))
 	{
 		ec2.GET("", middleware.QueryMiddleware(), ec2Controller.GetEC2InstanceTypes)
 	}
@@ -82,18 +180,20 @@ func NewRouter(
 	return router
 }
 
-func setupRouteConfig(router *gin.Engine) {
+func setupRouteConfig(router *gin.Engine, redisClient *redis.Client) {
 	err := router.SetTrustedProxies([]string{"127.0.0.1", "::1"})
 	if err != nil {
 		log.Fatalln("router not initiated")
 	}
 
+	router.Use(middleware.RequestIDMiddleware())
+
 	originRegex := regexp.MustCompile(`^https?:\/\/(localhost(:\d+)?|([a-zA-Z0-9-]+\.)?gwid\.io)$`)
 
 	router.Use(cors.New(cors.Config{
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length", "Content-Type"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Organization-ID", middleware.RequestIDHeader},
+		ExposeHeaders:    []string{"Content-Length", "Content-Type", "Content-Disposition", middleware.RequestIDHeader, "RateLimit-Policy", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
 		AllowCredentials: true,
 		AllowOriginFunc: func(origin string) bool {
 			return originRegex.MatchString(origin)
@@ -105,7 +205,7 @@ func setupRouteConfig(router *gin.Engine) {
 	// router.RedirectFixedPath = false
 	// router.RedirectTrailingSlash = false
 
-	router.Use(middleware.RateLimitMiddleware())
+	router.Use(middleware.RateLimitMiddleware(redisClient, globalRateLimit))
 
 	var xssMdlwr xss.XssMw
 	router.Use(xssMdlwr.RemoveXss())
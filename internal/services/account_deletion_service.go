@@ -0,0 +1,333 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"gwid.io/gwid-core/internal/config"
+	"gwid.io/gwid-core/internal/models"
+	"gwid.io/gwid-core/internal/repositories"
+	"gwid.io/gwid-core/internal/types"
+	"gwid.io/gwid-core/internal/utils"
+)
+
+const (
+	// accountDeletionPollInterval is how often the deletion task checks on
+	// gateways it asked to terminate.
+	accountDeletionPollInterval = 30 * time.Second
+	// gatewayTeardownTimeout bounds how long a deletion waits for gateways.
+	gatewayTeardownTimeout = 6 * time.Hour
+)
+
+// AccountDeletionService deletes accounts in the background: it terminates the
+// gateways of the personal organization, removes its credentials with their
+// IAM roles and then anonymizes the user.
+type AccountDeletionService struct {
+	cfg                       *config.Config
+	userRepository            *repositories.UserRepository
+	organizationRepository    *repositories.OrganizationRepository
+	gatewayRepository         *repositories.GatewayRepository
+	awsCredentialsRepository  *repositories.AWSCredentialsRepository
+	sessionRepository         *repositories.SessionRepository
+	accountDeletionRepository *repositories.AccountDeletionRepository
+	gatewayService            *GatewayService
+	awsCredentialsService     *AWSCredentialsService
+}
+
+func NewAccountDeletionService(
+	cfg *config.Config,
+	userRepository *repositories.UserRepository,
+	organizationRepository *repositories.OrganizationRepository,
+	gatewayRepository *repositories.GatewayRepository,
+	awsCredentialsRepository *repositories.AWSCredentialsRepository,
+	sessionRepository *repositories.SessionRepository,
+	accountDeletionRepository *repositories.AccountDeletionRepository,
+	gatewayService *GatewayService,
+	awsCredentialsService *AWSCredentialsService,
+) *AccountDeletionService {
+	return &AccountDeletionService{
+		cfg:                       cfg,
+		userRepository:            userRepository,
+		organizationRepository:    organizationRepository,
+		gatewayRepository:         gatewayRepository,
+		awsCredentialsRepository:  awsCredentialsRepository,
+		sessionRepository:         sessionRepository,
+		accountDeletionRepository: accountDeletionRepository,
+		gatewayService:            gatewayService,
+		awsCredentialsService:     awsCredentialsService,
+	}
+}
+
+func (s *AccountDeletionService) getAsynqClient() *asynq.Client {
+	client := asynq.NewClient(asynq.RedisClientOpt{Addr: s.cfg.RedisAddress, Password: s.cfg.RedisPassword})
+
+	return client
+}
+
+func (s *AccountDeletionService) enqueueDeleteAccountTask(payload types.DeleteAccountPayload, opts ...asynq.Option) error {
+	payloadJson, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	client := s.getAsynqClient()
+
+	defer client.Close()
+
+	opts = append([]asynq.Option{asynq.MaxRetry(5), asynq.Timeout(30 * time.Minute)}, opts...)
+
+	_, err = client.Enqueue(asynq.NewTask(utils.TypeDeleteAccount, payloadJson, opts...))
+
+	return err
+}
+
+// checkDeletionPassword requires the password of every user. Users who signed
+// up through an OAuth provider have to set one with the password reset flow
+// first, so that a stolen access token alone cannot delete an account.
+func checkDeletionPassword(user *models.User, deleteAccountReq types.DeleteAccountReq) (int, error) {
+	if err := user.CheckPassword(deleteAccountReq.Password); err != nil {
+		if !user.HasPassword() {
+			return http.StatusForbidden, errors.New("set a password with the password reset flow before deleting your account")
+		}
+
+		return http.StatusBadRequest, errors.New("invalid password")
+	}
+
+	return http.StatusOK, nil
+}
+
+// RequestAccountDeletion re-authenticates the user, locks them out and queues
+// the deletion. Users who are the last owner of a shared organization have to
+// hand it over or delete it first.
+func (s *AccountDeletionService) RequestAccountDeletion(userID uuid.UUID, deleteAccountReq types.DeleteAccountReq) (*types.AccountDeletionRes, int, error) {
+	user, result := s.userRepository.FindByID(userID)
+	if result.RowsAffected == 0 {
+		return nil, http.StatusNotFound, errors.New("user not found")
+	}
+
+	if user.IsDeletionRequested() {
+		return nil, http.StatusConflict, errors.New("account deletion has already been requested")
+	}
+
+	if statusCode, err := checkDeletionPassword(user, deleteAccountReq); err != nil {
+		return nil, statusCode, err
+	}
+
+	memberships, err := s.organizationRepository.GetUserMemberships(user.ID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	for _, membership := range *memberships {
+		if membership.Organization.IsPersonal() || membership.Role != models.OrgRoleOwner {
+			continue
+		}
+
+		owners, err := s.organizationRepository.CountOwners(membership.OrganizationID)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+
+		if owners <= 1 {
+			return nil, http.StatusConflict, fmt.Errorf("you are the last owner of %s, transfer ownership or delete it first", membership.Organization.Name)
+		}
+	}
+
+	now := time.Now()
+
+	user.DeletionRequestedAt = &now
+
+	deletion := models.AccountDeletion{
+		UserID: user.ID,
+		Status: models.AccountDeletionPending,
+	}
+
+	if err := s.accountDeletionRepository.CreateAccountDeletion(&deletion, user); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	if err := s.enqueueDeleteAccountTask(types.DeleteAccountPayload{AccountDeletionID: deletion.ID}); err != nil {
+		log.Printf("unable to queue deletion %s of user %s: %v", deletion.ID, user.ID, err)
+
+		user.DeletionRequestedAt = nil
+
+		if err := s.userRepository.UpdateUserColumns(user, "deletion_requested_at"); err != nil {
+			log.Printf("unable to unlock user %s: %v", user.ID, err)
+		}
+
+		deletion.Status = models.AccountDeletionFailed
+		deletion.Error = "unable to queue deletion"
+
+		if err := s.accountDeletionRepository.UpdateAccountDeletionColumns(&deletion, "status", "error"); err != nil {
+			log.Printf("unable to record failure of deletion %s: %v", deletion.ID, err)
+		}
+
+		return nil, http.StatusInternalServerError, errors.New("unable to queue account deletion")
+	}
+
+	if err := s.sessionRepository.RevokeUserSessions(user.ID, uuid.Nil, "account deleted"); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return types.NewAccountDeletionRes(&deletion), http.StatusAccepted, nil
+}
+
+func (s *AccountDeletionService) GetAccountDeletion(id uuid.UUID) (*types.AccountDeletionRes, int, error) {
+	deletion, result := s.accountDeletionRepository.GetAccountDeletionByID(id)
+	if result.RowsAffected == 0 {
+		return nil, http.StatusNotFound, errors.New("account deletion not found")
+	}
+
+	return types.NewAccountDeletionRes(deletion), http.StatusOK, nil
+}
+
+func (s *AccountDeletionService) HandleDeleteAccountTask(ctx context.Context, task *asynq.Task) error {
+	var payload types.DeleteAccountPayload
+
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("json.Unmarsal failed: %v: %w", err, asynq.SkipRetry)
+	}
+
+	deletion, result := s.accountDeletionRepository.GetAccountDeletionByID(payload.AccountDeletionID)
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("account deletion %s not found: %w", payload.AccountDeletionID, asynq.SkipRetry)
+	}
+
+	if deletion.IsFinished() {
+		return nil
+	}
+
+	user, result := s.userRepository.FindByID(deletion.UserID)
+	if result.RowsAffected == 0 {
+		return s.recordDeletionFailure(ctx, deletion, fmt.Errorf("user %s not found: %w", deletion.UserID, asynq.SkipRetry))
+	}
+
+	done, err := s.deleteAccount(deletion, user)
+	if err != nil {
+		return s.recordDeletionFailure(ctx, deletion, err)
+	}
+
+	if !done {
+		// Gateways terminate in their own tasks, check on them again later.
+		return s.enqueueDeleteAccountTask(payload, asynq.ProcessIn(accountDeletionPollInterval))
+	}
+
+	log.Printf("account of user %s deleted", user.ID)
+
+	return nil
+}
+
+// deleteAccount runs the next step of the deletion. It reports false while
+// gateways are still being terminated.
+func (s *AccountDeletionService) deleteAccount(deletion *models.AccountDeletion, user *models.User) (bool, error) {
+	organization, result := s.organizationRepository.GetPersonalOrganization(user.ID)
+	if result.RowsAffected > 0 {
+		gateways, err := s.gatewayRepository.GetAllOrganizationGateways(organization.ID)
+		if err != nil {
+			return false, err
+		}
+
+		if len(*gateways) > 0 {
+			if time.Since(deletion.CreatedAt) > gatewayTeardownTimeout {
+				return false, fmt.Errorf("gateways were not terminated within %s: %w", gatewayTeardownTimeout, asynq.SkipRetry)
+			}
+
+			if err := s.setDeletionStatus(deletion, models.AccountDeletionTerminatingGateways); err != nil {
+				return false, err
+			}
+
+			if err := s.gatewayService.terminateGateways(*gateways); err != nil {
+				return false, err
+			}
+
+			return false, nil
+		}
+
+		if err := s.setDeletionStatus(deletion, models.AccountDeletionRemovingCredentials); err != nil {
+			return false, err
+		}
+
+		credentials, err := s.awsCredentialsRepository.GetAllOrganizationCredentials(organization.ID)
+		if err != nil {
+			return false, err
+		}
+
+		for _, credential := range *credentials {
+			if _, err := s.awsCredentialsService.removeAWSCredentials(credential.ID, organization.ID, true); err != nil {
+				return false, fmt.Errorf("unable to delete credentials %s: %w", credential.ID, err)
+			}
+		}
+
+		if err := s.organizationRepository.DeleteOrganization(organization); err != nil {
+			return false, err
+		}
+	}
+
+	password, err := generateToken()
+	if err != nil {
+		return false, err
+	}
+
+	if err := user.HashPassword(password); err != nil {
+		return false, err
+	}
+
+	user.Name = "Deleted user"
+	user.Email = fmt.Sprintf("deleted-%s@users.gwid.invalid", user.ID)
+	user.AWSExternalID = nil
+	user.EmailVerifiedAt = nil
+	user.TOTPSecret = nil
+	user.TOTPEnabledAt = nil
+
+	if err := s.userRepository.AnonymizeUser(user); err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+
+	deletion.Status = models.AccountDeletionCompleted
+	deletion.Error = ""
+	deletion.CompletedAt = &now
+
+	if err := s.accountDeletionRepository.UpdateAccountDeletionColumns(deletion, "status", "error", "completed_at"); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *AccountDeletionService) setDeletionStatus(deletion *models.AccountDeletion, status models.AccountDeletionStatus) error {
+	if deletion.Status == status {
+		return nil
+	}
+
+	deletion.Status = status
+
+	return s.accountDeletionRepository.UpdateAccountDeletionColumns(deletion, "status")
+}
+
+// recordDeletionFailure stores the error on the deletion and marks it failed
+// once asynq has no retries left. The user stays locked out.
+func (s *AccountDeletionService) recordDeletionFailure(ctx context.Context, deletion *models.AccountDeletion, taskErr error) error {
+	deletion.Error = taskErr.Error()
+
+	retried, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+
+	if retried >= maxRetry || errors.Is(taskErr, asynq.SkipRetry) {
+		deletion.Status = models.AccountDeletionFailed
+	}
+
+	if err := s.accountDeletionRepository.UpdateAccountDeletionColumns(deletion, "status", "error"); err != nil {
+		log.Printf("unable to record failure of account deletion %s: %v", deletion.ID, err)
+	}
+
+	return taskErr
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+	"gwid.io/gwid-core/internal/models"
+	"gwid.io/gwid-core/internal/types"
+)
+
+func TestDeleteAccountReqRequiresPassword(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "empty body", body: `{}`},
+		{name: "empty password", body: `{"password":""}`},
+		{name: "two-factor code only", body: `{"code":"123456"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deleteAccountReq types.DeleteAccountReq
+
+			if err := binding.JSON.BindBody([]byte(tt.body), &deleteAccountReq); err == nil {
+				t.Fatalf("BindBody(%s) error = nil, want a validation error", tt.body)
+			}
+		})
+	}
+}
+
+func TestCheckDeletionPassword(t *testing.T) {
+	user := &models.User{}
+	if err := user.HashPassword("correct horse battery staple"); err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+
+	passwordless := &models.User{PasswordUnset: true}
+	if err := passwordless.HashPassword("random password nobody knows"); err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		user     *models.User
+		password string
+		want     int
+	}{
+		{name: "correct password", user: user, password: "correct horse battery staple", want: http.StatusOK},
+		{name: "no password", user: user, password: "", want: http.StatusBadRequest},
+		{name: "two-factor code as password", user: user, password: "123456", want: http.StatusBadRequest},
+		{name: "wrong password", user: user, password: "Tr0ub4dor&3", want: http.StatusBadRequest},
+		{name: "user without a password", user: passwordless, password: "", want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statusCode, err := checkDeletionPassword(tt.user, types.DeleteAccountReq{Password: tt.password})
+			if statusCode != tt.want {
+				t.Fatalf("checkDeletionPassword() status = %d, want %d", statusCode, tt.want)
+			}
+
+			if (err == nil) != (tt.want == http.StatusOK) {
+				t.Fatalf("checkDeletionPassword() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"gwid.io/gwid-core/internal/config"
+	"gwid.io/gwid-core/internal/middleware"
+	"gwid.io/gwid-core/internal/models"
+	"gwid.io/gwid-core/internal/repositories"
+	"gwid.io/gwid-core/internal/types"
+)
+
+var (
+	adminUserSorts    = []string{"created_at", "updated_at", "name", "email", "role", "suspended_at"}
+	adminGatewaySorts = []string{"created_at", "updated_at", "gateway_name", "status", "provider", "region"}
+)
+
+// AdminService backs the platform-wide admin API. Every change it makes is
+// recorded with AuditService.
+type AdminService struct {
+	cfg                  *config.Config
+	userRepository       *repositories.UserRepository
+	gatewayRepository    *repositories.GatewayRepository
+	sessionRepository    *repositories.SessionRepository
+	gatewayService       *GatewayService
+	auditService         *AuditService
+	loginThrottleService *LoginThrottleService
+}
+
+func NewAdminService(
+	cfg *config.Config,
+	userRepository *repositories.UserRepository,
+	gatewayRepository *repositories.GatewayRepository,
+	sessionRepository *repositories.SessionRepository,
+	gatewayService *GatewayService,
+	auditService *AuditService,
+	loginThrottleService *LoginThrottleService,
+) *AdminService {
+	return &AdminService{
+		cfg:                  cfg,
+		userRepository:       userRepository,
+		gatewayRepository:    gatewayRepository,
+		sessionRepository:    sessionRepository,
+		gatewayService:       gatewayService,
+		auditService:         auditService,
+		loginThrottleService: loginThrottleService,
+	}
+}
+
+// sanitizeSort falls back to sorting by creation time unless the requested
+// column is allowed, as the sort ends up in the ORDER BY clause.
+func sanitizeSort(params *middleware.QueryParams, allowed []string) {
+	if !slices.Contains(allowed, params.Sort) {
+		params.Sort = "created_at"
+	}
+}
+
+func (s *AdminService) GetUsers(params *middleware.QueryParams) (*[]models.User, int, error) {
+	sanitizeSort(params, adminUserSorts)
+
+	users, err := s.userRepository.GetUsers(params)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return users, http.StatusOK, nil
+}
+
+func (s *AdminService) GetUsersCount(params *middleware.QueryParams) (int64, error) {
+	return s.userRepository.GetUsersCount(params)
+}
+
+func (s *AdminService) GetUser(userID uuid.UUID) (*models.User, int, error) {
+	user, result := s.userRepository.FindByID(userID)
+	if result.RowsAffected == 0 {
+		return nil, http.StatusNotFound, errors.New("user not found")
+	}
+
+	return user, http.StatusOK, nil
+}
+
+// SuspendUser locks a user out and signs them out everywhere.
+func (s *AdminService) SuspendUser(userID uuid.UUID, suspendUserReq types.SuspendUserReq, actorID uuid.UUID, meta types.SessionMeta) (*models.User, int, error) {
+	if userID == actorID {
+		return nil, http.StatusBadRequest, errors.New("you cannot suspend yourself")
+	}
+
+	user, statusCode, err := s.GetUser(userID)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	if user.IsSuspended() {
+		return nil, http.StatusConflict, errors.New("user is already suspended")
+	}
+
+	now := time.Now()
+
+	user.SuspendedAt = &now
+	user.SuspensionReason = suspendUserReq.Reason
+
+	if err := s.userRepository.UpdateUserColumns(user, "suspended_at", "suspension_reason"); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	if err := s.sessionRepository.RevokeUserSessions(user.ID, uuid.Nil, "account suspended"); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	s.auditService.Record(actorID, models.AuditAdminUserSuspended, models.AuditTargetUser, user.ID.String(), map[string]any{
+		"reason": suspendUserReq.Reason,
+	}, meta)
+
+	return user, http.StatusOK, nil
+}
+
+func (s *AdminService) UnsuspendUser(userID uuid.UUID, actorID uuid.UUID, meta types.SessionMeta) (*models.User, int, error) {
+	user, statusCode, err := s.GetUser(userID)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	if !user.IsSuspended() {
+		return nil, http.StatusConflict, errors.New("user is not suspended")
+	}
+
+	user.SuspendedAt = nil
+	user.SuspensionReason = ""
+
+	if err := s.userRepository.UpdateUserColumns(user, "suspended_at", "suspension_reason"); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	s.auditService.Record(actorID, models.AuditAdminUserUnsuspended, models.AuditTargetUser, user.ID.String(), nil, meta)
+
+	return user, http.StatusOK, nil
+}
+
+// UpdateUserRole promotes or demotes a user. Admins cannot change their own
+// role, so there is always an admin left to undo a change.
+func (s *AdminService) UpdateUserRole(userID uuid.UUID, updateUserRoleReq types.UpdateUserRoleReq, actorID uuid.UUID, meta types.SessionMeta) (*models.User, int, error) {
+	if userID == actorID {
+		return nil, http.StatusBadRequest, errors.New("you cannot change your own role")
+	}
+
+	user, statusCode, err := s.GetUser(userID)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	role := models.UserRole(updateUserRoleReq.Role)
+
+	if user.Role == role {
+		return user, http.StatusOK, nil
+	}
+
+	previousRole := user.Role
+	user.Role = role
+
+	if err := s.userRepository.UpdateUserColumns(user, "role"); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	s.auditService.Record(actorID, models.AuditAdminUserRoleChanged, models.AuditTargetUser, user.ID.String(), map[string]any{
+		"from": previousRole,
+		"to":   role,
+	}, meta)
+
+	return user, http.StatusOK, nil
+}
+
+func (s *AdminService) GetUserLockout(userID uuid.UUID) (*types.LoginLockoutRes, int, error) {
+	user, statusCode, err := s.GetUser(userID)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	lockout, err := s.loginThrottleService.GetAccountLockout(user.Email)
+	if err != nil {
+		return nil, http.StatusServiceUnavailable, errors.New("unable to read login attempts")
+	}
+
+	return lockout, http.StatusOK, nil
+}
+
+// ClearUserLockout lets a user who was locked out after failed logins sign in
+// again right away.
+func (s *AdminService) ClearUserLockout(userID uuid.UUID, actorID uuid.UUID, meta types.SessionMeta) (int, error) {
+	user, statusCode, err := s.GetUser(userID)
+	if err != nil {
+		return statusCode, err
+	}
+
+	if err := s.loginThrottleService.ClearAccountLockout(user.Email); err != nil {
+		return http.StatusServiceUnavailable, errors.New("unable to clear login attempts")
+	}
+
+	s.auditService.Record(actorID, models.AuditAdminUserUnlocked, models.AuditTargetUser, user.ID.String(), nil, meta)
+
+	return http.StatusOK, nil
+}
+
+func (s *AdminService) GetGateways(params *middleware.QueryParams) (*[]models.Gateway, int, error) {
+	sanitizeSort(params, adminGatewaySorts)
+
+	for _, filter := range []string{"organization_id", "user_id"} {
+		if value := params.Filters[filter]; value != "" {
+			if _, err := uuid.Parse(value); err != nil {
+				return nil, http.StatusBadRequest, errors.New("invalid " + filter + " filter")
+			}
+		}
+	}
+
+	gateways, err := s.gatewayRepository.GetGateways(params)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return gateways, http.StatusOK, nil
+}
+
+func (s *AdminService) GetGatewaysCount(params *middleware.QueryParams) (int64, error) {
+	return s.gatewayRepository.GetGatewaysCount(params)
+}
+
+func (s *AdminService) ForceTerminateGateway(gatewayID uuid.UUID, actorID uuid.UUID, meta types.SessionMeta) (*models.Gateway, int, error) {
+	gateway, statusCode, err := s.gatewayService.ForceTerminateGateway(gatewayID)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	s.auditService.Record(actorID, models.AuditAdminGatewayTerminated, models.AuditTargetGateway, gateway.ID.String(), map[string]any{
+		"gateway_name":    gateway.GatewayName,
+		"organization_id": gateway.OrganizationID,
+	}, meta)
+
+	return gateway, statusCode, nil
+}
+
+// GetQueueHealth reports the size of every asynq queue and the workers
+// serving them.
+func (s *AdminService) GetQueueHealth() (*types.QueueHealthRes, int, error) {
+	inspector := asynq.NewInspector(asynq.RedisClientOpt{Addr: s.cfg.RedisAddress, Password: s.cfg.RedisPassword})
+
+	defer inspector.Close()
+
+	queueNames, err := inspector.Queues()
+	if err != nil {
+		return nil, http.StatusServiceUnavailable, errors.New("unable to reach the task queue")
+	}
+
+	res := &types.QueueHealthRes{
+		Queues:  make([]*types.QueueRes, 0, len(queueNames)),
+		Servers: []*types.QueueServerRes{},
+	}
+
+	for _, queueName := range queueNames {
+		info, err := inspector.GetQueueInfo(queueName)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+
+		res.Queues = append(res.Queues, &types.QueueRes{
+			Queue:          info.Queue,
+			Size:           info.Size,
+			Pending:        info.Pending,
+			Active:         info.Active,
+			Scheduled:      info.Scheduled,
+			Retry:          info.Retry,
+			Archived:       info.Archived,
+			Completed:      info.Completed,
+			Processed:      info.Processed,
+			Failed:         info.Failed,
+			LatencySeconds: info.Latency.Seconds(),
+			Paused:         info.Paused,
+		})
+	}
+
+	servers, err := inspector.Servers()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	for _, server := range servers {
+		res.Servers = append(res.Servers, &types.QueueServerRes{
+			ID:            server.ID,
+			Host:          server.Host,
+			PID:           server.PID,
+			Status:        server.Status,
+			Concurrency:   server.Concurrency,
+			Queues:        server.Queues,
+			ActiveWorkers: len(server.ActiveWorkers),
+			Started:       server.Started,
+		})
+	}
+
+	return res, http.StatusOK, nil
+}
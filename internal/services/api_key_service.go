@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gwid.io/gwid-core/internal/models"
+	"gwid.io/gwid-core/internal/repositories"
+	"gwid.io/gwid-core/internal/types"
+)
+
+const (
+	maxAPIKeysPerUser = 25
+	// apiKeyTouchInterval is how stale last_used_at may get for a key in use.
+	apiKeyTouchInterval = time.Minute
+)
+
+var ErrInvalidAPIKey = errors.New("invalid or expired API key")
+
+type APIKeyService struct {
+	apiKeyRepository *repositories.APIKeyRepository
+}
+
+func NewAPIKeyService(apiKeyRepository *repositories.APIKeyRepository) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepository: apiKeyRepository,
+	}
+}
+
+func (s *APIKeyService) CreateAPIKey(createAPIKeyReq types.CreateAPIKeyReq, userID uuid.UUID) (*types.CreatedAPIKeyRes, int, error) {
+	if createAPIKeyReq.ExpiresAt != nil && !createAPIKeyReq.ExpiresAt.After(time.Now()) {
+		return nil, http.StatusBadRequest, errors.New("expires_at must be in the future")
+	}
+
+	count, err := s.apiKeyRepository.CountUserAPIKeys(userID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	if count >= maxAPIKeysPerUser {
+		return nil, http.StatusConflict, fmt.Errorf("a user can have at most %d API keys", maxAPIKeysPerUser)
+	}
+
+	secret, err := generateToken()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	key := models.APIKeyPrefix + secret
+
+	scopes := slices.Clone(createAPIKeyReq.Scopes)
+	slices.Sort(scopes)
+
+	apiKey := models.APIKey{
+		UserID:        userID,
+		Name:          strings.TrimSpace(createAPIKeyReq.Name),
+		DisplayPrefix: key[:len(models.APIKeyPrefix)+6],
+		KeyHash:       hashToken(key),
+		Scopes:        slices.Compact(scopes),
+		ExpiresAt:     createAPIKeyReq.ExpiresAt,
+	}
+
+	if err := s.apiKeyRepository.CreateAPIKey(&apiKey); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return &types.CreatedAPIKeyRes{
+		APIKeyRes: types.NewAPIKeyRes(&apiKey),
+		Key:       key,
+	}, http.StatusCreated, nil
+}
+
+func (s *APIKeyService) GetUserAPIKeys(userID uuid.UUID) ([]*types.APIKeyRes, int, error) {
+	apiKeys, err := s.apiKeyRepository.GetUserAPIKeys(userID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return types.NewAPIKeyResList(*apiKeys), http.StatusOK, nil
+}
+
+func (s *APIKeyService) DeleteAPIKey(id uuid.UUID, userID uuid.UUID) (int, error) {
+	result := s.apiKeyRepository.DeleteAPIKey(id, userID)
+	if result.Error != nil {
+		return http.StatusInternalServerError, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return http.StatusNotFound, errors.New("API key not found")
+	}
+
+	return http.StatusOK, nil
+}
+
+// ValidateAPIKey is called by AuthMiddleware for bearer tokens with the API
+// key prefix. It returns claims like those of an access token, without a
+// session, and the scopes of the key.
+func (s *APIKeyService) ValidateAPIKey(key string) (*types.JwtCustomClaims, []string, error) {
+	apiKey, result := s.apiKeyRepository.GetAPIKeyByHash(hashToken(key))
+	if result.RowsAffected == 0 || apiKey.IsExpired() || apiKey.User == nil || checkUserCanSignIn(apiKey.User) != nil {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	if err := s.apiKeyRepository.TouchAPIKey(apiKey, apiKeyTouchInterval); err != nil {
+		log.Printf("unable to update last use of API key %s: %v", apiKey.ID, err)
+	}
+
+	return &types.JwtCustomClaims{
+		ID:   apiKey.UserID,
+		Role: string(apiKey.User.Role),
+	}, apiKey.Scopes, nil
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gwid.io/gwid-core/internal/middleware"
+	"gwid.io/gwid-core/internal/models"
+	"gwid.io/gwid-core/internal/repositories"
+	"gwid.io/gwid-core/internal/types"
+)
+
+const (
+	auditExportBatchSize = 1000
+	auditExportMaxRows   = 100000
+)
+
+var auditExportHeader = []string{"created_at", "id", "actor_id", "action", "target_type", "target_id", "ip_address", "user_agent", "request_id", "metadata"}
+
+type AuditService struct {
+	auditRepository *repositories.AuditRepository
+}
+
+func NewAuditService(auditRepository *repositories.AuditRepository) *AuditService {
+	return &AuditService{
+		auditRepository: auditRepository,
+	}
+}
+
+// Record saves an audit event for an action that already happened. Failures
+// are logged rather than returned so that they never undo the action.
+func (s *AuditService) Record(actorID uuid.UUID, action string, targetType string, targetID string, metadata map[string]any, meta types.SessionMeta) {
+	event := models.AuditEvent{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Metadata:   metadata,
+		IPAddress:  meta.IPAddress,
+		UserAgent:  meta.UserAgent,
+		RequestID:  meta.RequestID,
+	}
+
+	if actorID != uuid.Nil {
+		event.ActorID = &actorID
+	}
+
+	if err := s.auditRepository.CreateAuditEvent(&event); err != nil {
+		log.Printf("unable to record audit event %s on %s %s: %v", action, targetType, targetID, err)
+	}
+}
+
+// ValidateAuditQuery checks the actor and time range filters and rewrites the
+// time bounds, given as RFC 3339 timestamps, in a form the database accepts.
+func (s *AuditService) ValidateAuditQuery(params *middleware.QueryParams) (int, error) {
+	if value := params.Filters["actor_id"]; value != "" {
+		if _, err := uuid.Parse(value); err != nil {
+			return http.StatusBadRequest, errors.New("invalid actor_id filter")
+		}
+	}
+
+	for _, filter := range []string{"from", "to"} {
+		value := params.Filters[filter]
+		if value == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return http.StatusBadRequest, errors.New("invalid " + filter + " filter, expected an RFC 3339 timestamp")
+		}
+
+		params.Filters[filter] = t.UTC().Format(time.RFC3339Nano)
+	}
+
+	return http.StatusOK, nil
+}
+
+func (s *AuditService) GetAuditEvents(params *middleware.QueryParams) (*[]models.AuditEvent, int, error) {
+	if statusCode, err := s.ValidateAuditQuery(params); err != nil {
+		return nil, statusCode, err
+	}
+
+	events, err := s.auditRepository.GetAuditEvents(params)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return events, http.StatusOK, nil
+}
+
+func (s *AuditService) GetAuditEventsCount(params *middleware.QueryParams) (int64, error) {
+	return s.auditRepository.GetAuditEventsCount(params)
+}
+
+// GetUserAuditEvents lists the events of actions taken by a user or on their
+// account. The filters only narrow that list down.
+func (s *AuditService) GetUserAuditEvents(userID uuid.UUID, params *middleware.QueryParams) (*[]models.AuditEvent, int, error) {
+	if statusCode, err := s.ValidateAuditQuery(params); err != nil {
+		return nil, statusCode, err
+	}
+
+	events, err := s.auditRepository.GetUserAuditEvents(userID, params)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return events, http.StatusOK, nil
+}
+
+func (s *AuditService) GetUserAuditEventsCount(userID uuid.UUID, params *middleware.QueryParams) (int64, error) {
+	return s.auditRepository.GetUserAuditEventsCount(userID, params)
+}
+
+// ExportAuditEvents writes the events matching a query validated with
+// ValidateAuditQuery as CSV, newest first and at most auditExportMaxRows of
+// them. Events recorded while the export runs are left out so that they do
+// not shift the pages being read.
+func (s *AuditService) ExportAuditEvents(params *middleware.QueryParams, w io.Writer) error {
+	now := time.Now().UTC()
+	if to, err := time.Parse(time.RFC3339Nano, params.Filters["to"]); err != nil || to.After(now) {
+		params.Filters["to"] = now.Format(time.RFC3339Nano)
+	}
+
+	params.Order = "desc"
+	params.Limit = auditExportBatchSize
+
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(auditExportHeader); err != nil {
+		return err
+	}
+
+	for params.Offset = 0; params.Offset < auditExportMaxRows; params.Offset += auditExportBatchSize {
+		events, err := s.auditRepository.GetAuditEvents(params)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range *events {
+			if err := writer.Write(auditEventRecord(&event)); err != nil {
+				return err
+			}
+		}
+
+		writer.Flush()
+
+		if err := writer.Error(); err != nil {
+			return err
+		}
+
+		if len(*events) < auditExportBatchSize {
+			break
+		}
+	}
+
+	return nil
+}
+
+func auditEventRecord(event *models.AuditEvent) []string {
+	actorID := ""
+	if event.ActorID != nil {
+		actorID = event.ActorID.String()
+	}
+
+	metadata := ""
+	if len(event.Metadata) > 0 {
+		if encoded, err := json.Marshal(event.Metadata); err == nil {
+			metadata = string(encoded)
+		}
+	}
+
+	record := []string{
+		event.CreatedAt.UTC().Format(time.RFC3339Nano),
+		event.ID.String(),
+		actorID,
+		event.Action,
+		event.TargetType,
+		event.TargetID,
+		event.IPAddress,
+		event.UserAgent,
+		event.RequestID,
+		metadata,
+	}
+
+	for i, value := range record {
+		record[i] = escapeCSVFormula(value)
+	}
+
+	return record
+}
+
+// escapeCSVFormula keeps spreadsheets from evaluating client-controlled values
+// such as the user agent as formulas.
+func escapeCSVFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
@@ -2,28 +2,160 @@
 package services
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"log"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/google/uuid"
+	"gwid.io/gwid-core/internal/config"
+	"gwid.io/gwid-core/internal/mailer"
 	"gwid.io/gwid-core/internal/models"
 	"gwid.io/gwid-core/internal/repositories"
 	"gwid.io/gwid-core/internal/types"
+	"gwid.io/gwid-core/internal/utils"
 )
 
+const (
+	passwordResetTTL     = time.Hour
+	emailVerificationTTL = 24 * time.Hour
+)
+
+var (
+	ErrAccountSuspended = errors.New("this account has been suspended")
+	ErrAccountDeleted   = errors.New("this account has been deleted")
+)
+
+// checkUserCanSignIn reports why a user cannot sign in, if they cannot.
+func checkUserCanSignIn(user *models.User) error {
+	if user.IsDeletionRequested() {
+		return ErrAccountDeleted
+	}
+
+	if user.IsSuspended() {
+		return ErrAccountSuspended
+	}
+
+	return nil
+}
+
 type AuthService struct {
-	userRepository *repositories.UserRepository
-	jwtService     *JwtService
+	cfg                  *config.Config
+	userRepository       *repositories.UserRepository
+	sessionRepository    *repositories.SessionRepository
+	userTokenRepository  *repositories.UserTokenRepository
+	jwtService           *JwtService
+	twoFactorService     *TwoFactorService
+	auditService         *AuditService
+	loginThrottleService *LoginThrottleService
+	mailer               mailer.Mailer
 }
 
-func NewAuthService(userRepository *repositories.UserRepository, jwtService *JwtService) *AuthService {
+func NewAuthService(
+	cfg *config.Config,
+	userRepository *repositories.UserRepository,
+	sessionRepository *repositories.SessionRepository,
+	userTokenRepository *repositories.UserTokenRepository,
+	jwtService *JwtService,
+	twoFactorService *TwoFactorService,
+	auditService *AuditService,
+	loginThrottleService *LoginThrottleService,
+	mailer mailer.Mailer,
+) *AuthService {
 	return &AuthService{
-		userRepository: userRepository,
-		jwtService:     jwtService,
+		cfg:                  cfg,
+		userRepository:       userRepository,
+		sessionRepository:    sessionRepository,
+		userTokenRepository:  userTokenRepository,
+		jwtService:           jwtService,
+		twoFactorService:     twoFactorService,
+		auditService:         auditService,
+		loginThrottleService: loginThrottleService,
+		mailer:               mailer,
+	}
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(hash[:])
+}
+
+func generateToken() (string, error) {
+	randomBytes := make([]byte, 32)
+
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(randomBytes), nil
+}
+
+// issueTokens signs an access token for the session and hands out the next
+// refresh token of its family.
+func (s *AuthService) issueTokens(user *models.User, session *models.Session) (types.AuthRes, error) {
+	refreshToken, err := generateToken()
+	if err != nil {
+		return types.AuthRes{}, err
+	}
+
+	expiresAt := time.Now().Add(RefreshTokenTTL)
+
+	if err := s.sessionRepository.CreateRefreshToken(&models.RefreshToken{
+		SessionID: session.ID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		return types.AuthRes{}, err
+	}
+
+	session.LastUsedAt = time.Now()
+	session.ExpiresAt = expiresAt
+
+	if err := s.sessionRepository.UpdateSessionColumns(session, "last_used_at", "expires_at"); err != nil {
+		return types.AuthRes{}, err
+	}
+
+	tokenString, err := s.jwtService.SignJWT(user, session.ID)
+	if err != nil {
+		return types.AuthRes{}, err
 	}
+
+	return types.AuthRes{
+		ID:           user.ID,
+		Role:         string(user.Role),
+		AccessToken:  tokenString,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+func (s *AuthService) createSession(user *models.User, meta types.SessionMeta) (types.AuthRes, error) {
+	session := models.Session{
+		UserID:     user.ID,
+		UserAgent:  meta.UserAgent,
+		IPAddress:  meta.IPAddress,
+		LastUsedAt: time.Now(),
+		ExpiresAt:  time.Now().Add(RefreshTokenTTL),
+	}
+
+	if err := s.sessionRepository.CreateSession(&session); err != nil {
+		return types.AuthRes{}, err
+	}
+
+	return s.issueTokens(user, &session)
 }
 
-func (s *AuthService) SignUp(user *models.User) (types.AuthRes, error) {
+func (s *AuthService) SignUp(user *models.User, meta types.SessionMeta) (types.AuthRes, error) {
+	if err := s.loginThrottleService.AllowSignup(meta.IPAddress); err != nil {
+		return types.AuthRes{}, err
+	}
+
 	_, result := s.userRepository.FindByEmail(user.Email)
 
 	if result.RowsAffected > 0 {
@@ -35,36 +167,224 @@ func (s *AuthService) SignUp(user *models.User) (types.AuthRes, error) {
 		return types.AuthRes{}, err
 	}
 
-	tokenString, err := s.jwtService.SignJWT(user)
+	go s.sendVerificationEmail(*user)
 
-	return types.AuthRes{
-		ID:          user.ID,
-		Role:        string(user.Role),
-		AccessToken: tokenString,
-	}, err
+	return s.createSession(user, meta)
 }
 
-func (s *AuthService) Login(loginReq types.LoginReq) (types.AuthRes, error) {
+// Login returns tokens, or a challenge to be completed with
+// VerifyTwoFactorLogin if the user has 2FA enabled.
+func (s *AuthService) Login(loginReq types.LoginReq, meta types.SessionMeta) (types.AuthRes, *types.TwoFactorChallengeRes, error) {
+	if err := s.loginThrottleService.CheckLogin(loginReq.Email, meta.IPAddress); err != nil {
+		return types.AuthRes{}, nil, err
+	}
+
 	user, result := s.userRepository.FindByEmail(loginReq.Email)
 
 	if result.RowsAffected == 0 {
-		return types.AuthRes{}, errors.New("invalid credentials")
+		s.auditService.Record(uuid.Nil, models.AuditAuthLoginFailed, models.AuditTargetUser, "", map[string]any{
+			"email":  loginReq.Email,
+			"reason": "unknown email",
+		}, meta)
+
+		s.recordLoginFailure(loginReq.Email, nil, meta)
+
+		return types.AuthRes{}, nil, errors.New("invalid credentials")
 	}
 
 	if err := user.CheckPassword(loginReq.Password); err != nil {
-		return types.AuthRes{}, errors.New("invalid credentials")
+		s.auditService.Record(user.ID, models.AuditAuthLoginFailed, models.AuditTargetUser, user.ID.String(), map[string]any{
+			"reason": "invalid password",
+		}, meta)
+
+		s.recordLoginFailure(loginReq.Email, user, meta)
+
+		return types.AuthRes{}, nil, errors.New("invalid credentials")
 	}
 
-	tokenString, err := s.jwtService.SignJWT(user)
+	return s.completeLogin(user, meta)
+}
 
-	return types.AuthRes{
-		ID:          user.ID,
-		Role:        string(user.Role),
-		AccessToken: tokenString,
-	}, err
+// recordLoginFailure counts a failed attempt on an account, which may not
+// exist, and tells the owner when it locked them out.
+func (s *AuthService) recordLoginFailure(email string, user *models.User, meta types.SessionMeta) {
+	if !s.loginThrottleService.RecordLoginFailure(email, meta.IPAddress) || user == nil {
+		return
+	}
+
+	s.auditService.Record(user.ID, models.AuditAuthAccountLocked, models.AuditTargetUser, user.ID.String(), map[string]any{
+		"locked_for": accountLockoutTTL.String(),
+	}, meta)
+
+	go s.sendAccountLockedEmail(*user, meta)
+}
+
+func (s *AuthService) sendAccountLockedEmail(user models.User, meta types.SessionMeta) {
+	message, err := mailer.Render("account_locked", user.Email, map[string]string{
+		"Name":      user.Name,
+		"IPAddress": meta.IPAddress,
+		"LockedFor": utils.HumanizeDuration(accountLockoutTTL),
+		"Link":      s.cfg.AppURL + "/forgot-password",
+	})
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if err := s.mailer.Send(message); err != nil {
+		log.Printf("unable to send account locked mail to user %s: %v", user.ID, err)
+	}
+}
+
+// completeLogin is the last step of every login method: it signs the user in,
+// or asks for a second factor if they have 2FA enabled.
+func (s *AuthService) completeLogin(user *models.User, meta types.SessionMeta) (types.AuthRes, *types.TwoFactorChallengeRes, error) {
+	if err := checkUserCanSignIn(user); err != nil {
+		s.auditService.Record(user.ID, models.AuditAuthLoginFailed, models.AuditTargetUser, user.ID.String(), map[string]any{
+			"reason": err.Error(),
+		}, meta)
+
+		return types.AuthRes{}, nil, err
+	}
+
+	if user.IsTwoFactorEnabled() {
+		challengeToken, err := s.jwtService.SignTwoFactorChallenge(user)
+		if err != nil {
+			return types.AuthRes{}, nil, err
+		}
+
+		return types.AuthRes{}, &types.TwoFactorChallengeRes{
+			TwoFactorRequired: true,
+			ChallengeToken:    challengeToken,
+			ExpiresIn:         int(TwoFactorChallengeTTL.Seconds()),
+		}, nil
+	}
+
+	authRes, err := s.createSession(user, meta)
+	if err != nil {
+		return types.AuthRes{}, nil, err
+	}
+
+	s.loginThrottleService.RecordLoginSuccess(user.Email)
+	s.auditService.Record(user.ID, models.AuditAuthLogin, models.AuditTargetUser, user.ID.String(), nil, meta)
+
+	return authRes, nil, nil
+}
+
+func (s *AuthService) VerifyTwoFactorLogin(twoFactorLoginReq types.TwoFactorLoginReq, meta types.SessionMeta) (types.AuthRes, int, error) {
+	userID, err := s.jwtService.ParseTwoFactorChallenge(twoFactorLoginReq.ChallengeToken)
+	if err != nil {
+		return types.AuthRes{}, http.StatusUnauthorized, err
+	}
+
+	user, result := s.userRepository.FindByID(userID)
+	if result.RowsAffected == 0 || !user.IsTwoFactorEnabled() {
+		return types.AuthRes{}, http.StatusUnauthorized, errors.New("invalid or expired challenge token")
+	}
+
+	if err := checkUserCanSignIn(user); err != nil {
+		return types.AuthRes{}, http.StatusForbidden, err
+	}
+
+	if err := s.loginThrottleService.CheckLogin(user.Email, meta.IPAddress); err != nil {
+		return types.AuthRes{}, http.StatusTooManyRequests, err
+	}
+
+	if err := s.twoFactorService.VerifyCode(user, twoFactorLoginReq.Code); err != nil {
+		if errors.Is(err, errInvalidTwoFactorCode) {
+			s.auditService.Record(user.ID, models.AuditAuthLoginFailed, models.AuditTargetUser, user.ID.String(), map[string]any{
+				"reason": "invalid two-factor code",
+			}, meta)
+
+			s.recordLoginFailure(user.Email, user, meta)
+
+			return types.AuthRes{}, http.StatusUnauthorized, err
+		}
+
+		return types.AuthRes{}, http.StatusInternalServerError, err
+	}
+
+	authRes, err := s.createSession(user, meta)
+	if err != nil {
+		return types.AuthRes{}, http.StatusInternalServerError, err
+	}
+
+	s.loginThrottleService.RecordLoginSuccess(user.Email)
+	s.auditService.Record(user.ID, models.AuditAuthLogin, models.AuditTargetUser, user.ID.String(), map[string]any{
+		"two_factor": true,
+	}, meta)
+
+	return authRes, http.StatusOK, nil
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token. A
+// refresh token presented twice revokes its whole session.
+func (s *AuthService) Refresh(refreshTokenReq types.RefreshTokenReq, meta types.SessionMeta) (types.AuthRes, int, error) {
+	refreshToken, result := s.sessionRepository.GetRefreshTokenByHash(hashToken(refreshTokenReq.RefreshToken))
+	if result.RowsAffected == 0 {
+		return types.AuthRes{}, http.StatusUnauthorized, errors.New("invalid refresh token")
+	}
+
+	session := refreshToken.Session
+
+	if session == nil || !session.IsActive() || time.Now().After(refreshToken.ExpiresAt) {
+		return types.AuthRes{}, http.StatusUnauthorized, errors.New("session expired")
+	}
+
+	marked, err := s.sessionRepository.MarkRefreshTokenUsed(refreshToken)
+	if err != nil {
+		return types.AuthRes{}, http.StatusInternalServerError, err
+	}
+
+	if !marked {
+		log.Printf("refresh token reuse detected, revoking session %s", session.ID)
+
+		if err := s.sessionRepository.RevokeSession(session, "refresh token reuse"); err != nil {
+			return types.AuthRes{}, http.StatusInternalServerError, err
+		}
+
+		return types.AuthRes{}, http.StatusUnauthorized, errors.New("invalid refresh token")
+	}
+
+	user, result := s.userRepository.FindByID(session.UserID)
+	if result.RowsAffected == 0 {
+		return types.AuthRes{}, http.StatusUnauthorized, errors.New("invalid refresh token")
+	}
+
+	if err := checkUserCanSignIn(user); err != nil {
+		return types.AuthRes{}, http.StatusForbidden, err
+	}
+
+	session.IPAddress = meta.IPAddress
+	session.UserAgent = meta.UserAgent
+
+	if err := s.sessionRepository.UpdateSessionColumns(session, "ip_address", "user_agent"); err != nil {
+		return types.AuthRes{}, http.StatusInternalServerError, err
+	}
+
+	authRes, err := s.issueTokens(user, session)
+	if err != nil {
+		return types.AuthRes{}, http.StatusInternalServerError, err
+	}
+
+	return authRes, http.StatusOK, nil
 }
 
-func (s *AuthService) ChangePassword(changePasswordReq types.ChangePasswordReq, userID uuid.UUID) (int, error) {
+func (s *AuthService) Logout(sessionID uuid.UUID, userID uuid.UUID) (int, error) {
+	session, result := s.sessionRepository.GetSessionByID(sessionID, userID)
+	if result.RowsAffected == 0 {
+		return http.StatusNotFound, errors.New("session not found")
+	}
+
+	if err := s.sessionRepository.RevokeSession(session, "logout"); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusOK, nil
+}
+
+// ChangePassword also signs the user out of every session but the current one.
+func (s *AuthService) ChangePassword(changePasswordReq types.ChangePasswordReq, userID uuid.UUID, sessionID uuid.UUID, meta types.SessionMeta) (int, error) {
 	user, result := s.userRepository.FindByID(userID)
 
 	if result.RowsAffected == 0 {
@@ -83,5 +403,205 @@ func (s *AuthService) ChangePassword(changePasswordReq types.ChangePasswordReq,
 		return http.StatusInternalServerError, errors.New("unable to change password")
 	}
 
+	if err := s.sessionRepository.RevokeUserSessions(userID, sessionID, "password changed"); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	s.auditService.Record(user.ID, models.AuditAuthPasswordChanged, models.AuditTargetUser, user.ID.String(), nil, meta)
+
+	return http.StatusOK, nil
+}
+
+func (s *AuthService) LogoutAll(userID uuid.UUID) (int, error) {
+	if err := s.sessionRepository.RevokeUserSessions(userID, uuid.Nil, "logout all"); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
 	return http.StatusOK, nil
 }
+
+// issueUserToken stores a new single-use token for the user and returns it in
+// plain text, to be sent by email.
+func (s *AuthService) issueUserToken(userID uuid.UUID, purpose models.UserTokenPurpose, ttl time.Duration) (string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.userTokenRepository.CreateUserToken(&models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// consumeUserToken checks the token and marks it used, so that it cannot be
+// used a second time even by concurrent requests.
+func (s *AuthService) consumeUserToken(token string, purpose models.UserTokenPurpose) (*models.UserToken, int, error) {
+	userToken, result := s.userTokenRepository.GetUserTokenByHash(hashToken(token), purpose)
+	if result.RowsAffected == 0 || !userToken.IsUsable() {
+		return nil, http.StatusBadRequest, errors.New("invalid or expired token")
+	}
+
+	consumed, err := s.userTokenRepository.ConsumeUserToken(userToken)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	if !consumed {
+		return nil, http.StatusBadRequest, errors.New("invalid or expired token")
+	}
+
+	return userToken, http.StatusOK, nil
+}
+
+func (s *AuthService) appLink(path string, token string) string {
+	return s.cfg.AppURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// ForgotPassword emails a reset link if the address belongs to an account.
+// The work happens in the background so that neither the response nor its
+// timing tell whether the address is registered.
+func (s *AuthService) ForgotPassword(forgotPasswordReq types.ForgotPasswordReq) int {
+	go func() {
+		user, result := s.userRepository.FindByEmail(forgotPasswordReq.Email)
+		if result.RowsAffected == 0 {
+			return
+		}
+
+		token, err := s.issueUserToken(user.ID, models.UserTokenPasswordReset, passwordResetTTL)
+		if err != nil {
+			log.Printf("unable to create password reset token for user %s: %v", user.ID, err)
+			return
+		}
+
+		message, err := mailer.Render("password_reset", user.Email, map[string]string{
+			"Name":      user.Name,
+			"Link":      s.appLink("/reset-password", token),
+			"ExpiresIn": utils.HumanizeDuration(passwordResetTTL),
+		})
+		if err != nil {
+			log.Println(err)
+			return
+		}
+
+		if err := s.mailer.Send(message); err != nil {
+			log.Printf("unable to send password reset mail to user %s: %v", user.ID, err)
+		}
+	}()
+
+	return http.StatusOK
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword and signs
+// the user out everywhere.
+func (s *AuthService) ResetPassword(resetPasswordReq types.ResetPasswordReq, meta types.SessionMeta) (int, error) {
+	userToken, statusCode, err := s.consumeUserToken(resetPasswordReq.Token, models.UserTokenPasswordReset)
+	if err != nil {
+		return statusCode, err
+	}
+
+	user, result := s.userRepository.FindByID(userToken.UserID)
+	if result.RowsAffected == 0 {
+		return http.StatusBadRequest, errors.New("invalid or expired token")
+	}
+
+	if err := user.HashPassword(resetPasswordReq.NewPassword); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	user.PasswordUnset = false
+
+	if result := s.userRepository.UpdateUser(user); result.RowsAffected == 0 {
+		return http.StatusInternalServerError, errors.New("unable to reset password")
+	}
+
+	if err := s.userTokenRepository.InvalidateUserTokens(user.ID, models.UserTokenPasswordReset); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if err := s.sessionRepository.RevokeUserSessions(user.ID, uuid.Nil, "password reset"); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	s.auditService.Record(user.ID, models.AuditAuthPasswordReset, models.AuditTargetUser, user.ID.String(), nil, meta)
+
+	return http.StatusOK, nil
+}
+
+func (s *AuthService) sendVerificationEmail(user models.User) {
+	token, err := s.issueUserToken(user.ID, models.UserTokenEmailVerification, emailVerificationTTL)
+	if err != nil {
+		log.Printf("unable to create email verification token for user %s: %v", user.ID, err)
+		return
+	}
+
+	message, err := mailer.Render("email_verification", user.Email, map[string]string{
+		"Name":      user.Name,
+		"Link":      s.appLink("/verify-email", token),
+		"ExpiresIn": utils.HumanizeDuration(emailVerificationTTL),
+	})
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if err := s.mailer.Send(message); err != nil {
+		log.Printf("unable to send verification mail to user %s: %v", user.ID, err)
+	}
+}
+
+func (s *AuthService) VerifyEmail(verifyEmailReq types.VerifyEmailReq) (int, error) {
+	userToken, statusCode, err := s.consumeUserToken(verifyEmailReq.Token, models.UserTokenEmailVerification)
+	if err != nil {
+		return statusCode, err
+	}
+
+	user, result := s.userRepository.FindByID(userToken.UserID)
+	if result.RowsAffected == 0 {
+		return http.StatusBadRequest, errors.New("invalid or expired token")
+	}
+
+	if user.IsEmailVerified() {
+		return http.StatusOK, nil
+	}
+
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+
+	if result := s.userRepository.UpdateUser(user); result.RowsAffected == 0 {
+		return http.StatusInternalServerError, errors.New("unable to verify email")
+	}
+
+	if err := s.userTokenRepository.InvalidateUserTokens(user.ID, models.UserTokenEmailVerification); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusOK, nil
+}
+
+// ResendVerificationEmail replaces any pending verification link with a new
+// one.
+func (s *AuthService) ResendVerificationEmail(userID uuid.UUID) (int, error) {
+	user, result := s.userRepository.FindByID(userID)
+	if result.RowsAffected == 0 {
+		return http.StatusNotFound, errors.New("user not found")
+	}
+
+	if user.IsEmailVerified() {
+		return http.StatusConflict, errors.New("email is already verified")
+	}
+
+	if err := s.userTokenRepository.InvalidateUserTokens(user.ID, models.UserTokenEmailVerification); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	go s.sendVerificationEmail(*user)
+
+	return http.StatusAccepted, nil
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"gwid.io/gwid-core/internal/models"
+	"gwid.io/gwid-core/internal/repositories"
+	"gwid.io/gwid-core/internal/types"
+)
+
+// AWSComputeProvider runs gateways on EC2 and talks to them through SSM.
+type AWSComputeProvider struct {
+	awsCredentialsService    *AWSCredentialsService
+	ec2Service               *EC2Service
+	awsCredentialsRepository *repositories.AWSCredentialsRepository
+	ec2Repository            *repositories.EC2Repository
+}
+
+func NewAWSComputeProvider(
+	awsCredentialsService *AWSCredentialsService,
+	ec2Service *EC2Service,
+	awsCredentialsRepository *repositories.AWSCredentialsRepository,
+	ec2Repository *repositories.EC2Repository,
+) *AWSComputeProvider {
+	return &AWSComputeProvider{
+		awsCredentialsService:    awsCredentialsService,
+		ec2Service:               ec2Service,
+		awsCredentialsRepository: awsCredentialsRepository,
+		ec2Repository:            ec2Repository,
+	}
+}
+
+func (p *AWSComputeProvider) Name() string {
+	return models.ProviderAWS
+}
+
+func (p *AWSComputeProvider) loadConfig(ctx context.Context, target types.InstanceTarget) (aws.Config, error) {
+	userCreds, _, err := p.awsCredentialsService.GetAWSCredentialsByID(target.CredentialsID, target.OrganizationID)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("unable to get user AWS credentials: %w", err)
+	}
+
+	cfg, err := p.awsCredentialsService.LoadAWSConfig(ctx, userCreds, target.Region)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("unable to load AWS config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+func (p *AWSComputeProvider) ec2Client(ctx context.Context, target types.InstanceTarget) (*ec2.Client, error) {
+	cfg, err := p.loadConfig(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	return ec2.NewFromConfig(cfg), nil
+}
+
+func (p *AWSComputeProvider) ssmClient(ctx context.Context, target types.InstanceTarget) (*ssm.Client, error) {
+	cfg, err := p.loadConfig(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	return ssm.NewFromConfig(cfg), nil
+}
+
+func (p *AWSComputeProvider) ValidateInstanceReq(ctx context.Context, req types.CreateInstanceReq) (int, error) {
+	credentials, result := p.awsCredentialsRepository.GetCredentialsByID(req.CredentialsID, req.OrganizationID)
+	if result.RowsAffected == 0 {
+		return http.StatusNotFound, errors.New("aws credentials not found")
+	}
+
+	if credentials.IsDeletionRequested() {
+		return http.StatusConflict, errors.New("aws credentials are being deleted")
+	}
+
+	if _, result := p.ec2Repository.GetEC2InstanceTypeByID(req.InstanceTypeID); result.RowsAffected == 0 {
+		return http.StatusNotFound, errors.New("ec2 instance type not found")
+	}
+
+	return http.StatusOK, nil
+}
+
+func (p *AWSComputeProvider) CreateInstance(ctx context.Context, req types.CreateInstanceReq) (string, int, error) {
+	return p.ec2Service.CreateEC2Instance(types.CreateEC2InstanceReq{
+		InstanceName:      req.InstanceName,
+		Region:            req.Region,
+		CredentialsID:     req.CredentialsID,
+		EC2InstanceTypeID: req.InstanceTypeID,
+	}, req.OrganizationID)
+}
+
+func (p *AWSComputeProvider) WaitForInstanceRunning(ctx context.Context, target types.InstanceTarget) error {
+	ec2Client, err := p.ec2Client(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	return p.ec2Service.WaitForInstanceRunning(target.InstanceID, ctx, ec2Client)
+}
+
+func (p *AWSComputeProvider) WaitForInstanceReady(ctx context.Context, target types.InstanceTarget) error {
+	ssmClient, err := p.ssmClient(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	return p.ec2Service.WaitForSSM(target.InstanceID, ctx, ssmClient)
+}
+
+func (p *AWSComputeProvider) RunCommand(ctx context.Context, target types.InstanceTarget, command string) (*types.CommandResult, error) {
+	ssmClient, err := p.ssmClient(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	commandID, err := p.ec2Service.RunCommand(target.InstanceID, ctx, command, ssmClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.ec2Service.WaitForCommandCompletion(target.InstanceID, ctx, commandID, ssmClient)
+}
+
+func (p *AWSComputeProvider) GetIPAddress(ctx context.Context, target types.InstanceTarget) (string, error) {
+	ec2Client, err := p.ec2Client(ctx, target)
+	if err != nil {
+		return "", err
+	}
+
+	return p.ec2Service.GetEC2IPAddress(target.InstanceID, ctx, ec2Client)
+}
+
+func (p *AWSComputeProvider) StopInstance(ctx context.Context, target types.InstanceTarget) error {
+	ec2Client, err := p.ec2Client(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	return p.ec2Service.StopInstance(target.InstanceID, ctx, ec2Client)
+}
+
+func (p *AWSComputeProvider) StartInstance(ctx context.Context, target types.InstanceTarget) error {
+	ec2Client, err := p.ec2Client(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	return p.ec2Service.StartInstance(target.InstanceID, ctx, ec2Client)
+}
+
+func (p *AWSComputeProvider) RebootInstance(ctx context.Context, target types.InstanceTarget) error {
+	ec2Client, err := p.ec2Client(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	return p.ec2Service.RebootInstance(target.InstanceID, ctx, ec2Client)
+}
+
+func (p *AWSComputeProvider) TerminateInstance(ctx context.Context, target types.InstanceTarget) error {
+	ec2Client, err := p.ec2Client(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	if err := p.ec2Service.TerminateInstance(target.InstanceID, ctx, ec2Client); err != nil {
+		return fmt.Errorf("unable to terminate instance: %w", err)
+	}
+
+	return p.ec2Service.WaitForInstanceTerminated(target.InstanceID, ctx, ec2Client)
+}
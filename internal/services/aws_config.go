@@ -0,0 +1,27 @@
+package services
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"gwid.io/gwid-core/internal/config"
+)
+
+// LoadPlatformAWSConfig loads the AWS config of the platform account itself.
+// The static AWS_ACCESS_ID key pair is used when set, otherwise the default
+// credential chain (environment, shared config, instance role) applies.
+func LoadPlatformAWSConfig(ctx context.Context, cfg *config.Config, region string) (aws.Config, error) {
+	opts := []func(*awsConfig.LoadOptions) error{
+		awsConfig.WithRegion(region),
+	}
+
+	if cfg.AwsAccessID != "" {
+		opts = append(opts, awsConfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AwsAccessID, cfg.AwsSecretAccessKey, ""),
+		))
+	}
+
+	return awsConfig.LoadDefaultConfig(ctx, opts...)
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"gwid.io/gwid-core/internal/config"
+	"gwid.io/gwid-core/internal/models"
+	"gwid.io/gwid-core/internal/repositories"
+	"gwid.io/gwid-core/internal/types"
+	"gwid.io/gwid-core/internal/utils"
+)
+
+// AWSCredentialsDeletionService deletes credentials that gateways still use:
+// it terminates those gateways through their usual tasks and removes the
+// credentials, with their IAM role, once the last one is gone.
+type AWSCredentialsDeletionService struct {
+	cfg                      *config.Config
+	awsCredentialsRepository *repositories.AWSCredentialsRepository
+	gatewayRepository        *repositories.GatewayRepository
+	gatewayService           *GatewayService
+	awsCredentialsService    *AWSCredentialsService
+}
+
+func NewAWSCredentialsDeletionService(
+	cfg *config.Config,
+	awsCredentialsRepository *repositories.AWSCredentialsRepository,
+	gatewayRepository *repositories.GatewayRepository,
+	gatewayService *GatewayService,
+	awsCredentialsService *AWSCredentialsService,
+) *AWSCredentialsDeletionService {
+	return &AWSCredentialsDeletionService{
+		cfg:                      cfg,
+		awsCredentialsRepository: awsCredentialsRepository,
+		gatewayRepository:        gatewayRepository,
+		gatewayService:           gatewayService,
+		awsCredentialsService:    awsCredentialsService,
+	}
+}
+
+func (s *AWSCredentialsDeletionService) enqueueDeleteAWSCredentialsTask(payload types.DeleteAWSCredentialsPayload, opts ...asynq.Option) error {
+	payloadJson, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	client := asynq.NewClient(asynq.RedisClientOpt{Addr: s.cfg.RedisAddress, Password: s.cfg.RedisPassword})
+
+	defer client.Close()
+
+	opts = append([]asynq.Option{asynq.MaxRetry(5), asynq.Timeout(10 * time.Minute)}, opts...)
+
+	_, err = client.Enqueue(asynq.NewTask(utils.TypeDeleteAWSCredentials, payloadJson, opts...))
+
+	return err
+}
+
+// ForceDeleteAWSCredentials deletes credentials right away when no gateway
+// uses them, and otherwise queues the termination of their gateways and
+// answers 202.
+func (s *AWSCredentialsDeletionService) ForceDeleteAWSCredentials(id uuid.UUID, organizationID uuid.UUID) (int, error) {
+	credential, result := s.awsCredentialsRepository.GetCredentialsByID(id, organizationID)
+	if result.RowsAffected == 0 {
+		return http.StatusNotFound, errors.New("credentials not found")
+	}
+
+	if credential.IsDeletionRequested() {
+		return http.StatusAccepted, nil
+	}
+
+	gateways, err := s.gatewayRepository.GetGatewaysByCredentialsID(credential.ID, organizationID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if len(*gateways) == 0 {
+		return s.awsCredentialsService.DeleteAWSCredentials(credential.ID, organizationID)
+	}
+
+	now := time.Now()
+
+	credential.DeletionRequestedAt = &now
+
+	if err := s.awsCredentialsRepository.UpdateAWSCredentialsColumns(credential, "deletion_requested_at"); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if err := s.gatewayService.terminateGateways(*gateways); err != nil {
+		s.cancelDeletion(credential)
+
+		return http.StatusConflict, err
+	}
+
+	payload := types.DeleteAWSCredentialsPayload{CredentialsID: credential.ID, OrganizationID: organizationID}
+
+	if err := s.enqueueDeleteAWSCredentialsTask(payload, asynq.ProcessIn(accountDeletionPollInterval)); err != nil {
+		s.cancelDeletion(credential)
+
+		return http.StatusInternalServerError, errors.New("unable to queue credentials deletion")
+	}
+
+	return http.StatusAccepted, nil
+}
+
+// cancelDeletion lets the credentials be used again. Gateways that were
+// already queued for termination still terminate.
+func (s *AWSCredentialsDeletionService) cancelDeletion(credential *models.AWSCredentials) {
+	credential.DeletionRequestedAt = nil
+
+	if err := s.awsCredentialsRepository.UpdateAWSCredentialsColumns(credential, "deletion_requested_at"); err != nil {
+		log.Printf("unable to cancel deletion of credentials %s: %v", credential.ID, err)
+	}
+}
+
+func (s *AWSCredentialsDeletionService) HandleDeleteAWSCredentialsTask(ctx context.Context, task *asynq.Task) error {
+	var payload types.DeleteAWSCredentialsPayload
+
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("json.Unmarsal failed: %v: %w", err, asynq.SkipRetry)
+	}
+
+	credential, result := s.awsCredentialsRepository.GetCredentialsByID(payload.CredentialsID, payload.OrganizationID)
+	if result.RowsAffected == 0 || !credential.IsDeletionRequested() {
+		return nil
+	}
+
+	gateways, err := s.gatewayRepository.GetGatewaysByCredentialsID(credential.ID, credential.OrganizationID)
+	if err != nil {
+		return err
+	}
+
+	if len(*gateways) > 0 {
+		if time.Since(*credential.DeletionRequestedAt) > gatewayTeardownTimeout {
+			s.cancelDeletion(credential)
+
+			return fmt.Errorf("gateways of credentials %s were not terminated within %s: %w", credential.ID, gatewayTeardownTimeout, asynq.SkipRetry)
+		}
+
+		// Retry gateways whose termination failed, the others are on their way.
+		if err := s.gatewayService.terminateGateways(*gateways); err != nil {
+			return err
+		}
+
+		return s.enqueueDeleteAWSCredentialsTask(payload, asynq.ProcessIn(accountDeletionPollInterval))
+	}
+
+	if _, err := s.awsCredentialsService.removeAWSCredentials(credential.ID, credential.OrganizationID, true); err != nil {
+		return err
+	}
+
+	log.Printf("credentials %s deleted", credential.ID)
+
+	return nil
+}
@@ -2,17 +2,24 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamTypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/google/uuid"
+	"gwid.io/gwid-core/internal/config"
 	"gwid.io/gwid-core/internal/middleware"
 	"gwid.io/gwid-core/internal/models"
 	"gwid.io/gwid-core/internal/repositories"
@@ -20,8 +27,11 @@ import (
 )
 
 type AWSCredentialsService struct {
+	cfg                      *config.Config
 	awsCredentialsRepository *repositories.AWSCredentialsRepository
-	encryptionService        *EncryptionService
+	userRepository           *repositories.UserRepository
+	gatewayRepository        *repositories.GatewayRepository
+	encryptionService        EncryptionService
 }
 
 const ssmTrustPolicy = `{
@@ -37,22 +47,133 @@ const ssmTrustPolicy = `{
   ]
 }`
 
+const ssmPolicyARN = "arn:aws:iam::aws:policy/AmazonSSMManagedInstanceCore"
+
+// platformTrustPolicy is the trust policy users attach to the role they
+// create for assume_role credentials.
+const platformTrustPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Principal": {
+        "AWS": "arn:aws:iam::%s:root"
+      },
+      "Action": "sts:AssumeRole",
+      "Condition": {
+        "StringEquals": {
+          "sts:ExternalId": "%s"
+        }
+      }
+    }
+  ]
+}`
+
 func NewAWSCredentialsService(
+	cfg *config.Config,
 	awsCredentialsRepository *repositories.AWSCredentialsRepository,
-	encryptionService *EncryptionService,
+	userRepository *repositories.UserRepository,
+	gatewayRepository *repositories.GatewayRepository,
+	encryptionService EncryptionService,
 ) *AWSCredentialsService {
 	return &AWSCredentialsService{
+		cfg:                      cfg,
 		awsCredentialsRepository: awsCredentialsRepository,
+		userRepository:           userRepository,
+		gatewayRepository:        gatewayRepository,
 		encryptionService:        encryptionService,
 	}
 }
 
-func (s *AWSCredentialsService) ValidateAWSCredentials(accessKeyID, secretAccessKey, region string) (*types.AWSCredentailsProfile, error) {
-	creds := credentials.NewStaticCredentialsProvider(accessKeyID, secretAccessKey, "")
+// CredentialsProvider returns the provider that acts in the user's account.
+// Access key credentials must already be decrypted. Assume role credentials
+// are exchanged for short-lived STS credentials, refreshed on expiry.
+func (s *AWSCredentialsService) CredentialsProvider(ctx context.Context, credential *models.AWSCredentials) (aws.CredentialsProvider, error) {
+	switch credential.Kind {
+	case models.AWSCredentialsAssumeRole:
+		if credential.AssumeRoleARN == nil || credential.ExternalID == nil {
+			return nil, errors.New("assume role credentials are incomplete")
+		}
+
+		platformCfg, err := LoadPlatformAWSConfig(ctx, s.cfg, "eu-central-1")
+		if err != nil {
+			return nil, fmt.Errorf("unable to load platform AWS config: %w", err)
+		}
+
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(platformCfg), *credential.AssumeRoleARN, func(o *stscreds.AssumeRoleOptions) {
+			o.ExternalID = credential.ExternalID
+			o.RoleSessionName = "gwid-" + credential.UserID.String()
+		})
+
+		return aws.NewCredentialsCache(provider), nil
+	default:
+		if credential.AccessKeyID == nil {
+			return nil, errors.New("access key credentials are incomplete")
+		}
+
+		return credentials.NewStaticCredentialsProvider(*credential.AccessKeyID, credential.SecretAccessKey, ""), nil
+	}
+}
+
+// LoadAWSConfig loads the AWS config for acting in the user's account.
+func (s *AWSCredentialsService) LoadAWSConfig(ctx context.Context, credential *models.AWSCredentials, region string) (aws.Config, error) {
+	provider, err := s.CredentialsProvider(ctx, credential)
+	if err != nil {
+		return aws.Config{}, err
+	}
+
+	return awsConfig.LoadDefaultConfig(ctx,
+		awsConfig.WithCredentialsProvider(provider),
+		awsConfig.WithRegion(region),
+	)
+}
+
+// GetAWSExternalID returns what a user needs to create a role for assume_role
+// credentials. The external ID is generated on first use and never changes.
+func (s *AWSCredentialsService) GetAWSExternalID(userID uuid.UUID) (*types.AWSExternalIDRes, int, error) {
+	user, result := s.userRepository.FindByID(userID)
+	if result.RowsAffected == 0 {
+		return nil, http.StatusNotFound, errors.New("user not found")
+	}
+
+	if user.AWSExternalID == nil {
+		randomBytes := make([]byte, 16)
+
+		if _, err := rand.Read(randomBytes); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+
+		externalID := "gwid-" + hex.EncodeToString(randomBytes)
+		user.AWSExternalID = &externalID
+
+		if result := s.userRepository.UpdateUser(user); result.Error != nil {
+			return nil, http.StatusInternalServerError, result.Error
+		}
+	}
+
+	ctx := context.TODO()
 
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
-		config.WithCredentialsProvider(creds),
-		config.WithRegion(region),
+	platformCfg, err := LoadPlatformAWSConfig(ctx, s.cfg, "eu-central-1")
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.New("unable to load platform AWS config")
+	}
+
+	identity, err := sts.NewFromConfig(platformCfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.New("unable to get platform AWS account")
+	}
+
+	return &types.AWSExternalIDRes{
+		AccountID:   *identity.Account,
+		ExternalID:  *user.AWSExternalID,
+		TrustPolicy: fmt.Sprintf(platformTrustPolicy, *identity.Account, *user.AWSExternalID),
+	}, http.StatusOK, nil
+}
+
+func (s *AWSCredentialsService) ValidateAWSCredentials(creds aws.CredentialsProvider, region string) (*types.AWSCredentailsProfile, error) {
+	cfg, err := awsConfig.LoadDefaultConfig(context.TODO(),
+		awsConfig.WithCredentialsProvider(creds),
+		awsConfig.WithRegion(region),
 	)
 	if err != nil {
 		return nil, err
@@ -76,6 +197,10 @@ func (s *AWSCredentialsService) ValidateAWSCredentials(accessKeyID, secretAccess
 	profileArn, err := createInstanceProfile(iamClient, profileName, roleName)
 
 	if err != nil {
+		if cleanupErr := deleteSSMRole(iamClient, roleName, profileName); cleanupErr != nil {
+			log.Printf("unable to clean up role %s: %v", roleName, cleanupErr)
+		}
+
 		return nil, err
 	}
 
@@ -102,11 +227,15 @@ func createSSMRole(iamClient *iam.Client, roleName string) (*string, error) {
 
 	attachPolicyInput := &iam.AttachRolePolicyInput{
 		RoleName:  aws.String(roleName),
-		PolicyArn: aws.String("arn:aws:iam::aws:policy/AmazonSSMManagedInstanceCore"),
+		PolicyArn: aws.String(ssmPolicyARN),
 	}
 
 	_, err = iamClient.AttachRolePolicy(context.TODO(), attachPolicyInput)
 	if err != nil {
+		if cleanupErr := deleteSSMRole(iamClient, roleName, ""); cleanupErr != nil {
+			log.Printf("unable to clean up role %s: %v", roleName, cleanupErr)
+		}
+
 		return nil, fmt.Errorf("failed to attach SSM policy: %w", err)
 	}
 
@@ -136,14 +265,104 @@ func createInstanceProfile(iamClient *iam.Client, profileName, roleName string)
 	return profileResult.InstanceProfile.Arn, nil
 }
 
+// deleteSSMRole removes the role and instance profile created by
+// ValidateAWSCredentials. Resources that are already gone are skipped.
+func deleteSSMRole(iamClient *iam.Client, roleName, profileName string) error {
+	ctx := context.TODO()
+
+	var noSuchEntity *iamTypes.NoSuchEntityException
+
+	if profileName != "" {
+		_, err := iamClient.RemoveRoleFromInstanceProfile(ctx, &iam.RemoveRoleFromInstanceProfileInput{
+			InstanceProfileName: aws.String(profileName),
+			RoleName:            aws.String(roleName),
+		})
+		if err != nil && !errors.As(err, &noSuchEntity) {
+			return fmt.Errorf("failed to remove role from instance profile: %w", err)
+		}
+
+		_, err = iamClient.DeleteInstanceProfile(ctx, &iam.DeleteInstanceProfileInput{
+			InstanceProfileName: aws.String(profileName),
+		})
+		if err != nil && !errors.As(err, &noSuchEntity) {
+			return fmt.Errorf("failed to delete instance profile: %w", err)
+		}
+	}
+
+	_, err := iamClient.DetachRolePolicy(ctx, &iam.DetachRolePolicyInput{
+		RoleName:  aws.String(roleName),
+		PolicyArn: aws.String(ssmPolicyARN),
+	})
+	if err != nil && !errors.As(err, &noSuchEntity) {
+		return fmt.Errorf("failed to detach SSM policy: %w", err)
+	}
+
+	_, err = iamClient.DeleteRole(ctx, &iam.DeleteRoleInput{
+		RoleName: aws.String(roleName),
+	})
+	if err != nil && !errors.As(err, &noSuchEntity) {
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+
+	return nil
+}
+
+// discardSSMRole removes the role and instance profile created for
+// credentials that could not be saved, so that nothing is left behind in the
+// account.
+func discardSSMRole(creds aws.CredentialsProvider, roleName, profileName string) {
+	cfg, err := awsConfig.LoadDefaultConfig(context.TODO(),
+		awsConfig.WithCredentialsProvider(creds),
+		awsConfig.WithRegion("eu-central-1"),
+	)
+	if err == nil {
+		err = deleteSSMRole(iam.NewFromConfig(cfg), roleName, profileName)
+	}
+
+	if err != nil {
+		log.Printf("unable to clean up role %s: %v", roleName, err)
+	}
+}
+
 func (s *AWSCredentialsService) CreateAWSCredentials(credentials *models.AWSCredentials) (int, error) {
-	_, result := s.awsCredentialsRepository.GetCredentialsByAccessKeyID(credentials.AccessKeyID)
+	switch credentials.Kind {
+	case models.AWSCredentialsAssumeRole:
+		if credentials.AssumeRoleARN == nil {
+			return http.StatusBadRequest, errors.New("assume role ARN is required")
+		}
+
+		if _, result := s.awsCredentialsRepository.GetCredentialsByAssumeRoleARN(*credentials.AssumeRoleARN, credentials.OrganizationID); result.RowsAffected > 0 {
+			return http.StatusBadRequest, errors.New("aws credentials already exisits")
+		}
+
+		user, result := s.userRepository.FindByID(credentials.UserID)
+		if result.RowsAffected == 0 {
+			return http.StatusNotFound, errors.New("user not found")
+		}
+
+		if user.AWSExternalID == nil {
+			return http.StatusBadRequest, errors.New("request an external ID before adding a role")
+		}
+
+		credentials.ExternalID = user.AWSExternalID
+	case models.AWSCredentialsAccessKey:
+		if credentials.AccessKeyID == nil {
+			return http.StatusBadRequest, errors.New("access key ID is required")
+		}
+
+		if _, result := s.awsCredentialsRepository.GetCredentialsByAccessKeyID(*credentials.AccessKeyID); result.RowsAffected > 0 {
+			return http.StatusBadRequest, errors.New("aws credentials already exisits")
+		}
+	default:
+		return http.StatusBadRequest, fmt.Errorf("unsupported credentials kind %q", credentials.Kind)
+	}
 
-	if result.RowsAffected > 0 {
-		return http.StatusBadRequest, errors.New("aws credentials already exisits")
+	creds, err := s.CredentialsProvider(context.TODO(), credentials)
+	if err != nil {
+		return http.StatusBadRequest, err
 	}
 
-	awsCredentialsProfile, err := s.ValidateAWSCredentials(credentials.AccessKeyID, credentials.SecretAccessKey, "eu-central-1")
+	awsCredentialsProfile, err := s.ValidateAWSCredentials(creds, "eu-central-1")
 
 	if err != nil {
 		return http.StatusBadRequest, errors.New("invalid AWS credentials")
@@ -154,21 +373,27 @@ func (s *AWSCredentialsService) CreateAWSCredentials(credentials *models.AWSCred
 	credentials.RoleName = awsCredentialsProfile.RoleName
 	credentials.RoleARN = awsCredentialsProfile.RoleARN
 
-	if encryptedSecretAccessKey, err := s.encryptionService.EncryptData([]byte(credentials.SecretAccessKey)); err != nil {
-		return http.StatusBadRequest, err
-	} else {
-		credentials.SecretAccessKey = encryptedSecretAccessKey
+	if credentials.Kind == models.AWSCredentialsAccessKey {
+		if encryptedSecretAccessKey, err := s.encryptionService.EncryptData([]byte(credentials.SecretAccessKey)); err != nil {
+			discardSSMRole(creds, credentials.RoleName, credentials.ProfieName)
+
+			return http.StatusBadRequest, err
+		} else {
+			credentials.SecretAccessKey = encryptedSecretAccessKey
+		}
 	}
 
 	if err := s.awsCredentialsRepository.CreateAWSCredentials(credentials); err != nil {
+		discardSSMRole(creds, credentials.RoleName, credentials.ProfieName)
+
 		return http.StatusBadRequest, err
 	}
 
 	return http.StatusCreated, nil
 }
 
-func (s *AWSCredentialsService) GetUserAWSCredentials(userID uuid.UUID, params *middleware.QueryParams) (*[]models.AWSCredentials, int, error) {
-	credentials, err := s.awsCredentialsRepository.GetUserCredentials(userID, params)
+func (s *AWSCredentialsService) GetOrganizationAWSCredentials(organizationID uuid.UUID, params *middleware.QueryParams) (*[]models.AWSCredentials, int, error) {
+	credentials, err := s.awsCredentialsRepository.GetOrganizationCredentials(organizationID, params)
 	if err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
@@ -176,8 +401,8 @@ func (s *AWSCredentialsService) GetUserAWSCredentials(userID uuid.UUID, params *
 	return credentials, http.StatusOK, nil
 }
 
-func (s *AWSCredentialsService) GetUserCredentialsCount(userID uuid.UUID) (int64, error) {
-	count, err := s.awsCredentialsRepository.GetUserCredentialsCount(userID)
+func (s *AWSCredentialsService) GetOrganizationCredentialsCount(organizationID uuid.UUID) (int64, error) {
+	count, err := s.awsCredentialsRepository.GetOrganizationCredentialsCount(organizationID)
 	if err != nil {
 		return 0, err
 	}
@@ -185,19 +410,125 @@ func (s *AWSCredentialsService) GetUserCredentialsCount(userID uuid.UUID) (int64
 	return count, nil
 }
 
-func (s *AWSCredentialsService) GetAWSCredentialsByID(id uuid.UUID, userID uuid.UUID) (*models.AWSCredentials, int, error) {
-	credential, result := s.awsCredentialsRepository.GetCredentialsByID(id, userID)
+func (s *AWSCredentialsService) GetAWSCredentialsByID(id uuid.UUID, organizationID uuid.UUID) (*models.AWSCredentials, int, error) {
+	credential, result := s.awsCredentialsRepository.GetCredentialsByID(id, organizationID)
 
 	if result.RowsAffected == 0 {
 		return nil, http.StatusNotFound, errors.New("credentials not found")
 	}
 
-	decryptedSecretAccessKey, err := s.encryptionService.DecryptData(credential.SecretAccessKey)
+	if credential.Kind == models.AWSCredentialsAccessKey {
+		decryptedSecretAccessKey, err := s.encryptionService.DecryptData(credential.SecretAccessKey)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+
+		credential.SecretAccessKey = decryptedSecretAccessKey
+	}
+
+	return credential, http.StatusOK, nil
+}
+
+// DeleteAWSCredentials removes the credentials and the IAM role and instance
+// profile created for them. Credentials still used by gateways are refused;
+// AWSCredentialsDeletionService terminates those gateways first.
+func (s *AWSCredentialsService) DeleteAWSCredentials(id uuid.UUID, organizationID uuid.UUID) (int, error) {
+	return s.removeAWSCredentials(id, organizationID, false)
+}
+
+// removeAWSCredentials deletes credentials no gateway uses anymore. With
+// ignoreIAMErrors a role that cannot be cleaned up, for example because the
+// key was revoked, is left behind instead of blocking the deletion.
+func (s *AWSCredentialsService) removeAWSCredentials(id uuid.UUID, organizationID uuid.UUID, ignoreIAMErrors bool) (int, error) {
+	credential, statusCode, err := s.GetAWSCredentialsByID(id, organizationID)
+	if err != nil {
+		return statusCode, err
+	}
+
+	gateways, err := s.gatewayRepository.GetGatewaysByCredentialsID(credential.ID, organizationID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if len(*gateways) > 0 {
+		return http.StatusConflict, fmt.Errorf("credentials are used by %d gateways, terminate them first or delete with force", len(*gateways))
+	}
+
+	ctx := context.TODO()
+
+	cfg, err := s.LoadAWSConfig(ctx, credential, "eu-central-1")
+	if err == nil {
+		err = deleteSSMRole(iam.NewFromConfig(cfg), credential.RoleName, credential.ProfieName)
+	}
+
+	if err != nil {
+		if !ignoreIAMErrors {
+			return http.StatusBadRequest, fmt.Errorf("unable to clean up IAM role: %w", err)
+		}
+
+		log.Printf("unable to clean up IAM role %s of credentials %s: %v", credential.RoleName, credential.ID, err)
+	}
+
+	if err := s.awsCredentialsRepository.DeleteAWSCredentials(credential); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusOK, nil
+}
+
+// RotateAWSCredentials replaces the key pair of access key credentials. The
+// new key has to belong to the same account, since the existing role and
+// instance profile are kept.
+func (s *AWSCredentialsService) RotateAWSCredentials(id uuid.UUID, organizationID uuid.UUID, updateReq types.UpdateAWSCredentialsReq) (*models.AWSCredentials, int, error) {
+	credential, result := s.awsCredentialsRepository.GetCredentialsByID(id, organizationID)
+	if result.RowsAffected == 0 {
+		return nil, http.StatusNotFound, errors.New("credentials not found")
+	}
+
+	if credential.Kind != models.AWSCredentialsAccessKey {
+		return nil, http.StatusBadRequest, errors.New("only access key credentials can be rotated")
+	}
+
+	if existing, result := s.awsCredentialsRepository.GetCredentialsByAccessKeyID(updateReq.AccessKeyID); result.RowsAffected > 0 && existing.ID != credential.ID {
+		return nil, http.StatusBadRequest, errors.New("aws credentials already exisits")
+	}
+
+	ctx := context.TODO()
+
+	cfg, err := awsConfig.LoadDefaultConfig(ctx,
+		awsConfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(updateReq.AccessKeyID, updateReq.SecretAccessKey, "")),
+		awsConfig.WithRegion("eu-central-1"),
+	)
 	if err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
 
-	credential.SecretAccessKey = decryptedSecretAccessKey
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, http.StatusBadRequest, errors.New("invalid AWS credentials")
+	}
+
+	if roleARN, err := arn.Parse(credential.RoleARN); err != nil || roleARN.AccountID != *identity.Account {
+		return nil, http.StatusBadRequest, errors.New("new key belongs to a different AWS account")
+	}
+
+	if _, err := iam.NewFromConfig(cfg).GetInstanceProfile(ctx, &iam.GetInstanceProfileInput{
+		InstanceProfileName: aws.String(credential.ProfieName),
+	}); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("unable to access instance profile %s: %w", credential.ProfieName, err)
+	}
+
+	encryptedSecretAccessKey, err := s.encryptionService.EncryptData([]byte(updateReq.SecretAccessKey))
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	credential.AccessKeyID = &updateReq.AccessKeyID
+	credential.SecretAccessKey = encryptedSecretAccessKey
+
+	if err := s.awsCredentialsRepository.UpdateAWSCredentialsColumns(credential, "access_key_id", "secret_access_key"); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
 
 	return credential, http.StatusOK, nil
 }
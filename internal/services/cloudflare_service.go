@@ -19,14 +19,27 @@ func NewCloudflareService(config *config.Config) *CloudflareService {
 	}
 }
 
+func (s *CloudflareService) IsConfigured() bool {
+	return s.config.CloudflareAPIToken != "" && s.config.CloudflareZoneName != ""
+}
+
+func (s *CloudflareService) GetZoneID() (string, error) {
+	cloudflareAPI, err := cloudflare.NewWithAPIToken(s.config.CloudflareAPIToken)
+	if err != nil {
+		return "", err
+	}
+
+	return cloudflareAPI.ZoneIDByName(s.config.CloudflareZoneName)
+}
+
+// AddGatewayToCloudflare creates the A record of the gateway. Calling it again
+// for a gateway that already has a record points that record at ipAddress.
 func (s *CloudflareService) AddGatewayToCloudflare(ipAddress, gatewayName string) (*types.CreateDNSRecordResult, error) {
 	cloudflareAPI, err := cloudflare.NewWithAPIToken(s.config.CloudflareAPIToken)
 	if err != nil {
 		return nil, err
 	}
 
-	ctx := context.Background()
-
 	zoneID, err := cloudflareAPI.ZoneIDByName(s.config.CloudflareZoneName)
 	if err != nil {
 		return nil, err
@@ -34,17 +47,7 @@ func (s *CloudflareService) AddGatewayToCloudflare(ipAddress, gatewayName string
 
 	subdomain := fmt.Sprintf("%s.%s", gatewayName, s.config.CloudflareZoneName)
 
-	record := cloudflare.CreateDNSRecordParams{
-		Type:      "A",
-		Name:      subdomain,
-		Content:   ipAddress,
-		ID:        zoneID,
-		TTL:       120,
-		Proxied:   cloudflare.BoolPtr(false),
-		Proxiable: false,
-	}
-
-	if _, err := cloudflareAPI.CreateDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneID), record); err != nil {
+	if err := s.upsertGatewayRecord(cloudflareAPI, zoneID, subdomain, ipAddress); err != nil {
 		return nil, err
 	}
 
@@ -54,6 +57,66 @@ func (s *CloudflareService) AddGatewayToCloudflare(ipAddress, gatewayName string
 	}, nil
 }
 
+// UpdateGatewayInCloudflare points the gateway record at a new address,
+// creating the record when it does not exist yet.
+func (s *CloudflareService) UpdateGatewayInCloudflare(zoneID, ipAddress, gatewayName string) error {
+	cloudflareAPI, err := cloudflare.NewWithAPIToken(s.config.CloudflareAPIToken)
+	if err != nil {
+		return err
+	}
+
+	subdomain := fmt.Sprintf("%s.%s", gatewayName, s.config.CloudflareZoneName)
+
+	return s.upsertGatewayRecord(cloudflareAPI, zoneID, subdomain, ipAddress)
+}
+
+func (s *CloudflareService) upsertGatewayRecord(cloudflareAPI *cloudflare.API, zoneID, subdomain, ipAddress string) error {
+	ctx := context.Background()
+
+	records, _, err := cloudflareAPI.ListDNSRecords(ctx, cloudflare.ZoneIdentifier(zoneID), cloudflare.ListDNSRecordsParams{
+		Type: "A",
+		Name: subdomain,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		record := cloudflare.CreateDNSRecordParams{
+			Type:      "A",
+			Name:      subdomain,
+			Content:   ipAddress,
+			ID:        zoneID,
+			TTL:       120,
+			Proxied:   cloudflare.BoolPtr(false),
+			Proxiable: false,
+		}
+
+		if _, err := cloudflareAPI.CreateDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneID), record); err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	if records[0].Content == ipAddress {
+		return nil
+	}
+
+	if _, err := cloudflareAPI.UpdateDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneID), cloudflare.UpdateDNSRecordParams{
+		ID:      records[0].ID,
+		Type:    "A",
+		Name:    subdomain,
+		Content: ipAddress,
+		TTL:     120,
+		Proxied: cloudflare.BoolPtr(false),
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (s *CloudflareService) RemoveGatewayFromCloudflare(zoneID, gatewayName string) error {
 	cloudflareAPI, err := cloudflare.NewWithAPIToken(s.config.CloudflareAPIToken)
 	if err != nil {
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"gwid.io/gwid-core/internal/config"
+	"gwid.io/gwid-core/internal/types"
+)
+
+// ComputeProvider is implemented by every cloud a gateway can be deployed to.
+// Instances are addressed through types.InstanceTarget so that implementations
+// can resolve credentials and regions on their own.
+type ComputeProvider interface {
+	Name() string
+
+	// ValidateInstanceReq checks a create request before any resource exists,
+	// returning the HTTP status code to report on failure.
+	ValidateInstanceReq(ctx context.Context, req types.CreateInstanceReq) (int, error)
+	CreateInstance(ctx context.Context, req types.CreateInstanceReq) (string, int, error)
+
+	// WaitForInstanceRunning returns once the machine itself is up, and
+	// WaitForInstanceReady once it accepts commands through RunCommand.
+	WaitForInstanceRunning(ctx context.Context, target types.InstanceTarget) error
+	WaitForInstanceReady(ctx context.Context, target types.InstanceTarget) error
+
+	RunCommand(ctx context.Context, target types.InstanceTarget, command string) (*types.CommandResult, error)
+	GetIPAddress(ctx context.Context, target types.InstanceTarget) (string, error)
+
+	StopInstance(ctx context.Context, target types.InstanceTarget) error
+	StartInstance(ctx context.Context, target types.InstanceTarget) error
+	RebootInstance(ctx context.Context, target types.InstanceTarget) error
+
+	// TerminateInstance returns once the instance is gone and must succeed for
+	// instances that no longer exist.
+	TerminateInstance(ctx context.Context, target types.InstanceTarget) error
+}
+
+type ComputeProviderRegistry struct {
+	providers map[string]ComputeProvider
+}
+
+func NewComputeProviderRegistry(
+	cfg *config.Config,
+	awsComputeProvider *AWSComputeProvider,
+	fakeComputeProvider *FakeComputeProvider,
+) *ComputeProviderRegistry {
+	registry := &ComputeProviderRegistry{
+		providers: make(map[string]ComputeProvider),
+	}
+
+	// The fake provider registers under the aws name so the usual create and
+	// deploy path runs against it unchanged.
+	if cfg.ComputeBackend == "fake" {
+		log.Println("Warning: using the in-memory fake compute provider")
+
+		registry.register(fakeComputeProvider)
+	} else {
+		registry.register(awsComputeProvider)
+	}
+
+	return registry
+}
+
+func (r *ComputeProviderRegistry) register(provider ComputeProvider) {
+	r.providers[provider.Name()] = provider
+}
+
+func (r *ComputeProviderRegistry) GetProvider(name string) (ComputeProvider, error) {
+	provider, ok := r.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported provider %q", name)
+	}
+
+	return provider, nil
+}
@@ -11,12 +11,11 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	awsTypes "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/smithy-go"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"gwid.io/gwid-core/internal/middleware"
@@ -87,8 +86,8 @@ func (s *EC2Service) GetUbuntuImageID(ec2Client *ec2.Client) (string, error) {
 	return *latestImage.ImageId, nil
 }
 
-func (s *EC2Service) CreateEC2Instance(ec2InstanceReq types.CreateEC2InstanceReq, userID uuid.UUID) (string, int, error) {
-	userCreds, int, err := s.awsCredentialsService.GetAWSCredentialsByID(ec2InstanceReq.CredentialsID, userID)
+func (s *EC2Service) CreateEC2Instance(ec2InstanceReq types.CreateEC2InstanceReq, organizationID uuid.UUID) (string, int, error) {
+	userCreds, int, err := s.awsCredentialsService.GetAWSCredentialsByID(ec2InstanceReq.CredentialsID, organizationID)
 	if err != nil {
 		return "", int, err
 	}
@@ -99,12 +98,7 @@ func (s *EC2Service) CreateEC2Instance(ec2InstanceReq types.CreateEC2InstanceReq
 		return "", http.StatusNotFound, errors.New("ec2 intance type not found")
 	}
 
-	creds := credentials.NewStaticCredentialsProvider(userCreds.AccessKeyID, userCreds.SecretAccessKey, "")
-
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
-		config.WithCredentialsProvider(creds),
-		config.WithRegion("eu-central-1"),
-	)
+	cfg, err := s.awsCredentialsService.LoadAWSConfig(context.TODO(), userCreds, ec2InstanceReq.Region)
 	if err != nil {
 		return "", http.StatusInternalServerError, errors.New("unable to load AWS config")
 	}
@@ -237,7 +231,7 @@ func (s *EC2Service) RunCommand(instanceID string, ctx context.Context, command
 
 func (s *EC2Service) WaitForCommandCompletion(instanceID string, ctx context.Context, commandID string, ssmClient *ssm.Client) (*types.CommandResult, error) {
 	startTime := time.Now()
-	maxWaitTime := 5 * time.Minute
+	maxWaitTime := 20 * time.Minute
 	pollInterval := 2 * time.Second
 
 	for {
@@ -283,10 +277,94 @@ func (s *EC2Service) WaitForCommandCompletion(instanceID string, ctx context.Con
 func (s *EC2Service) TerminateInstance(instanceID string, ctx context.Context, ec2Client *ec2.Client) error {
 	if _, err := ec2Client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{
 		InstanceIds: []string{instanceID},
+	}); err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidInstanceID.NotFound" {
+			return nil
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (s *EC2Service) WaitForInstanceTerminated(instanceID string, ctx context.Context, ec2Client *ec2.Client) error {
+	waiter := ec2.NewInstanceTerminatedWaiter(ec2Client)
+
+	input := &ec2.DescribeInstancesInput{
+		InstanceIds: []string{instanceID},
+	}
+
+	if err := waiter.Wait(ctx, input, 4*time.Minute); err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidInstanceID.NotFound" {
+			return nil
+		}
+
+		return fmt.Errorf("instance %s did not terminate: %w", instanceID, err)
+	}
+
+	return nil
+}
+
+func (s *EC2Service) StopInstance(instanceID string, ctx context.Context, ec2Client *ec2.Client) error {
+	input := &ec2.DescribeInstancesInput{
+		InstanceIds: []string{instanceID},
+	}
+
+	if _, err := ec2Client.StopInstances(ctx, &ec2.StopInstancesInput{
+		InstanceIds: []string{instanceID},
+	}); err != nil {
+		return err
+	}
+
+	waiter := ec2.NewInstanceStoppedWaiter(ec2Client)
+
+	if err := waiter.Wait(ctx, input, 5*time.Minute); err != nil {
+		return fmt.Errorf("instance %s did not stop: %w", instanceID, err)
+	}
+
+	return nil
+}
+
+func (s *EC2Service) StartInstance(instanceID string, ctx context.Context, ec2Client *ec2.Client) error {
+	input := &ec2.DescribeInstancesInput{
+		InstanceIds: []string{instanceID},
+	}
+
+	if _, err := ec2Client.StartInstances(ctx, &ec2.StartInstancesInput{
+		InstanceIds: []string{instanceID},
 	}); err != nil {
 		return err
 	}
 
+	waiter := ec2.NewInstanceRunningWaiter(ec2Client)
+
+	if err := waiter.Wait(ctx, input, 5*time.Minute); err != nil {
+		return fmt.Errorf("instance %s did not start: %w", instanceID, err)
+	}
+
+	return nil
+}
+
+func (s *EC2Service) RebootInstance(instanceID string, ctx context.Context, ec2Client *ec2.Client) error {
+	if _, err := ec2Client.RebootInstances(ctx, &ec2.RebootInstancesInput{
+		InstanceIds: []string{instanceID},
+	}); err != nil {
+		return err
+	}
+
+	// A reboot keeps the instance in the running state, so wait for the status
+	// checks to pass again instead of for a state change.
+	waiter := ec2.NewInstanceStatusOkWaiter(ec2Client)
+
+	if err := waiter.Wait(ctx, &ec2.DescribeInstanceStatusInput{
+		InstanceIds: []string{instanceID},
+	}, 10*time.Minute); err != nil {
+		return fmt.Errorf("instance %s did not come back after reboot: %w", instanceID, err)
+	}
+
 	return nil
 }
 
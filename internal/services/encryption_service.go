@@ -7,21 +7,49 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
+	"strings"
 
 	"gwid.io/gwid-core/internal/config"
 )
 
-type EncryptionService struct {
+// EncryptionService encrypts secrets before they are stored. Every
+// implementation can decrypt the ciphertexts written by LocalEncryptionService
+// so that the backend can be switched and re-encryption catch up later.
+type EncryptionService interface {
+	EncryptData(data []byte) (string, error)
+	DecryptData(encryptedData string) (string, error)
+	// ReEncryptData decrypts the ciphertext and encrypts it again with the
+	// active key.
+	ReEncryptData(encryptedData string) (string, error)
+	// ActiveKeyID is the prefix of ciphertexts that need no re-encryption.
+	ActiveKeyID() string
+}
+
+func NewEncryptionService(config *config.Config) EncryptionService {
+	localEncryptionService := NewLocalEncryptionService(config)
+
+	if config.EncryptionBackend == "vault" {
+		return NewVaultEncryptionService(config, localEncryptionService)
+	}
+
+	return localEncryptionService
+}
+
+// LocalEncryptionService encrypts with the active key of the keyring and
+// prefixes the ciphertext with its key ID, so that any key still in the
+// keyring can decrypt it. Ciphertexts without a prefix predate the keyring and
+// are decrypted with ENCRYPTION_KEY.
+type LocalEncryptionService struct {
 	config *config.Config
 }
 
-func NewEncryptionService(config *config.Config) *EncryptionService {
-	return &EncryptionService{
+func NewLocalEncryptionService(config *config.Config) *LocalEncryptionService {
+	return &LocalEncryptionService{
 		config: config,
 	}
 }
 
-func (s *EncryptionService) validateKey(key string) ([]byte, bool, int) {
+func (s *LocalEncryptionService) validateKey(key string) ([]byte, bool, int) {
 	byteKey := []byte(key)
 
 	if len(byteKey) == 32 {
@@ -31,66 +59,134 @@ func (s *EncryptionService) validateKey(key string) ([]byte, bool, int) {
 	}
 }
 
-func (s *EncryptionService) EncryptData(data []byte) (string, error) {
-	key, isEncryptionKeyValid, size := s.validateKey(s.config.EncryptionKey)
+func (s *LocalEncryptionService) getKey(keyID string) ([]byte, error) {
+	keyString, ok := s.config.EncryptionKeys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("encryption key %q not found", keyID)
+	}
+
+	key, isEncryptionKeyValid, size := s.validateKey(keyString)
 
 	if !isEncryptionKeyValid {
-		return "", fmt.Errorf("invalid encryption key of size %v", size)
+		return nil, fmt.Errorf("invalid encryption key %q of size %v", keyID, size)
 	}
 
-	block, err := aes.NewCipher(key)
+	return key, nil
+}
+
+func (s *LocalEncryptionService) ActiveKeyID() string {
+	return s.config.EncryptionKeyID
+}
+
+// KeyID returns the ID of the key the ciphertext was encrypted with, or an
+// empty string for legacy ciphertexts.
+func (s *LocalEncryptionService) KeyID(encryptedData string) string {
+	keyID, _, ok := strings.Cut(encryptedData, ":")
+	if !ok {
+		return ""
+	}
+
+	return keyID
+}
+
+func (s *LocalEncryptionService) EncryptData(data []byte) (string, error) {
+	keyID := s.ActiveKeyID()
+
+	key, err := s.getKey(keyID)
 	if err != nil {
 		return "", err
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	cipherText, err := sealAESGCM(key, data)
 	if err != nil {
 		return "", err
 	}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+	return keyID + ":" + base64.StdEncoding.EncodeToString(cipherText), nil
+}
+
+func (s *LocalEncryptionService) DecryptData(encryptedData string) (string, error) {
+	var key []byte
+
+	keyID, encodedCipherText, ok := strings.Cut(encryptedData, ":")
+	if ok {
+		versionedKey, err := s.getKey(keyID)
+		if err != nil {
+			return "", err
+		}
+
+		key = versionedKey
+	} else {
+		legacyKey, isEncryptionKeyValid, size := s.validateKey(s.config.EncryptionKey)
+
+		if !isEncryptionKeyValid {
+			return "", fmt.Errorf("invalid legacy encryption key of size %v", size)
+		}
+
+		key = legacyKey
+		encodedCipherText = encryptedData
+	}
+
+	cipherText, err := base64.StdEncoding.DecodeString(encodedCipherText)
+	if err != nil {
 		return "", err
 	}
 
-	cipherText := gcm.Seal(nonce, nonce, data, nil)
+	data, err := openAESGCM(key, cipherText)
+	if err != nil {
+		return "", err
+	}
 
-	return base64.StdEncoding.EncodeToString(cipherText), nil
+	return string(data), nil
 }
 
-func (s *EncryptionService) DecryptData(encryptedData string) (string, error) {
-	key, isEncryptionKeyValid, size := s.validateKey(s.config.EncryptionKey)
+func (s *LocalEncryptionService) ReEncryptData(encryptedData string) (string, error) {
+	data, err := s.DecryptData(encryptedData)
+	if err != nil {
+		return "", err
+	}
 
-	if !isEncryptionKeyValid {
-		return "", fmt.Errorf("invalid encryption key of size %v", size)
+	return s.EncryptData([]byte(data))
+}
+
+// sealAESGCM encrypts data with a random nonce that is prepended to the
+// returned ciphertext.
+func sealAESGCM(key []byte, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
 	}
 
-	cipherText, err := base64.StdEncoding.DecodeString(encryptedData)
+	gcm, err := cipher.NewGCM(block)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+func openAESGCM(key []byte, cipherText []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	gcm, err := cipher.NewGCM(block)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	nonceSize := gcm.NonceSize()
 	if len(cipherText) < nonceSize {
-		return "", fmt.Errorf("ciphertext too short")
+		return nil, fmt.Errorf("ciphertext too short")
 	}
 
 	nonce, cipherText := cipherText[:nonceSize], cipherText[nonceSize:]
 
-	data, err := gcm.Open(nil, nonce, cipherText, nil)
-	if err != nil {
-		return "", err
-	}
-
-	return string(data), nil
+	return gcm.Open(nil, nonce, cipherText, nil)
 }
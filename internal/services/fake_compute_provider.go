@@ -0,0 +1,328 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gwid.io/gwid-core/internal/config"
+	"gwid.io/gwid-core/internal/models"
+	"gwid.io/gwid-core/internal/provisioning"
+	"gwid.io/gwid-core/internal/repositories"
+	"gwid.io/gwid-core/internal/types"
+)
+
+// Steps that FAKE_COMPUTE_FAIL_STEP can make the fake provider fail at. The
+// deploy step does not return an error but makes the deploy script report a
+// failure, like a broken install on a real instance would.
+const (
+	FakeFailCreate    = "create"
+	FakeFailRunning   = "running"
+	FakeFailReady     = "ready"
+	FakeFailCommand   = "command"
+	FakeFailDeploy    = "deploy"
+	FakeFailStop      = "stop"
+	FakeFailStart     = "start"
+	FakeFailReboot    = "reboot"
+	FakeFailTerminate = "terminate"
+)
+
+var errFakeInstanceNotFound = errors.New("fake instance not found")
+
+type fakeInstance struct {
+	state     string
+	ipAddress string
+	runningAt time.Time
+}
+
+// FakeComputeProvider keeps instances in memory so that gateways can be
+// created, deployed and torn down without a cloud account. It stands in for
+// the aws provider when COMPUTE_BACKEND is "fake".
+type FakeComputeProvider struct {
+	config                   config.FakeComputeConfig
+	awsCredentialsRepository *repositories.AWSCredentialsRepository
+
+	mu        sync.Mutex
+	instances map[string]*fakeInstance
+	nextIP    int
+}
+
+func NewFakeComputeProvider(cfg *config.Config, awsCredentialsRepository *repositories.AWSCredentialsRepository) *FakeComputeProvider {
+	return &FakeComputeProvider{
+		config:                   cfg.FakeCompute,
+		awsCredentialsRepository: awsCredentialsRepository,
+		instances:                make(map[string]*fakeInstance),
+	}
+}
+
+func (p *FakeComputeProvider) Name() string {
+	return models.ProviderAWS
+}
+
+func (p *FakeComputeProvider) fail(step string) error {
+	if p.config.FailStep == step {
+		return fmt.Errorf("fake compute: injected failure at %s", step)
+	}
+
+	return nil
+}
+
+// sleep waits for d unless the task is cancelled first.
+func (p *FakeComputeProvider) sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// assignIP hands out addresses from TEST-NET-3 so they can never be mistaken
+// for a real host.
+func (p *FakeComputeProvider) assignIP() string {
+	p.nextIP++
+
+	return fmt.Sprintf("203.0.113.%d", p.nextIP%254+1)
+}
+
+func (p *FakeComputeProvider) getInstance(instanceID string) (*fakeInstance, error) {
+	instance, ok := p.instances[instanceID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errFakeInstanceNotFound, instanceID)
+	}
+
+	return instance, nil
+}
+
+// ValidateInstanceReq only checks the credentials, which gateways reference by
+// foreign key. Instance types are synced from AWS and are not required.
+func (p *FakeComputeProvider) ValidateInstanceReq(ctx context.Context, req types.CreateInstanceReq) (int, error) {
+	if _, result := p.awsCredentialsRepository.GetCredentialsByID(req.CredentialsID, req.OrganizationID); result.RowsAffected == 0 {
+		return http.StatusNotFound, errors.New("aws credentials not found")
+	}
+
+	return http.StatusOK, nil
+}
+
+func (p *FakeComputeProvider) CreateInstance(ctx context.Context, req types.CreateInstanceReq) (string, int, error) {
+	if err := p.fail(FakeFailCreate); err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	instanceID := "i-fake-" + uuid.NewString()[:8]
+
+	p.instances[instanceID] = &fakeInstance{
+		state:     "pending",
+		runningAt: time.Now().Add(p.config.BootDelay),
+	}
+
+	return instanceID, http.StatusCreated, nil
+}
+
+func (p *FakeComputeProvider) WaitForInstanceRunning(ctx context.Context, target types.InstanceTarget) error {
+	p.mu.Lock()
+	instance, err := p.getInstance(target.InstanceID)
+	p.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	if err := p.sleep(ctx, time.Until(instance.runningAt)); err != nil {
+		return err
+	}
+
+	if err := p.fail(FakeFailRunning); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if instance.state == "pending" {
+		instance.state = "running"
+		instance.ipAddress = p.assignIP()
+	}
+
+	return nil
+}
+
+func (p *FakeComputeProvider) WaitForInstanceReady(ctx context.Context, target types.InstanceTarget) error {
+	if err := p.fail(FakeFailReady); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	instance, err := p.getInstance(target.InstanceID)
+	if err != nil {
+		return err
+	}
+
+	if instance.state != "running" {
+		return fmt.Errorf("fake instance %s is %s", target.InstanceID, instance.state)
+	}
+
+	return nil
+}
+
+// RunCommand ignores the command and returns FAKE_COMPUTE_COMMAND_OUTPUT, or a
+// deploy result in the format the real deploy script prints.
+func (p *FakeComputeProvider) RunCommand(ctx context.Context, target types.InstanceTarget, command string) (*types.CommandResult, error) {
+	p.mu.Lock()
+	_, err := p.getInstance(target.InstanceID)
+	p.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+
+	if err := p.sleep(ctx, p.config.CommandDelay); err != nil {
+		return nil, err
+	}
+
+	if err := p.fail(FakeFailCommand); err != nil {
+		return nil, err
+	}
+
+	stdout := p.config.CommandOutput
+
+	if stdout == "" {
+		result := types.GatewayDeployResult{
+			Success:         true,
+			ScriptVersion:   provisioning.GatewayDeployScriptVersion,
+			LivepeerVersion: "fake",
+		}
+
+		if p.fail(FakeFailDeploy) != nil {
+			result.Success = false
+			result.Step = "install_livepeer"
+			result.Error = "fake compute: injected deploy failure"
+		}
+
+		output, err := json.Marshal(result)
+		if err != nil {
+			return nil, err
+		}
+
+		stdout = string(output)
+	}
+
+	return &types.CommandResult{
+		CommandID:     "fake-" + uuid.NewString(),
+		Status:        "Success",
+		StandardOut:   stdout,
+		ExecutionTime: time.Since(started),
+	}, nil
+}
+
+func (p *FakeComputeProvider) GetIPAddress(ctx context.Context, target types.InstanceTarget) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	instance, err := p.getInstance(target.InstanceID)
+	if err != nil {
+		return "", err
+	}
+
+	if instance.ipAddress == "" {
+		return "", fmt.Errorf("fake instance %s has no public IP", target.InstanceID)
+	}
+
+	return instance.ipAddress, nil
+}
+
+func (p *FakeComputeProvider) StopInstance(ctx context.Context, target types.InstanceTarget) error {
+	if err := p.sleep(ctx, p.config.BootDelay); err != nil {
+		return err
+	}
+
+	if err := p.fail(FakeFailStop); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	instance, err := p.getInstance(target.InstanceID)
+	if err != nil {
+		return err
+	}
+
+	instance.state = "stopped"
+	instance.ipAddress = ""
+
+	return nil
+}
+
+// StartInstance assigns a new address, as EC2 does for instances without an
+// elastic IP.
+func (p *FakeComputeProvider) StartInstance(ctx context.Context, target types.InstanceTarget) error {
+	if err := p.sleep(ctx, p.config.BootDelay); err != nil {
+		return err
+	}
+
+	if err := p.fail(FakeFailStart); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	instance, err := p.getInstance(target.InstanceID)
+	if err != nil {
+		return err
+	}
+
+	instance.state = "running"
+	instance.ipAddress = p.assignIP()
+
+	return nil
+}
+
+func (p *FakeComputeProvider) RebootInstance(ctx context.Context, target types.InstanceTarget) error {
+	p.mu.Lock()
+	_, err := p.getInstance(target.InstanceID)
+	p.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	if err := p.sleep(ctx, p.config.BootDelay); err != nil {
+		return err
+	}
+
+	return p.fail(FakeFailReboot)
+}
+
+func (p *FakeComputeProvider) TerminateInstance(ctx context.Context, target types.InstanceTarget) error {
+	if err := p.fail(FakeFailTerminate); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.instances, target.InstanceID)
+
+	return nil
+}
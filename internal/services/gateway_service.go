@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -16,29 +17,23 @@ import (
 )
 
 type GatewayService struct {
-	cfg                      *config.Config
-	gatewayTaskService       *GatewayTaskService
-	gatewayRepository        *repositories.GatewayRepository
-	ec2Service               *EC2Service
-	awsCredentialsRepository *repositories.AWSCredentialsRepository
-	ec2Repository            *repositories.EC2Repository
+	cfg                     *config.Config
+	gatewayTaskService      *GatewayTaskService
+	gatewayRepository       *repositories.GatewayRepository
+	computeProviderRegistry *ComputeProviderRegistry
 }
 
 func NewGatewayService(
 	cfg *config.Config,
 	gatewayTaskService *GatewayTaskService,
 	gatewayRepository *repositories.GatewayRepository,
-	ec2Service *EC2Service,
-	awsCredentialsRepository *repositories.AWSCredentialsRepository,
-	ec2Repository *repositories.EC2Repository,
+	computeProviderRegistry *ComputeProviderRegistry,
 ) *GatewayService {
 	return &GatewayService{
-		cfg:                      cfg,
-		gatewayTaskService:       gatewayTaskService,
-		gatewayRepository:        gatewayRepository,
-		ec2Service:               ec2Service,
-		awsCredentialsRepository: awsCredentialsRepository,
-		ec2Repository:            ec2Repository,
+		cfg:                     cfg,
+		gatewayTaskService:      gatewayTaskService,
+		gatewayRepository:       gatewayRepository,
+		computeProviderRegistry: computeProviderRegistry,
 	}
 }
 
@@ -48,53 +43,90 @@ func (s *GatewayService) getAsynqClient() *asynq.Client {
 	return client
 }
 
-func (s *GatewayService) CreateGatewayWithAWS(createGatewayWithAWSReq types.CreateGatewayWithAWSReq, userID uuid.UUID) (*models.Gateway, int, error) {
-	formattedGatewayName := utils.ToKebabCase(createGatewayWithAWSReq.GatewayName)
+func (s *GatewayService) getAsynqInspector() *asynq.Inspector {
+	inspector := asynq.NewInspector(asynq.RedisClientOpt{Addr: s.cfg.RedisAddress, Password: s.cfg.RedisPassword})
+
+	return inspector
+}
+
+// cancelQueuedTask makes sure the task with the given ID will not run again,
+// whether it is still waiting in the queue or currently being processed.
+func (s *GatewayService) cancelQueuedTask(taskID string) error {
+	inspector := s.getAsynqInspector()
+
+	defer inspector.Close()
+
+	info, err := inspector.GetTaskInfo(utils.DefaultQueue, taskID)
+	if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	switch info.State {
+	case asynq.TaskStateActive:
+		return inspector.CancelProcessing(taskID)
+	case asynq.TaskStateCompleted, asynq.TaskStateArchived:
+		return nil
+	default:
+		if err := inspector.DeleteTask(utils.DefaultQueue, taskID); err != nil && !errors.Is(err, asynq.ErrTaskNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *GatewayService) CreateGateway(createGatewayReq types.CreateGatewayReq, organizationID uuid.UUID, userID uuid.UUID) (*models.Gateway, int, error) {
+	provider, err := s.computeProviderRegistry.GetProvider(createGatewayReq.Provider)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	formattedGatewayName := utils.ToKebabCase(createGatewayReq.GatewayName)
 
 	if _, result := s.gatewayRepository.GetGatewayByName(formattedGatewayName); result.RowsAffected > 0 {
-		return nil, http.StatusBadRequest, fmt.Errorf("%s already existis", createGatewayWithAWSReq.GatewayName)
+		return nil, http.StatusBadRequest, fmt.Errorf("%s already existis", createGatewayReq.GatewayName)
 	}
 
-	if _, result := s.awsCredentialsRepository.GetCredentialsByID(createGatewayWithAWSReq.CredentialsID, userID); result.RowsAffected == 0 {
-		return nil, http.StatusNotFound, errors.New("aws credentials not found")
+	instanceReq := types.CreateInstanceReq{
+		InstanceName:   createGatewayReq.GatewayName,
+		Region:         createGatewayReq.Region,
+		CredentialsID:  createGatewayReq.CredentialsID,
+		InstanceTypeID: createGatewayReq.InstanceTypeID,
+		OrganizationID: organizationID,
 	}
 
-	if _, result := s.ec2Repository.GetEC2InstanceTypeByID(createGatewayWithAWSReq.EC2InstanceTypeID); result.RowsAffected == 0 {
-		return nil, http.StatusNotFound, errors.New("ec2 instance type not found")
+	ctx := context.Background()
+
+	if statusCode, err := provider.ValidateInstanceReq(ctx, instanceReq); err != nil {
+		return nil, statusCode, err
 	}
 
 	gateway := models.Gateway{
-		Provider:           "aws",
-		Region:             createGatewayWithAWSReq.Region,
+		Provider:           provider.Name(),
+		Region:             createGatewayReq.Region,
 		GatewayName:        formattedGatewayName,
-		GatewayType:        createGatewayWithAWSReq.GatewayType,
-		RPCURL:             createGatewayWithAWSReq.RPCURL,
-		Password:           createGatewayWithAWSReq.Password,
-		TranscodingProfile: createGatewayWithAWSReq.TranscodingProfile,
+		GatewayType:        createGatewayReq.GatewayType,
+		RPCURL:             createGatewayReq.RPCURL,
+		Password:           createGatewayReq.Password,
+		TranscodingProfile: createGatewayReq.TranscodingProfile,
 		UserID:             userID,
-		AWSCredentialsID:   createGatewayWithAWSReq.CredentialsID,
+		OrganizationID:     organizationID,
+		AWSCredentialsID:   createGatewayReq.CredentialsID,
 	}
 
-	err := s.gatewayRepository.CreateGateway(&gateway)
-
-	if err != nil {
+	if err := s.gatewayRepository.CreateGateway(&gateway); err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
 
-	ec2InstancePayload := types.CreateEC2InstanceReq{
-		CredentialsID:     createGatewayWithAWSReq.CredentialsID,
-		EC2InstanceTypeID: createGatewayWithAWSReq.EC2InstanceTypeID,
-		InstanceName:      createGatewayWithAWSReq.GatewayName,
-	}
-
-	instanceID, statusCode, err := s.ec2Service.CreateEC2Instance(ec2InstancePayload, userID)
-
+	instanceID, statusCode, err := provider.CreateInstance(ctx, instanceReq)
 	if err != nil {
-		gateway.Status = models.GatewayFailed
+		gateway.ErrorStatus = err.Error()
 
-		err := s.gatewayRepository.UpdateGateway(&gateway)
-
-		if err != nil {
+		if err := s.gatewayRepository.UpdateGatewayStatus(&gateway, models.GatewayFailed, "error_status"); err != nil {
 			return nil, http.StatusInternalServerError, err
 		}
 
@@ -103,40 +135,59 @@ func (s *GatewayService) CreateGatewayWithAWS(createGatewayWithAWSReq types.Crea
 
 	gateway.InstanceID = &instanceID
 
+	if err := s.gatewayRepository.UpdateGatewayColumns(&gateway, "instance_id"); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
 	client := s.getAsynqClient()
 
-	payload := types.DeployAWSGatewayPayload{
-		GatewayID:        gateway.ID,
-		UnhashedPassword: createGatewayWithAWSReq.Password,
-		CredentialsID:    gateway.AWSCredentialsID,
-		InstanceID:       instanceID,
-		UserID:           userID,
-		Region:           createGatewayWithAWSReq.Region,
-	}
+	defer client.Close()
 
-	task, err := s.gatewayTaskService.NewAWSDeployGatewayTask(payload)
+	task, err := s.gatewayTaskService.NewDeployGatewayTask(types.DeployGatewayPayload{
+		GatewayID:        gateway.ID,
+		OrganizationID:   organizationID,
+		UnhashedPassword: createGatewayReq.Password,
+	})
 	if err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
 
 	info, err := client.Enqueue(task)
 	if err != nil {
+		gateway.ErrorStatus = "unable to queue deploy task"
+
+		if err := s.gatewayRepository.UpdateGatewayStatus(&gateway, models.GatewayFailed, "error_status"); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+
 		return nil, http.StatusInternalServerError, errors.New("unable to queue task")
 	}
 
 	gateway.QueueID = &info.ID
 
-	if err := s.gatewayRepository.UpdateGateway(&gateway); err != nil {
+	if err := s.gatewayRepository.UpdateGatewayColumns(&gateway, "queue_id"); err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
 
-	defer client.Close()
-
 	return &gateway, http.StatusCreated, nil
 }
 
-func (s *GatewayService) GetUserGateways(userID uuid.UUID, params *middleware.QueryParams) (*[]models.Gateway, int, error) {
-	gateways, err := s.gatewayRepository.GetUserGateways(userID, params)
+func (s *GatewayService) CreateGatewayWithAWS(createGatewayWithAWSReq types.CreateGatewayWithAWSReq, organizationID uuid.UUID, userID uuid.UUID) (*models.Gateway, int, error) {
+	return s.CreateGateway(types.CreateGatewayReq{
+		Provider:           models.ProviderAWS,
+		CredentialsID:      createGatewayWithAWSReq.CredentialsID,
+		InstanceTypeID:     createGatewayWithAWSReq.EC2InstanceTypeID,
+		Region:             createGatewayWithAWSReq.Region,
+		RPCURL:             createGatewayWithAWSReq.RPCURL,
+		Password:           createGatewayWithAWSReq.Password,
+		GatewayType:        createGatewayWithAWSReq.GatewayType,
+		GatewayName:        createGatewayWithAWSReq.GatewayName,
+		TranscodingProfile: createGatewayWithAWSReq.TranscodingProfile,
+	}, organizationID, userID)
+}
+
+func (s *GatewayService) GetOrganizationGateways(organizationID uuid.UUID, params *middleware.QueryParams) (*[]models.Gateway, int, error) {
+	gateways, err := s.gatewayRepository.GetOrganizationGateways(organizationID, params)
 	if err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
@@ -144,8 +195,8 @@ func (s *GatewayService) GetUserGateways(userID uuid.UUID, params *middleware.Qu
 	return gateways, http.StatusOK, nil
 }
 
-func (s *GatewayService) GetUserGatewaysCount(userID uuid.UUID) (int64, error) {
-	count, err := s.gatewayRepository.GetUserGatewaysCount(userID)
+func (s *GatewayService) GetOrganizationGatewaysCount(organizationID uuid.UUID) (int64, error) {
+	count, err := s.gatewayRepository.GetOrganizationGatewaysCount(organizationID)
 	if err != nil {
 		return 0, err
 	}
@@ -153,4 +204,158 @@ func (s *GatewayService) GetUserGatewaysCount(userID uuid.UUID) (int64, error) {
 	return count, nil
 }
 
+// enqueueGatewayAction moves the gateway into the in-progress status of an
+// action and queues the task that carries it out. If the task cannot be queued
+// the gateway is moved to abortStatus instead.
+func (s *GatewayService) enqueueGatewayAction(
+	gateway *models.Gateway,
+	inProgressStatus models.GatewayStatus,
+	abortStatus models.GatewayStatus,
+	newTask func(types.GatewayActionPayload) (*asynq.Task, error),
+) (int, error) {
+	if !gateway.Status.CanTransitionTo(inProgressStatus) {
+		return http.StatusConflict, fmt.Errorf("gateway is %s and cannot be moved to %s", gateway.Status, inProgressStatus)
+	}
+
+	task, err := newTask(types.GatewayActionPayload{
+		GatewayID:      gateway.ID,
+		OrganizationID: gateway.OrganizationID,
+	})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	gateway.ErrorStatus = ""
+
+	if err := s.gatewayRepository.UpdateGatewayStatus(gateway, inProgressStatus, "error_status"); err != nil {
+		if errors.Is(err, models.ErrGatewayStatusChanged) {
+			return http.StatusConflict, err
+		}
+
+		return http.StatusInternalServerError, err
+	}
+
+	client := s.getAsynqClient()
+
+	defer client.Close()
+
+	info, err := client.Enqueue(task)
+	if err != nil {
+		gateway.ErrorStatus = "unable to queue task"
+
+		if err := s.gatewayRepository.UpdateGatewayStatus(gateway, abortStatus, "error_status"); err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		return http.StatusInternalServerError, errors.New("unable to queue task")
+	}
+
+	gateway.QueueID = &info.ID
+
+	if err := s.gatewayRepository.UpdateGatewayColumns(gateway, "queue_id"); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusAccepted, nil
+}
+
+func (s *GatewayService) DeleteGateway(gatewayID uuid.UUID, organizationID uuid.UUID) (*models.Gateway, int, error) {
+	gateway, result := s.gatewayRepository.GetGatewayByID(gatewayID, organizationID)
+	if result.RowsAffected == 0 {
+		return nil, http.StatusNotFound, errors.New("gateway not found")
+	}
+
+	return s.terminateGateway(gateway)
+}
+
+// ForceTerminateGateway terminates a gateway of any organization on behalf of
+// an admin.
+func (s *GatewayService) ForceTerminateGateway(gatewayID uuid.UUID) (*models.Gateway, int, error) {
+	gateway, result := s.gatewayRepository.GetAnyGatewayByID(gatewayID)
+	if result.RowsAffected == 0 {
+		return nil, http.StatusNotFound, errors.New("gateway not found")
+	}
+
+	return s.terminateGateway(gateway)
+}
+
+// terminateGateway cancels any pending task of the gateway and queues its
+// termination.
+func (s *GatewayService) terminateGateway(gateway *models.Gateway) (*models.Gateway, int, error) {
+	if !gateway.Status.CanTransitionTo(models.GatewayTerminating) {
+		return nil, http.StatusConflict, fmt.Errorf("gateway is %s and cannot be terminated", gateway.Status)
+	}
+
+	if gateway.QueueID != nil {
+		if err := s.cancelQueuedTask(*gateway.QueueID); err != nil {
+			return nil, http.StatusInternalServerError, errors.New("unable to cancel pending gateway task")
+		}
+	}
+
+	statusCode, err := s.enqueueGatewayAction(gateway, models.GatewayTerminating, models.GatewayFailed, s.gatewayTaskService.NewTerminateGatewayTask)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	return gateway, statusCode, nil
+}
+
+// terminateGateways queues the termination of every gateway that is not
+// already terminating, for callers that wait for all of them to be gone.
+func (s *GatewayService) terminateGateways(gateways []models.Gateway) error {
+	for _, gateway := range gateways {
+		if gateway.Status == models.GatewayTerminating {
+			continue
+		}
+
+		if _, _, err := s.terminateGateway(&gateway); err != nil {
+			return fmt.Errorf("unable to terminate gateway %s: %w", gateway.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *GatewayService) StopGateway(gatewayID uuid.UUID, organizationID uuid.UUID) (*models.Gateway, int, error) {
+	gateway, result := s.gatewayRepository.GetGatewayByID(gatewayID, organizationID)
+	if result.RowsAffected == 0 {
+		return nil, http.StatusNotFound, errors.New("gateway not found")
+	}
+
+	statusCode, err := s.enqueueGatewayAction(gateway, models.GatewayStopping, models.GatewayRunning, s.gatewayTaskService.NewStopGatewayTask)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	return gateway, statusCode, nil
+}
+
+func (s *GatewayService) StartGateway(gatewayID uuid.UUID, organizationID uuid.UUID) (*models.Gateway, int, error) {
+	gateway, result := s.gatewayRepository.GetGatewayByID(gatewayID, organizationID)
+	if result.RowsAffected == 0 {
+		return nil, http.StatusNotFound, errors.New("gateway not found")
+	}
+
+	statusCode, err := s.enqueueGatewayAction(gateway, models.GatewayStarting, models.GatewayStopped, s.gatewayTaskService.NewStartGatewayTask)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	return gateway, statusCode, nil
+}
+
+func (s *GatewayService) RebootGateway(gatewayID uuid.UUID, organizationID uuid.UUID) (*models.Gateway, int, error) {
+	gateway, result := s.gatewayRepository.GetGatewayByID(gatewayID, organizationID)
+	if result.RowsAffected == 0 {
+		return nil, http.StatusNotFound, errors.New("gateway not found")
+	}
+
+	statusCode, err := s.enqueueGatewayAction(gateway, models.GatewayRebooting, models.GatewayRunning, s.gatewayTaskService.NewRebootGatewayTask)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	return gateway, statusCode, nil
+}
+
 func (s *GatewayService) GetGatewayByInstanceID(instanceID string) {}
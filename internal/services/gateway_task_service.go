@@ -3,42 +3,93 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
-	"github.com/aws/aws-sdk-go-v2/service/ec2"
-	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
+	"gwid.io/gwid-core/internal/config"
+	"gwid.io/gwid-core/internal/models"
+	"gwid.io/gwid-core/internal/provisioning"
+	"gwid.io/gwid-core/internal/repositories"
 	"gwid.io/gwid-core/internal/types"
 	"gwid.io/gwid-core/internal/utils"
 )
 
 type GatewayTaskService struct {
-	awsCredentialsService *AWSCredentialsService
-	ec2Service            *EC2Service
+	config                  *config.Config
+	computeProviderRegistry *ComputeProviderRegistry
+	cloudflareService       *CloudflareService
+	gatewayRepository       *repositories.GatewayRepository
 }
 
-func NewGatewayTaskService(awsCredentialsService *AWSCredentialsService, ec2Service *EC2Service) *GatewayTaskService {
+func NewGatewayTaskService(
+	config *config.Config,
+	computeProviderRegistry *ComputeProviderRegistry,
+	cloudflareService *CloudflareService,
+	gatewayRepository *repositories.GatewayRepository,
+) *GatewayTaskService {
 	return &GatewayTaskService{
-		awsCredentialsService: awsCredentialsService,
-		ec2Service:            ec2Service,
+		config:                  config,
+		computeProviderRegistry: computeProviderRegistry,
+		cloudflareService:       cloudflareService,
+		gatewayRepository:       gatewayRepository,
 	}
 }
 
-func (gt *GatewayTaskService) NewAWSDeployGatewayTask(payload types.DeployAWSGatewayPayload) (*asynq.Task, error) {
+// getGatewayInstance resolves the provider of the gateway and the target that
+// addresses its instance on that provider.
+func (gt *GatewayTaskService) getGatewayInstance(gateway *models.Gateway) (ComputeProvider, types.InstanceTarget, error) {
+	provider, err := gt.computeProviderRegistry.GetProvider(gateway.Provider)
+	if err != nil {
+		return nil, types.InstanceTarget{}, fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+
+	if gateway.InstanceID == nil {
+		return nil, types.InstanceTarget{}, fmt.Errorf("gateway %s has no instance: %w", gateway.ID, asynq.SkipRetry)
+	}
+
+	return provider, types.InstanceTarget{
+		InstanceID:     *gateway.InstanceID,
+		Region:         gateway.Region,
+		CredentialsID:  gateway.AWSCredentialsID,
+		OrganizationID: gateway.OrganizationID,
+	}, nil
+}
+
+func (gt *GatewayTaskService) NewDeployGatewayTask(payload types.DeployGatewayPayload) (*asynq.Task, error) {
 	payloadJson, err := json.Marshal(payload)
 	if err != nil {
 		return nil, err
 	}
 
-	task := asynq.NewTask(utils.TypeDeployAWSGateway, payloadJson, asynq.MaxRetry(2), asynq.Timeout(5*time.Minute))
+	task := asynq.NewTask(utils.TypeDeployGateway, payloadJson, asynq.MaxRetry(2), asynq.Timeout(30*time.Minute))
 
 	return task, nil
 }
 
+func (gt *GatewayTaskService) HandleDeployGatewayTask(ctx context.Context, task *asynq.Task) error {
+	var payload types.DeployGatewayPayload
+
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("json.Unmarsal failed: %v: %w", err, asynq.SkipRetry)
+	}
+
+	log.Println("processing task", task.ResultWriter().TaskID())
+
+	gateway, result := gt.gatewayRepository.GetGatewayByID(payload.GatewayID, payload.OrganizationID)
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("gateway %s not found: %w", payload.GatewayID, asynq.SkipRetry)
+	}
+
+	return gt.runDeployGateway(ctx, gateway, payload.UnhashedPassword)
+}
+
+// HandleAWSDeployGatewayTask deploys gateways queued with the AWS-only deploy
+// task. The instance was only saved on the gateway after such tasks were
+// queued, so it is taken from the payload when missing.
 func (gt *GatewayTaskService) HandleAWSDeployGatewayTask(ctx context.Context, task *asynq.Task) error {
 	var payload types.DeployAWSGatewayPayload
 
@@ -48,62 +99,372 @@ func (gt *GatewayTaskService) HandleAWSDeployGatewayTask(ctx context.Context, ta
 
 	log.Println("processing task", task.ResultWriter().TaskID())
 
-	userCreds, _, err := gt.awsCredentialsService.GetAWSCredentialsByID(payload.CredentialsID, payload.UserID)
+	gateway, err := gt.getUserGateway(payload.GatewayID, payload.UserID)
+	if err != nil {
+		return err
+	}
+
+	if gateway.InstanceID == nil && payload.InstanceID != "" {
+		gateway.InstanceID = &payload.InstanceID
+
+		if err := gt.gatewayRepository.UpdateGatewayColumns(gateway, "instance_id"); err != nil {
+			return fmt.Errorf("unable to save gateway instance: %w", err)
+		}
+	}
+
+	return gt.runDeployGateway(ctx, gateway, payload.UnhashedPassword)
+}
+
+func (gt *GatewayTaskService) runDeployGateway(ctx context.Context, gateway *models.Gateway, password string) error {
+	// Another operation, e.g. a termination, took the gateway over while a
+	// retry was pending.
+	if gateway.Status != models.GatewayProvisioning && !gateway.Status.CanTransitionTo(models.GatewayProvisioning) {
+		return fmt.Errorf("gateway %s is %s: %w", gateway.ID, gateway.Status, asynq.SkipRetry)
+	}
+
+	if err := gt.deployGateway(ctx, gateway, password); err != nil {
+		return gt.recordTaskFailure(ctx, gateway, models.GatewayFailed, err)
+	}
+
+	gateway.ErrorStatus = ""
+
+	if err := gt.gatewayRepository.UpdateGatewayStatus(gateway, models.GatewayRunning, "error_status"); err != nil {
+		return fmt.Errorf("unable to mark gateway running: %w", statusWriteError(err))
+	}
+
+	log.Printf("gateway %s deployed", gateway.ID)
+
+	return nil
+}
+
+// deployGateway runs every step of a deployment. Errors of the instance, SSM
+// and DNS APIs are left retryable; a retried task starts over from the
+// instance checks, which pass at once for an instance that is already up.
+func (gt *GatewayTaskService) deployGateway(ctx context.Context, gateway *models.Gateway, password string) error {
+	script, err := provisioning.RenderGatewayDeployScript(types.GatewayDeployScriptParams{
+		GatewayName:        gateway.GatewayName,
+		GatewayType:        gateway.GatewayType,
+		RPCURL:             gateway.RPCURL,
+		Password:           password,
+		TranscodingProfile: gateway.TranscodingProfile,
+		LivepeerVersion:    gt.config.LivepeerVersion,
+	})
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+
+	provider, target, err := gt.getGatewayInstance(gateway)
 	if err != nil {
-		return fmt.Errorf("unable to get user AWS credentials: %v: %w", err, asynq.SkipRetry)
+		return err
 	}
 
-	creds := credentials.NewStaticCredentialsProvider(userCreds.AccessKeyID, userCreds.SecretAccessKey, "")
+	if err := provider.WaitForInstanceRunning(ctx, target); err != nil {
+		return fmt.Errorf("unable to get instance running state: %w", err)
+	}
 
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
-		config.WithCredentialsProvider(creds),
-		config.WithRegion(payload.Region),
-	)
+	ipAddress, err := provider.GetIPAddress(ctx, target)
 	if err != nil {
-		return fmt.Errorf("unable to load AWS config: %v: %w", err, asynq.SkipRetry)
+		return err
+	}
+
+	gateway.PublicIP = &ipAddress
+
+	if err := gt.registerGatewayDNS(gateway, ipAddress); err != nil {
+		return err
 	}
 
-	ec2Client := ec2.NewFromConfig(cfg)
+	if err := gt.setDeployStatus(gateway, models.GatewayProvisioning, "public_ip", "subdomain", "dns_zone_id"); err != nil {
+		return err
+	}
 
-	ssmClient := ssm.NewFromConfig(cfg)
+	if err := provider.WaitForInstanceReady(ctx, target); err != nil {
+		return err
+	}
 
-	if err := gt.ec2Service.WaitForInstanceRunning(payload.InstanceID, ctx, ec2Client); err != nil {
-		return fmt.Errorf("unable to get instance running state: %v: %w", err, asynq.SkipRetry)
+	if err := gt.setDeployStatus(gateway, models.GatewayDeploying); err != nil {
+		return err
 	}
 
-	if err := gt.ec2Service.WaitForSSM(payload.InstanceID, ctx, ssmClient); err != nil {
+	commandResult, err := provider.RunCommand(ctx, target, script)
+	if err != nil {
+		return fmt.Errorf("something went wrong with command execution: %w", err)
+	}
+
+	log.Printf("deploy command %s for gateway %s finished with status %s (exit code %d) in %v",
+		commandResult.CommandID, gateway.ID, commandResult.Status, commandResult.ExitCode, commandResult.ExecutionTime)
+
+	deployResult, err := provisioning.ParseGatewayDeployResult(commandResult.StandardOut)
+	if err != nil {
+		if commandResult.StandardErr != "" {
+			log.Printf("deploy command %s stderr:\n%s", commandResult.CommandID, commandResult.StandardErr)
+		}
+
 		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
 	}
 
-	command := `echo "{\"success\": true, \"grafana_url\": \"https://gwid.io\"}"`
+	if !deployResult.Success {
+		return fmt.Errorf("deployment failed at step %s: %s: %w", deployResult.Step, deployResult.Error, asynq.SkipRetry)
+	}
+
+	return nil
+}
+
+// setDeployStatus moves the gateway to a step of the deployment and saves the
+// given columns. A retried task finds the gateway at the step the previous
+// attempt reached, in which case only the columns are saved.
+func (gt *GatewayTaskService) setDeployStatus(gateway *models.Gateway, next models.GatewayStatus, columns ...string) error {
+	if gateway.Status != next {
+		return statusWriteError(gt.gatewayRepository.UpdateGatewayStatus(gateway, next, columns...))
+	}
+
+	if len(columns) == 0 {
+		return nil
+	}
+
+	return gt.gatewayRepository.UpdateGatewayColumns(gateway, columns...)
+}
+
+// statusWriteError stops retries once another operation changed the status of
+// the gateway. Database errors stay retryable.
+func statusWriteError(err error) error {
+	if errors.Is(err, models.ErrGatewayStatusChanged) || errors.Is(err, models.ErrInvalidGatewayTransition) {
+		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+
+	return err
+}
+
+func (gt *GatewayTaskService) newGatewayActionTask(taskType string, payload types.GatewayActionPayload, opts ...asynq.Option) (*asynq.Task, error) {
+	payloadJson, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return asynq.NewTask(taskType, payloadJson, opts...), nil
+}
+
+func (gt *GatewayTaskService) NewTerminateGatewayTask(payload types.GatewayActionPayload) (*asynq.Task, error) {
+	return gt.newGatewayActionTask(utils.TypeTerminateGateway, payload, asynq.MaxRetry(5), asynq.Timeout(10*time.Minute))
+}
+
+func (gt *GatewayTaskService) NewStopGatewayTask(payload types.GatewayActionPayload) (*asynq.Task, error) {
+	return gt.newGatewayActionTask(utils.TypeStopGateway, payload, asynq.MaxRetry(3), asynq.Timeout(10*time.Minute))
+}
+
+func (gt *GatewayTaskService) NewStartGatewayTask(payload types.GatewayActionPayload) (*asynq.Task, error) {
+	return gt.newGatewayActionTask(utils.TypeStartGateway, payload, asynq.MaxRetry(3), asynq.Timeout(10*time.Minute))
+}
+
+func (gt *GatewayTaskService) NewRebootGatewayTask(payload types.GatewayActionPayload) (*asynq.Task, error) {
+	return gt.newGatewayActionTask(utils.TypeRebootGateway, payload, asynq.MaxRetry(3), asynq.Timeout(15*time.Minute))
+}
+
+func (gt *GatewayTaskService) getActionGateway(task *asynq.Task) (*models.Gateway, error) {
+	var payload types.GatewayActionPayload
+
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return nil, fmt.Errorf("json.Unmarsal failed: %v: %w", err, asynq.SkipRetry)
+	}
+
+	log.Println("processing task", task.ResultWriter().TaskID())
 
-	// command := "ls -la /home && echo 'Hello from EC2!' && date"
+	gateway, result := gt.gatewayRepository.GetGatewayByID(payload.GatewayID, payload.OrganizationID)
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("gateway %s not found: %w", payload.GatewayID, asynq.SkipRetry)
+	}
+
+	return gateway, nil
+}
+
+// getUserGateway finds the gateway of a task queued with the AWS-only deploy
+// task, which addressed gateways by the user who created them.
+func (gt *GatewayTaskService) getUserGateway(gatewayID uuid.UUID, userID uuid.UUID) (*models.Gateway, error) {
+	gateway, result := gt.gatewayRepository.GetAnyGatewayByID(gatewayID)
+	if result.RowsAffected == 0 || gateway.UserID != userID {
+		return nil, fmt.Errorf("gateway %s not found: %w", gatewayID, asynq.SkipRetry)
+	}
+
+	return gateway, nil
+}
+
+// recordTaskFailure stores the error on the gateway. The gateway is only moved
+// to failedStatus once asynq has no retries left, so that the in-progress
+// status keeps blocking conflicting actions while a retry is pending. Stop,
+// start and reboot fall back to the settled status they started from rather
+// than failed, since the instance is still there. Nothing is written if
+// another operation changed the status since the task loaded it.
+func (gt *GatewayTaskService) recordTaskFailure(ctx context.Context, gateway *models.Gateway, failedStatus models.GatewayStatus, taskErr error) error {
+	gateway.ErrorStatus = taskErr.Error()
+
+	retried, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+
+	var err error
+
+	if retried >= maxRetry || errors.Is(taskErr, asynq.SkipRetry) {
+		err = gt.gatewayRepository.UpdateGatewayStatus(gateway, failedStatus, "error_status")
+	} else {
+		err = gt.gatewayRepository.UpdateGatewayColumns(gateway, "error_status")
+	}
 
-	commandID, err := gt.ec2Service.RunCommand(payload.InstanceID, ctx, command, ssmClient)
 	if err != nil {
-		return fmt.Errorf("something went wrong: %v: %w", err, asynq.SkipRetry)
+		log.Printf("unable to record failure of gateway %s: %v", gateway.ID, err)
 	}
 
-	commandResult, err := gt.ec2Service.WaitForCommandCompletion(payload.InstanceID, ctx, commandID, ssmClient)
+	return taskErr
+}
+
+func (gt *GatewayTaskService) HandleTerminateGatewayTask(ctx context.Context, task *asynq.Task) error {
+	gateway, err := gt.getActionGateway(task)
 	if err != nil {
-		fmt.Println(err)
-		return fmt.Errorf("something went wrong with command execution: %v: %w", err, asynq.SkipRetry)
+		return err
+	}
+
+	if err := gt.TerminateGateway(ctx, gateway); err != nil {
+		return gt.recordTaskFailure(ctx, gateway, models.GatewayFailed, err)
+	}
+
+	if err := gt.gatewayRepository.DeleteGateway(gateway); err != nil {
+		return fmt.Errorf("unable to delete gateway: %w", err)
+	}
+
+	log.Printf("gateway %s terminated", gateway.ID)
+
+	return nil
+}
+
+// TerminateGateway releases every cloud resource held by the gateway. Each
+// step tolerates resources that are already gone so it is safe to retry.
+func (gt *GatewayTaskService) TerminateGateway(ctx context.Context, gateway *models.Gateway) error {
+	if gateway.InstanceID != nil {
+		provider, target, err := gt.getGatewayInstance(gateway)
+		if err != nil {
+			return err
+		}
+
+		if err := provider.TerminateInstance(ctx, target); err != nil {
+			return err
+		}
+	}
+
+	if gt.cloudflareService.IsConfigured() {
+		var zoneID string
+
+		if gateway.DNSZoneID != nil {
+			zoneID = *gateway.DNSZoneID
+		} else {
+			// Gateways deployed before DNS registration was recorded may still
+			// have a record in the configured zone.
+			id, err := gt.cloudflareService.GetZoneID()
+			if err != nil {
+				return fmt.Errorf("unable to get cloudflare zone: %w", err)
+			}
+
+			zoneID = id
+		}
+
+		if err := gt.cloudflareService.RemoveGatewayFromCloudflare(zoneID, gateway.GatewayName); err != nil {
+			return fmt.Errorf("unable to remove dns record: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// registerGatewayDNS points <gateway-name>.<zone> at the given address and
+// records the hostname on the gateway. The caller persists the gateway.
+func (gt *GatewayTaskService) registerGatewayDNS(gateway *models.Gateway, ipAddress string) error {
+	if !gt.cloudflareService.IsConfigured() {
+		return nil
 	}
 
-	fmt.Printf("\n=== Command Execution Result ===\n")
-	fmt.Printf("Command ID: %s\n", commandResult.CommandID)
-	fmt.Printf("Status: %s\n", commandResult.Status)
-	fmt.Printf("Exit Code: %d\n", commandResult.ExitCode)
-	fmt.Printf("Execution Time: %v\n", commandResult.ExecutionTime)
+	if gateway.DNSZoneID != nil {
+		if err := gt.cloudflareService.UpdateGatewayInCloudflare(*gateway.DNSZoneID, ipAddress, gateway.GatewayName); err != nil {
+			return fmt.Errorf("unable to update dns record: %w", err)
+		}
 
-	if commandResult.StandardOut != "" {
-		fmt.Printf("\n--- Standard Output ---\n%s\n", commandResult.StandardOut)
+		return nil
 	}
 
-	if commandResult.StandardErr != "" {
-		fmt.Printf("\n--- Standard Error ---\n%s\n", commandResult.StandardErr)
+	record, err := gt.cloudflareService.AddGatewayToCloudflare(ipAddress, gateway.GatewayName)
+	if err != nil {
+		return fmt.Errorf("unable to create dns record: %w", err)
 	}
-	fmt.Printf("===============================\n")
+
+	gateway.Subdomain = &record.Subdomain
+	gateway.DNSZoneID = &record.ZoneID
 
 	return nil
 }
+
+func (gt *GatewayTaskService) HandleStopGatewayTask(ctx context.Context, task *asynq.Task) error {
+	gateway, err := gt.getActionGateway(task)
+	if err != nil {
+		return err
+	}
+
+	provider, target, err := gt.getGatewayInstance(gateway)
+	if err != nil {
+		return gt.recordTaskFailure(ctx, gateway, models.GatewayRunning, err)
+	}
+
+	if err := provider.StopInstance(ctx, target); err != nil {
+		return gt.recordTaskFailure(ctx, gateway, models.GatewayRunning, err)
+	}
+
+	// Stopped instances give up their public address.
+	gateway.ErrorStatus = ""
+	gateway.PublicIP = nil
+
+	return gt.gatewayRepository.UpdateGatewayStatus(gateway, models.GatewayStopped, "error_status", "public_ip")
+}
+
+func (gt *GatewayTaskService) HandleStartGatewayTask(ctx context.Context, task *asynq.Task) error {
+	gateway, err := gt.getActionGateway(task)
+	if err != nil {
+		return err
+	}
+
+	provider, target, err := gt.getGatewayInstance(gateway)
+	if err != nil {
+		return gt.recordTaskFailure(ctx, gateway, models.GatewayStopped, err)
+	}
+
+	if err := provider.StartInstance(ctx, target); err != nil {
+		return gt.recordTaskFailure(ctx, gateway, models.GatewayStopped, err)
+	}
+
+	ipAddress, err := provider.GetIPAddress(ctx, target)
+	if err != nil {
+		return gt.recordTaskFailure(ctx, gateway, models.GatewayStopped, err)
+	}
+
+	if err := gt.registerGatewayDNS(gateway, ipAddress); err != nil {
+		return gt.recordTaskFailure(ctx, gateway, models.GatewayStopped, err)
+	}
+
+	gateway.ErrorStatus = ""
+	gateway.PublicIP = &ipAddress
+
+	return gt.gatewayRepository.UpdateGatewayStatus(gateway, models.GatewayRunning, "error_status", "public_ip", "subdomain", "dns_zone_id")
+}
+
+func (gt *GatewayTaskService) HandleRebootGatewayTask(ctx context.Context, task *asynq.Task) error {
+	gateway, err := gt.getActionGateway(task)
+	if err != nil {
+		return err
+	}
+
+	provider, target, err := gt.getGatewayInstance(gateway)
+	if err != nil {
+		return gt.recordTaskFailure(ctx, gateway, models.GatewayRunning, err)
+	}
+
+	if err := provider.RebootInstance(ctx, target); err != nil {
+		return gt.recordTaskFailure(ctx, gateway, models.GatewayRunning, err)
+	}
+
+	gateway.ErrorStatus = ""
+
+	return gt.gatewayRepository.UpdateGatewayStatus(gateway, models.GatewayRunning, "error_status")
+}
@@ -1,14 +1,26 @@
 package services
 
 import (
+	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"gwid.io/gwid-core/internal/config"
 	"gwid.io/gwid-core/internal/models"
 	"gwid.io/gwid-core/internal/types"
 )
 
+const (
+	AccessTokenTTL        = 15 * time.Minute
+	RefreshTokenTTL       = 30 * 24 * time.Hour
+	TwoFactorChallengeTTL = 5 * time.Minute
+)
+
+// twoFactorChallengeAudience keeps challenge tokens and access tokens from
+// being accepted in place of each other.
+const twoFactorChallengeAudience = "gwid-2fa-challenge"
+
 type JwtService struct {
 	config *config.Config
 }
@@ -19,12 +31,13 @@ func NewJwtService(config *config.Config) *JwtService {
 	}
 }
 
-func (s *JwtService) SignJWT(user *models.User) (string, error) {
+func (s *JwtService) SignJWT(user *models.User, sessionID uuid.UUID) (string, error) {
 	claims := &types.JwtCustomClaims{
-		ID:   user.ID,
-		Role: string(user.Role),
+		ID:        user.ID,
+		Role:      string(user.Role),
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24 * 30)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    "gwid-core",
@@ -37,3 +50,38 @@ func (s *JwtService) SignJWT(user *models.User) (string, error) {
 
 	return tokenString, err
 }
+
+func (s *JwtService) SignTwoFactorChallenge(user *models.User) (string, error) {
+	claims := &types.TwoFactorChallengeClaims{
+		ID: user.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{twoFactorChallengeAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TwoFactorChallengeTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    "gwid-core",
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+
+	return token.SignedString([]byte(s.config.JwtSecret))
+}
+
+// ParseTwoFactorChallenge returns the ID of the user a challenge token was
+// issued to.
+func (s *JwtService) ParseTwoFactorChallenge(tokenString string) (uuid.UUID, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &types.TwoFactorChallengeClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return []byte(s.config.JwtSecret), nil
+	}, jwt.WithAudience(twoFactorChallengeAudience), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return uuid.Nil, errors.New("invalid or expired challenge token")
+	}
+
+	claims, ok := token.Claims.(*types.TwoFactorChallengeClaims)
+	if !ok {
+		return uuid.Nil, errors.New("invalid or expired challenge token")
+	}
+
+	return claims.ID, nil
+}
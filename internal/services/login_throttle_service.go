@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gwid.io/gwid-core/internal/types"
+)
+
+const (
+	loginThrottlePrefix = "login-throttle:"
+	// loginFailureWindow is how long failed attempts are remembered, counted
+	// from the first one.
+	loginFailureWindow = 15 * time.Minute
+	// loginDelayThreshold failures in a row make every further attempt on the
+	// account wait, twice as long after each failure up to loginMaxDelay.
+	loginDelayThreshold = 3
+	loginMaxDelay       = 30 * time.Second
+	// accountLockoutThreshold failures lock the account for accountLockoutTTL.
+	accountLockoutThreshold = 10
+	accountLockoutTTL       = 15 * time.Minute
+	// ipLockoutThreshold is higher than the account threshold as offices and
+	// carrier NATs share an address.
+	ipLockoutThreshold = 50
+	ipLockoutTTL       = 15 * time.Minute
+	signupWindow       = time.Hour
+	signupLimit        = 10
+)
+
+// LoginThrottledError is returned while an account or address has to wait
+// before trying again.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("too many attempts, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// LoginThrottleService counts failed logins per account and per IP address in
+// Redis, so that the counters are shared by every replica. When Redis cannot
+// be reached it lets requests through rather than locking everyone out.
+type LoginThrottleService struct {
+	redis *redis.Client
+}
+
+func NewLoginThrottleService(redis *redis.Client) *LoginThrottleService {
+	return &LoginThrottleService{
+		redis: redis,
+	}
+}
+
+func accountThrottleKey(kind string, email string) string {
+	return loginThrottlePrefix + kind + ":account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipThrottleKey(kind string, ip string) string {
+	return loginThrottlePrefix + kind + ":ip:" + ip
+}
+
+// loginDelay is the wait after the given number of failures in a row.
+func loginDelay(failures int64) time.Duration {
+	if failures < loginDelayThreshold {
+		return 0
+	}
+
+	delay := time.Second << min(failures-loginDelayThreshold, 8)
+
+	return min(delay, loginMaxDelay)
+}
+
+// CheckLogin returns a LoginThrottledError if the account or the address is
+// locked out or still has to wait after a failed attempt.
+func (s *LoginThrottleService) CheckLogin(email string, ip string) error {
+	ctx := context.Background()
+
+	pipe := s.redis.Pipeline()
+
+	ttls := []*redis.DurationCmd{
+		pipe.PTTL(ctx, accountThrottleKey("lock", email)),
+		pipe.PTTL(ctx, accountThrottleKey("delay", email)),
+		pipe.PTTL(ctx, ipThrottleKey("lock", ip)),
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("unable to check login throttle: %v", err)
+		return nil
+	}
+
+	var retryAfter time.Duration
+
+	for _, ttl := range ttls {
+		retryAfter = max(retryAfter, ttl.Val())
+	}
+
+	if retryAfter > 0 {
+		return &LoginThrottledError{RetryAfter: retryAfter}
+	}
+
+	return nil
+}
+
+// RecordLoginFailure counts a failed attempt and reports whether it locked
+// the account.
+func (s *LoginThrottleService) RecordLoginFailure(email string, ip string) bool {
+	ctx := context.Background()
+
+	accountFailures, err := s.incrementCounter(ctx, accountThrottleKey("failures", email), loginFailureWindow)
+	if err != nil {
+		log.Printf("unable to count failed login: %v", err)
+		return false
+	}
+
+	ipFailures, err := s.incrementCounter(ctx, ipThrottleKey("failures", ip), loginFailureWindow)
+	if err != nil {
+		log.Printf("unable to count failed login: %v", err)
+		return false
+	}
+
+	if ipFailures >= ipLockoutThreshold {
+		pipe := s.redis.TxPipeline()
+		pipe.Set(ctx, ipThrottleKey("lock", ip), 1, ipLockoutTTL)
+		pipe.Del(ctx, ipThrottleKey("failures", ip))
+
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Printf("unable to lock out %s: %v", ip, err)
+		}
+	}
+
+	if accountFailures >= accountLockoutThreshold {
+		pipe := s.redis.TxPipeline()
+		pipe.Set(ctx, accountThrottleKey("lock", email), 1, accountLockoutTTL)
+		pipe.Del(ctx, accountThrottleKey("failures", email), accountThrottleKey("delay", email))
+
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Printf("unable to lock account: %v", err)
+			return false
+		}
+
+		return true
+	}
+
+	if delay := loginDelay(accountFailures); delay > 0 {
+		if err := s.redis.Set(ctx, accountThrottleKey("delay", email), 1, delay).Err(); err != nil {
+			log.Printf("unable to delay logins: %v", err)
+		}
+	}
+
+	return false
+}
+
+// incrementScript increments the counter in KEYS[1] and starts its window of
+// ARGV[1] milliseconds unless one is running, in a single step so that a
+// counter can never be left without an expiry.
+var incrementScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if redis.call("PTTL", KEYS[1]) < 0 then
+  redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// incrementCounter counts an event in a window that starts with the first
+// event.
+func (s *LoginThrottleService) incrementCounter(ctx context.Context, key string, window time.Duration) (int64, error) {
+	return incrementScript.Run(ctx, s.redis, []string{key}, window.Milliseconds()).Int64()
+}
+
+// RecordLoginSuccess forgets the failed attempts on the account. Those of the
+// address are kept, as one valid account says nothing about the others tried
+// from it.
+func (s *LoginThrottleService) RecordLoginSuccess(email string) {
+	ctx := context.Background()
+
+	if err := s.redis.Del(ctx, accountThrottleKey("failures", email), accountThrottleKey("delay", email)).Err(); err != nil {
+		log.Printf("unable to reset failed logins: %v", err)
+	}
+}
+
+// AllowSignup counts a signup attempt from an address and returns a
+// LoginThrottledError once it made too many.
+func (s *LoginThrottleService) AllowSignup(ip string) error {
+	ctx := context.Background()
+	key := ipThrottleKey("signups", ip)
+
+	attempts, err := s.incrementCounter(ctx, key, signupWindow)
+	if err != nil {
+		log.Printf("unable to count signup: %v", err)
+		return nil
+	}
+
+	if attempts <= signupLimit {
+		return nil
+	}
+
+	retryAfter, err := s.redis.PTTL(ctx, key).Result()
+	if err != nil || retryAfter <= 0 {
+		retryAfter = signupWindow
+	}
+
+	return &LoginThrottledError{RetryAfter: retryAfter}
+}
+
+func (s *LoginThrottleService) GetAccountLockout(email string) (*types.LoginLockoutRes, error) {
+	ctx := context.Background()
+
+	pipe := s.redis.Pipeline()
+	lockTTL := pipe.PTTL(ctx, accountThrottleKey("lock", email))
+	delayTTL := pipe.PTTL(ctx, accountThrottleKey("delay", email))
+	failures := pipe.Get(ctx, accountThrottleKey("failures", email))
+
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	failedAttempts, _ := failures.Int64()
+
+	res := &types.LoginLockoutRes{
+		Locked:         lockTTL.Val() > 0,
+		FailedAttempts: failedAttempts,
+	}
+
+	if retryAfter := max(lockTTL.Val(), delayTTL.Val()); retryAfter > 0 {
+		until := time.Now().Add(retryAfter)
+		res.RetryAt = &until
+	}
+
+	return res, nil
+}
+
+// ClearAccountLockout lifts a lockout and forgets the failed attempts on an
+// account.
+func (s *LoginThrottleService) ClearAccountLockout(email string) error {
+	ctx := context.Background()
+
+	return s.redis.Del(ctx, accountThrottleKey("lock", email), accountThrottleKey("delay", email), accountThrottleKey("failures", email)).Err()
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"gwid.io/gwid-core/internal/config"
+	"gwid.io/gwid-core/internal/models"
+	"gwid.io/gwid-core/internal/oauth"
+	"gwid.io/gwid-core/internal/repositories"
+	"gwid.io/gwid-core/internal/types"
+)
+
+const (
+	oauthStateTTL        = 10 * time.Minute
+	oauthExchangeTimeout = 15 * time.Second
+)
+
+var errInvalidOAuthState = errors.New("invalid or expired login attempt")
+
+type OAuthService struct {
+	cfg             *config.Config
+	registry        *oauth.Registry
+	oauthRepository *repositories.OAuthRepository
+	userRepository  *repositories.UserRepository
+	userService     *UserService
+	authService     *AuthService
+}
+
+func NewOAuthService(
+	cfg *config.Config,
+	registry *oauth.Registry,
+	oauthRepository *repositories.OAuthRepository,
+	userRepository *repositories.UserRepository,
+	userService *UserService,
+	authService *AuthService,
+) *OAuthService {
+	return &OAuthService{
+		cfg:             cfg,
+		registry:        registry,
+		oauthRepository: oauthRepository,
+		userRepository:  userRepository,
+		userService:     userService,
+		authService:     authService,
+	}
+}
+
+func (s *OAuthService) GetProviders() []string {
+	names := s.registry.Names()
+
+	slices.Sort(names)
+
+	return names
+}
+
+// Authorize starts a login with the provider and returns the URL to send the
+// user to. The provider redirects back to OAUTH_REDIRECT_URL with the code and
+// state, which the app posts to Callback.
+func (s *OAuthService) Authorize(providerName string) (string, int, error) {
+	provider, err := s.registry.Get(providerName)
+	if err != nil {
+		return "", http.StatusNotFound, err
+	}
+
+	var authRequest oauth.AuthRequest
+
+	for _, value := range []*string{&authRequest.State, &authRequest.Nonce, &authRequest.CodeVerifier} {
+		if *value, err = oauth.RandomString(32); err != nil {
+			return "", http.StatusInternalServerError, err
+		}
+	}
+
+	authRequest.RedirectURI = s.cfg.OAuthRedirectURL
+
+	ctx, cancel := context.WithTimeout(context.Background(), oauthExchangeTimeout)
+	defer cancel()
+
+	authURL, err := provider.AuthCodeURL(ctx, authRequest)
+	if err != nil {
+		return "", http.StatusBadGateway, err
+	}
+
+	if err := s.oauthRepository.CreateOAuthState(&models.OAuthState{
+		Provider:     providerName,
+		StateHash:    hashToken(authRequest.State),
+		Nonce:        authRequest.Nonce,
+		CodeVerifier: authRequest.CodeVerifier,
+		RedirectURI:  authRequest.RedirectURI,
+		ExpiresAt:    time.Now().Add(oauthStateTTL),
+	}); err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+
+	return authURL, http.StatusOK, nil
+}
+
+// Callback finishes a login started by Authorize. The user is found by the
+// identity linked to them, by a verified email address, or created.
+func (s *OAuthService) Callback(providerName string, oauthCallbackReq types.OAuthCallbackReq, meta types.SessionMeta) (types.AuthRes, *types.TwoFactorChallengeRes, int, error) {
+	provider, err := s.registry.Get(providerName)
+	if err != nil {
+		return types.AuthRes{}, nil, http.StatusNotFound, err
+	}
+
+	oauthState, result := s.oauthRepository.GetOAuthStateByHash(hashToken(oauthCallbackReq.State))
+	if result.RowsAffected == 0 || !oauthState.IsUsable() || oauthState.Provider != providerName {
+		return types.AuthRes{}, nil, http.StatusBadRequest, errInvalidOAuthState
+	}
+
+	consumed, err := s.oauthRepository.ConsumeOAuthState(oauthState)
+	if err != nil {
+		return types.AuthRes{}, nil, http.StatusInternalServerError, err
+	}
+
+	if !consumed {
+		return types.AuthRes{}, nil, http.StatusBadRequest, errInvalidOAuthState
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), oauthExchangeTimeout)
+	defer cancel()
+
+	identity, err := provider.Exchange(ctx, oauthCallbackReq.Code, oauth.AuthRequest{
+		State:        oauthCallbackReq.State,
+		Nonce:        oauthState.Nonce,
+		CodeVerifier: oauthState.CodeVerifier,
+		RedirectURI:  oauthState.RedirectURI,
+	})
+	if err != nil {
+		return types.AuthRes{}, nil, http.StatusUnauthorized, err
+	}
+
+	user, statusCode, err := s.findOrCreateUser(providerName, identity)
+	if err != nil {
+		return types.AuthRes{}, nil, statusCode, err
+	}
+
+	authRes, challengeRes, err := s.authService.completeLogin(user, meta)
+	if err != nil {
+		if errors.Is(err, ErrAccountSuspended) || errors.Is(err, ErrAccountDeleted) {
+			return types.AuthRes{}, nil, http.StatusForbidden, err
+		}
+
+		return types.AuthRes{}, nil, http.StatusInternalServerError, err
+	}
+
+	return authRes, challengeRes, http.StatusOK, nil
+}
+
+func (s *OAuthService) findOrCreateUser(providerName string, identity *oauth.Identity) (*models.User, int, error) {
+	now := time.Now()
+
+	userIdentity, result := s.oauthRepository.GetUserIdentity(providerName, identity.Subject)
+	if result.RowsAffected > 0 {
+		user, result := s.userRepository.FindByID(userIdentity.UserID)
+		if result.RowsAffected == 0 {
+			return nil, http.StatusUnauthorized, errors.New("user not found")
+		}
+
+		userIdentity.Email = identity.Email
+		userIdentity.LastLoginAt = &now
+
+		if err := s.oauthRepository.UpdateUserIdentityColumns(userIdentity, "email", "last_login_at"); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+
+		return user, http.StatusOK, nil
+	}
+
+	// Linking by an unverified address would let anyone who can register it
+	// at the provider take over the account.
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, http.StatusBadRequest, errors.New("the provider did not return a verified email address")
+	}
+
+	user, result := s.userRepository.FindByEmail(identity.Email)
+	if result.RowsAffected > 0 {
+		if !user.IsEmailVerified() {
+			user.EmailVerifiedAt = &now
+
+			if err := s.userRepository.UpdateUserColumns(user, "email_verified_at"); err != nil {
+				return nil, http.StatusInternalServerError, err
+			}
+		}
+	} else {
+		createdUser, err := s.createUser(identity)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+
+		user = createdUser
+	}
+
+	if err := s.oauthRepository.CreateUserIdentity(&models.UserIdentity{
+		UserID:      user.ID,
+		Provider:    providerName,
+		Subject:     identity.Subject,
+		Email:       identity.Email,
+		LastLoginAt: &now,
+	}); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return user, http.StatusOK, nil
+}
+
+// createUser signs up a user who has no password. They can set one with the
+// password reset flow.
+func (s *OAuthService) createUser(identity *oauth.Identity) (*models.User, error) {
+	referralCode, err := s.userService.GenerateUniqueReferralCode()
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	user := &models.User{
+		Name:            oauthUserName(identity),
+		Email:           identity.Email,
+		Password:        password,
+		PasswordUnset:   true,
+		Role:            models.Regular,
+		ReferralCode:    referralCode,
+		EmailVerifiedAt: &now,
+	}
+
+	if err := s.userRepository.CreateUser(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// oauthUserName fits the provider name into the limits of SignupReq, falling
+// back to the local part of the email address.
+func oauthUserName(identity *oauth.Identity) string {
+	name := strings.TrimSpace(identity.Name)
+
+	if len([]rune(name)) < 2 {
+		name, _, _ = strings.Cut(identity.Email, "@")
+	}
+
+	if runes := []rune(name); len(runes) > 30 {
+		name = string(runes[:30])
+	}
+
+	return name
+}
+
+func (s *OAuthService) DeleteExpiredOAuthStates() (int64, error) {
+	return s.oauthRepository.DeleteExpiredOAuthStates(time.Now())
+}
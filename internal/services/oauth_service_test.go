@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gwid.io/gwid-core/internal/config"
+	"gwid.io/gwid-core/internal/database"
+	"gwid.io/gwid-core/internal/models"
+	"gwid.io/gwid-core/internal/oauth"
+	"gwid.io/gwid-core/internal/oauth/oauthtest"
+	"gwid.io/gwid-core/internal/repositories"
+	"gwid.io/gwid-core/internal/types"
+)
+
+// newTestDB connects to the Postgres database in TEST_DATABASE_URL and runs
+// every test in a transaction that is rolled back afterwards.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger:                 logger.Default.LogMode(logger.Silent),
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatalf("unable to connect to the test database: %v", err)
+	}
+
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("unable to migrate the test database: %v", err)
+	}
+
+	tx := db.Begin()
+	t.Cleanup(func() { tx.Rollback() })
+
+	return tx
+}
+
+type oauthServiceTest struct {
+	server          *oauthtest.Provider
+	service         *OAuthService
+	oauthRepository *repositories.OAuthRepository
+	userRepository  *repositories.UserRepository
+}
+
+func newOAuthServiceTest(t *testing.T) *oauthServiceTest {
+	t.Helper()
+
+	db := newTestDB(t)
+
+	server, err := oauthtest.NewProvider()
+	if err != nil {
+		t.Fatalf("oauthtest.NewProvider() error = %v", err)
+	}
+
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{
+		OAuthRedirectURL: "https://app.example.com/oauth/callback",
+		OAuthProviders: map[string]config.OAuthProviderConfig{
+			"sso": {
+				Name:         "sso",
+				Kind:         "oidc",
+				Issuer:       server.Issuer(),
+				ClientID:     oauthtest.ClientID,
+				ClientSecret: oauthtest.ClientSecret,
+				Scopes:       []string{"openid", "email"},
+			},
+		},
+	}
+
+	oauthRepository := repositories.NewOAuthRepository(db)
+	userRepository := repositories.NewUserRepository(db)
+
+	return &oauthServiceTest{
+		server:          server,
+		service:         NewOAuthService(cfg, oauth.NewRegistry(cfg), oauthRepository, userRepository, NewUserService(userRepository), nil),
+		oauthRepository: oauthRepository,
+		userRepository:  userRepository,
+	}
+}
+
+// login signs the user in at the provider and exchanges the code with the
+// values Authorize stored for the state.
+func (st *oauthServiceTest) login(t *testing.T, user oauthtest.User) *oauth.Identity {
+	t.Helper()
+
+	authURL, statusCode, err := st.service.Authorize("sso")
+	if err != nil {
+		t.Fatalf("Authorize() = %d, %v", statusCode, err)
+	}
+
+	code, state, err := st.server.Authorize(authURL, user)
+	if err != nil {
+		t.Fatalf("provider Authorize() error = %v", err)
+	}
+
+	oauthState, result := st.oauthRepository.GetOAuthStateByHash(hashToken(state))
+	if result.RowsAffected == 0 {
+		t.Fatal("Authorize() did not store the state")
+	}
+
+	provider, err := st.service.registry.Get("sso")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	identity, err := provider.Exchange(context.Background(), code, oauth.AuthRequest{
+		State:        state,
+		Nonce:        oauthState.Nonce,
+		CodeVerifier: oauthState.CodeVerifier,
+		RedirectURI:  oauthState.RedirectURI,
+	})
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+
+	return identity
+}
+
+func (st *oauthServiceTest) createUser(t *testing.T, email string) *models.User {
+	t.Helper()
+
+	user := &models.User{
+		Name:         "Existing",
+		Email:        email,
+		Password:     "correct horse battery",
+		Role:         models.Regular,
+		ReferralCode: "REF" + email[:3],
+	}
+
+	if err := st.userRepository.CreateUser(user); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	return user
+}
+
+func TestOAuthServiceAuthorizeStoresState(t *testing.T) {
+	st := newOAuthServiceTest(t)
+
+	authURL, _, err := st.service.Authorize("sso")
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+
+	parsedURL, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("url.Parse() error = %v", err)
+	}
+
+	query := parsedURL.Query()
+
+	if _, result := st.oauthRepository.GetOAuthStateByHash(query.Get("state")); result.RowsAffected != 0 {
+		t.Error("Authorize() stored the state unhashed")
+	}
+
+	oauthState, result := st.oauthRepository.GetOAuthStateByHash(hashToken(query.Get("state")))
+	if result.RowsAffected == 0 {
+		t.Fatal("Authorize() did not store the state")
+	}
+
+	if oauthState.Provider != "sso" || oauthState.Nonce != query.Get("nonce") || !oauthState.IsUsable() {
+		t.Errorf("stored state = %+v", oauthState)
+	}
+
+	if oauth.CodeChallenge(oauthState.CodeVerifier) != query.Get("code_challenge") {
+		t.Error("stored code verifier does not match the code challenge")
+	}
+
+	if _, statusCode, err := st.service.Authorize("unknown"); err == nil || statusCode != http.StatusNotFound {
+		t.Errorf("Authorize(unknown) = %d, %v, want 404", statusCode, err)
+	}
+}
+
+func TestOAuthServiceCallbackRejectsState(t *testing.T) {
+	st := newOAuthServiceTest(t)
+
+	authURL, _, err := st.service.Authorize("sso")
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+
+	code, state, err := st.server.Authorize(authURL, oauthtest.User{Subject: "user-1"})
+	if err != nil {
+		t.Fatalf("provider Authorize() error = %v", err)
+	}
+
+	if err := st.oauthRepository.CreateOAuthState(&models.OAuthState{
+		Provider:     "other",
+		StateHash:    hashToken("state-of-other"),
+		Nonce:        "nonce",
+		CodeVerifier: "verifier",
+		RedirectURI:  "https://app.example.com/oauth/callback",
+		ExpiresAt:    time.Now().Add(oauthStateTTL),
+	}); err != nil {
+		t.Fatalf("CreateOAuthState() error = %v", err)
+	}
+
+	oauthState, _ := st.oauthRepository.GetOAuthStateByHash(hashToken(state))
+	if _, err := st.oauthRepository.ConsumeOAuthState(oauthState); err != nil {
+		t.Fatalf("ConsumeOAuthState() error = %v", err)
+	}
+
+	tests := map[string]string{
+		"unknown state":             "forged",
+		"state of another provider": "state-of-other",
+		"state already used":        state,
+	}
+
+	for name, state := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, _, statusCode, err := st.service.Callback("sso", types.OAuthCallbackReq{Code: code, State: state}, types.SessionMeta{})
+			if !errors.Is(err, errInvalidOAuthState) || statusCode != http.StatusBadRequest {
+				t.Errorf("Callback() = %d, %v, want 400 %v", statusCode, err, errInvalidOAuthState)
+			}
+		})
+	}
+}
+
+func TestOAuthServiceLinksVerifiedEmail(t *testing.T) {
+	st := newOAuthServiceTest(t)
+	existing := st.createUser(t, "ada@example.com")
+
+	identity := st.login(t, oauthtest.User{Subject: "user-1", Email: "Ada@Example.com", EmailVerified: true})
+
+	user, statusCode, err := st.service.findOrCreateUser("sso", identity)
+	if err != nil {
+		t.Fatalf("findOrCreateUser() = %d, %v", statusCode, err)
+	}
+
+	if user.ID != existing.ID {
+		t.Fatalf("findOrCreateUser() = user %s, want the existing user %s", user.ID, existing.ID)
+	}
+
+	if !user.IsEmailVerified() {
+		t.Error("linking by a verified email did not verify the user's email")
+	}
+
+	if !user.HasPassword() {
+		t.Error("linking an identity marked the user's password as unset")
+	}
+
+	userIdentity, result := st.oauthRepository.GetUserIdentity("sso", "user-1")
+	if result.RowsAffected == 0 || userIdentity.UserID != existing.ID {
+		t.Fatalf("identity was not linked to the existing user: %+v", userIdentity)
+	}
+
+	// Once linked, the subject finds the user even if the email changes.
+	identity = st.login(t, oauthtest.User{Subject: "user-1", Email: "ada@new.example.com"})
+
+	user, _, err = st.service.findOrCreateUser("sso", identity)
+	if err != nil || user.ID != existing.ID {
+		t.Fatalf("findOrCreateUser() = %v, %v, want the linked user", user, err)
+	}
+}
+
+func TestOAuthServiceRefusesUnverifiedEmail(t *testing.T) {
+	st := newOAuthServiceTest(t)
+	st.createUser(t, "ada@example.com")
+
+	identity := st.login(t, oauthtest.User{Subject: "attacker", Email: "ada@example.com", EmailVerified: false})
+
+	if _, statusCode, err := st.service.findOrCreateUser("sso", identity); err == nil || statusCode != http.StatusBadRequest {
+		t.Fatalf("findOrCreateUser() = %d, %v, want 400", statusCode, err)
+	}
+
+	if _, result := st.oauthRepository.GetUserIdentity("sso", "attacker"); result.RowsAffected != 0 {
+		t.Error("an identity with an unverified email was linked")
+	}
+}
+
+func TestOAuthServiceCreatesUser(t *testing.T) {
+	st := newOAuthServiceTest(t)
+
+	identity := st.login(t, oauthtest.User{Subject: "user-2", Email: "grace@example.com", EmailVerified: true, Name: "Grace Hopper"})
+
+	user, statusCode, err := st.service.findOrCreateUser("sso", identity)
+	if err != nil {
+		t.Fatalf("findOrCreateUser() = %d, %v", statusCode, err)
+	}
+
+	if user.Email != "grace@example.com" || user.Name != "Grace Hopper" || !user.IsEmailVerified() {
+		t.Errorf("findOrCreateUser() created %+v", user)
+	}
+
+	if user.HasPassword() {
+		t.Error("a user created through OAuth has a password they never saw")
+	}
+
+	if _, result := st.userRepository.FindByEmail("grace@example.com"); result.RowsAffected == 0 {
+		t.Error("findOrCreateUser() did not save the user")
+	}
+}
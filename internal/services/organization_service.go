@@ -0,0 +1,442 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gwid.io/gwid-core/internal/config"
+	"gwid.io/gwid-core/internal/mailer"
+	"gwid.io/gwid-core/internal/models"
+	"gwid.io/gwid-core/internal/repositories"
+	"gwid.io/gwid-core/internal/types"
+)
+
+const invitationTTL = 7 * 24 * time.Hour
+
+var errOrganizationNotFound = errors.New("organization not found")
+
+type OrganizationService struct {
+	cfg                      *config.Config
+	organizationRepository   *repositories.OrganizationRepository
+	userRepository           *repositories.UserRepository
+	gatewayRepository        *repositories.GatewayRepository
+	awsCredentialsRepository *repositories.AWSCredentialsRepository
+	mailer                   mailer.Mailer
+}
+
+func NewOrganizationService(
+	cfg *config.Config,
+	organizationRepository *repositories.OrganizationRepository,
+	userRepository *repositories.UserRepository,
+	gatewayRepository *repositories.GatewayRepository,
+	awsCredentialsRepository *repositories.AWSCredentialsRepository,
+	mailer mailer.Mailer,
+) *OrganizationService {
+	return &OrganizationService{
+		cfg:                      cfg,
+		organizationRepository:   organizationRepository,
+		userRepository:           userRepository,
+		gatewayRepository:        gatewayRepository,
+		awsCredentialsRepository: awsCredentialsRepository,
+		mailer:                   mailer,
+	}
+}
+
+// ResolveOrganization returns the organization the user acts in, which is the
+// personal organization when organizationID is nil.
+func (s *OrganizationService) ResolveOrganization(userID uuid.UUID, organizationID uuid.UUID) (*types.OrganizationContext, int, error) {
+	if organizationID == uuid.Nil {
+		organization, result := s.organizationRepository.GetPersonalOrganization(userID)
+		if result.RowsAffected == 0 {
+			return nil, http.StatusNotFound, errOrganizationNotFound
+		}
+
+		organizationID = organization.ID
+	}
+
+	member, result := s.organizationRepository.GetMembership(organizationID, userID)
+	if result.RowsAffected == 0 {
+		return nil, http.StatusForbidden, errors.New("you are not a member of this organization")
+	}
+
+	return &types.OrganizationContext{
+		ID:   member.OrganizationID,
+		Role: member.Role,
+	}, http.StatusOK, nil
+}
+
+func (s *OrganizationService) CreateOrganization(createOrganizationReq types.CreateOrganizationReq, userID uuid.UUID) (*types.OrganizationRes, int, error) {
+	organization := models.Organization{
+		Name: strings.TrimSpace(createOrganizationReq.Name),
+	}
+
+	if err := s.organizationRepository.CreateOrganization(&organization, userID); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return types.NewOrganizationRes(&organization, models.OrgRoleOwner), http.StatusCreated, nil
+}
+
+func (s *OrganizationService) GetUserOrganizations(userID uuid.UUID) ([]*types.OrganizationRes, int, error) {
+	memberships, err := s.organizationRepository.GetUserMemberships(userID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return types.NewOrganizationResList(*memberships), http.StatusOK, nil
+}
+
+// getMembership returns the membership of the user in an organization with
+// the organization preloaded.
+func (s *OrganizationService) getMembership(organizationID uuid.UUID, userID uuid.UUID) (*models.OrganizationMember, int, error) {
+	member, result := s.organizationRepository.GetMembership(organizationID, userID)
+	if result.RowsAffected == 0 {
+		return nil, http.StatusNotFound, errOrganizationNotFound
+	}
+
+	return member, http.StatusOK, nil
+}
+
+func (s *OrganizationService) GetOrganization(organizationID uuid.UUID, userID uuid.UUID) (*types.OrganizationRes, int, error) {
+	member, statusCode, err := s.getMembership(organizationID, userID)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	return types.NewOrganizationRes(member.Organization, member.Role), http.StatusOK, nil
+}
+
+func (s *OrganizationService) UpdateOrganization(organizationID uuid.UUID, userID uuid.UUID, updateOrganizationReq types.UpdateOrganizationReq) (*types.OrganizationRes, int, error) {
+	member, statusCode, err := s.getMembership(organizationID, userID)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	if !member.Role.AtLeast(models.OrgRoleAdmin) {
+		return nil, http.StatusForbidden, errors.New("only admins can rename the organization")
+	}
+
+	member.Organization.Name = strings.TrimSpace(updateOrganizationReq.Name)
+
+	if err := s.organizationRepository.UpdateOrganizationColumns(member.Organization, "name"); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return types.NewOrganizationRes(member.Organization, member.Role), http.StatusOK, nil
+}
+
+// DeleteOrganization deletes a shared organization once its gateways and
+// credentials are gone.
+func (s *OrganizationService) DeleteOrganization(organizationID uuid.UUID, userID uuid.UUID) (int, error) {
+	member, statusCode, err := s.getMembership(organizationID, userID)
+	if err != nil {
+		return statusCode, err
+	}
+
+	if member.Role != models.OrgRoleOwner {
+		return http.StatusForbidden, errors.New("only owners can delete the organization")
+	}
+
+	if member.Organization.IsPersonal() {
+		return http.StatusBadRequest, errors.New("personal organizations cannot be deleted")
+	}
+
+	gatewaysCount, err := s.gatewayRepository.GetOrganizationGatewaysCount(organizationID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	credentialsCount, err := s.awsCredentialsRepository.GetOrganizationCredentialsCount(organizationID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if gatewaysCount > 0 || credentialsCount > 0 {
+		return http.StatusConflict, errors.New("delete the gateways and credentials of the organization first")
+	}
+
+	if err := s.organizationRepository.DeleteOrganization(member.Organization); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusOK, nil
+}
+
+func (s *OrganizationService) GetOrganizationMembers(organizationID uuid.UUID, userID uuid.UUID) ([]*types.OrganizationMemberRes, int, error) {
+	if _, statusCode, err := s.getMembership(organizationID, userID); err != nil {
+		return nil, statusCode, err
+	}
+
+	members, err := s.organizationRepository.GetOrganizationMembers(organizationID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return types.NewOrganizationMemberResList(*members), http.StatusOK, nil
+}
+
+// canManage reports whether actor may change a member with the given role, or
+// grant it. Admins manage everyone below owner and only owners manage owners.
+func canManage(actor models.OrganizationRole, role models.OrganizationRole) bool {
+	if actor == models.OrgRoleOwner {
+		return true
+	}
+
+	return actor == models.OrgRoleAdmin && role != models.OrgRoleOwner
+}
+
+// removesLastOwner reports whether demoting or removing target would leave the
+// organization without an owner.
+func (s *OrganizationService) removesLastOwner(target *models.OrganizationMember) (bool, error) {
+	if target.Role != models.OrgRoleOwner {
+		return false, nil
+	}
+
+	owners, err := s.organizationRepository.CountOwners(target.OrganizationID)
+	if err != nil {
+		return false, err
+	}
+
+	return owners <= 1, nil
+}
+
+func (s *OrganizationService) UpdateMemberRole(organizationID uuid.UUID, memberUserID uuid.UUID, userID uuid.UUID, updateMemberRoleReq types.UpdateMemberRoleReq) (int, error) {
+	actor, statusCode, err := s.getMembership(organizationID, userID)
+	if err != nil {
+		return statusCode, err
+	}
+
+	target, result := s.organizationRepository.GetMembership(organizationID, memberUserID)
+	if result.RowsAffected == 0 {
+		return http.StatusNotFound, errors.New("member not found")
+	}
+
+	role := models.OrganizationRole(updateMemberRoleReq.Role)
+
+	if !canManage(actor.Role, target.Role) || !canManage(actor.Role, role) {
+		return http.StatusForbidden, errors.New("you cannot change the role of this member")
+	}
+
+	if role != models.OrgRoleOwner {
+		lastOwner, err := s.removesLastOwner(target)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		if lastOwner {
+			return http.StatusConflict, errors.New("an organization needs at least one owner")
+		}
+	}
+
+	target.Role = role
+
+	if err := s.organizationRepository.UpdateMemberColumns(target, "role"); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusOK, nil
+}
+
+// RemoveMember removes a member from the organization. Members can always
+// remove themselves, unless they are its last owner.
+func (s *OrganizationService) RemoveMember(organizationID uuid.UUID, memberUserID uuid.UUID, userID uuid.UUID) (int, error) {
+	actor, statusCode, err := s.getMembership(organizationID, userID)
+	if err != nil {
+		return statusCode, err
+	}
+
+	target := actor
+
+	if memberUserID != userID {
+		member, result := s.organizationRepository.GetMembership(organizationID, memberUserID)
+		if result.RowsAffected == 0 {
+			return http.StatusNotFound, errors.New("member not found")
+		}
+
+		if !canManage(actor.Role, member.Role) {
+			return http.StatusForbidden, errors.New("you cannot remove this member")
+		}
+
+		target = member
+	}
+
+	if actor.Organization.IsPersonal() {
+		return http.StatusBadRequest, errors.New("personal organizations cannot have other members")
+	}
+
+	lastOwner, err := s.removesLastOwner(target)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if lastOwner {
+		return http.StatusConflict, errors.New("an organization needs at least one owner")
+	}
+
+	if err := s.organizationRepository.DeleteMember(target); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusOK, nil
+}
+
+// InviteMember emails an invitation link to join the organization. Sending
+// happens in the background.
+func (s *OrganizationService) InviteMember(organizationID uuid.UUID, userID uuid.UUID, inviteMemberReq types.InviteMemberReq) (*types.OrganizationInvitationRes, int, error) {
+	actor, statusCode, err := s.getMembership(organizationID, userID)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	role := models.OrganizationRole(inviteMemberReq.Role)
+
+	if !actor.Role.AtLeast(models.OrgRoleAdmin) || !canManage(actor.Role, role) {
+		return nil, http.StatusForbidden, errors.New("you cannot invite members with this role")
+	}
+
+	if actor.Organization.IsPersonal() {
+		return nil, http.StatusBadRequest, errors.New("personal organizations cannot have other members")
+	}
+
+	email := strings.ToLower(strings.TrimSpace(inviteMemberReq.Email))
+
+	if user, result := s.userRepository.FindByEmail(email); result.RowsAffected > 0 {
+		if _, result := s.organizationRepository.GetMembership(organizationID, user.ID); result.RowsAffected > 0 {
+			return nil, http.StatusConflict, errors.New("user is already a member of the organization")
+		}
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	invitation := models.OrganizationInvitation{
+		OrganizationID: organizationID,
+		Email:          email,
+		Role:           role,
+		TokenHash:      hashToken(token),
+		InvitedByID:    userID,
+		ExpiresAt:      time.Now().Add(invitationTTL),
+	}
+
+	if err := s.organizationRepository.CreateInvitation(&invitation); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	go s.sendInvitationEmail(invitation, actor.Organization.Name, token)
+
+	return types.NewOrganizationInvitationRes(&invitation), http.StatusCreated, nil
+}
+
+func (s *OrganizationService) sendInvitationEmail(invitation models.OrganizationInvitation, organizationName string, token string) {
+	inviterName := "A GWID user"
+
+	if inviter, result := s.userRepository.FindByID(invitation.InvitedByID); result.RowsAffected > 0 {
+		inviterName = inviter.Name
+	}
+
+	message, err := mailer.Render("organization_invitation", invitation.Email, map[string]string{
+		"Inviter":      inviterName,
+		"Organization": organizationName,
+		"Role":         string(invitation.Role),
+		"Link":         s.cfg.AppURL + "/invitations/accept?token=" + url.QueryEscape(token),
+		"ExpiresIn":    "7 days",
+	})
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if err := s.mailer.Send(message); err != nil {
+		log.Printf("unable to send invitation mail for invitation %s: %v", invitation.ID, err)
+	}
+}
+
+func (s *OrganizationService) GetPendingInvitations(organizationID uuid.UUID, userID uuid.UUID) ([]*types.OrganizationInvitationRes, int, error) {
+	actor, statusCode, err := s.getMembership(organizationID, userID)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	if !actor.Role.AtLeast(models.OrgRoleAdmin) {
+		return nil, http.StatusForbidden, errors.New("only admins can see invitations")
+	}
+
+	invitations, err := s.organizationRepository.GetPendingInvitations(organizationID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return types.NewOrganizationInvitationResList(*invitations), http.StatusOK, nil
+}
+
+func (s *OrganizationService) RevokeInvitation(organizationID uuid.UUID, invitationID uuid.UUID, userID uuid.UUID) (int, error) {
+	actor, statusCode, err := s.getMembership(organizationID, userID)
+	if err != nil {
+		return statusCode, err
+	}
+
+	if !actor.Role.AtLeast(models.OrgRoleAdmin) {
+		return http.StatusForbidden, errors.New("only admins can revoke invitations")
+	}
+
+	result := s.organizationRepository.DeleteInvitation(invitationID, organizationID)
+	if result.Error != nil {
+		return http.StatusInternalServerError, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return http.StatusNotFound, errors.New("invitation not found")
+	}
+
+	return http.StatusOK, nil
+}
+
+// AcceptInvitation adds the user to the organization of the invitation. The
+// invitation is bound to the email address it was sent to.
+func (s *OrganizationService) AcceptInvitation(acceptInvitationReq types.AcceptInvitationReq, userID uuid.UUID) (*types.OrganizationRes, int, error) {
+	invitation, result := s.organizationRepository.GetInvitationByHash(hashToken(acceptInvitationReq.Token))
+	if result.RowsAffected == 0 || !invitation.IsPending() {
+		return nil, http.StatusBadRequest, errors.New("invalid or expired invitation")
+	}
+
+	user, result := s.userRepository.FindByID(userID)
+	if result.RowsAffected == 0 {
+		return nil, http.StatusNotFound, errors.New("user not found")
+	}
+
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		return nil, http.StatusForbidden, errors.New("this invitation was sent to a different email address")
+	}
+
+	if !user.IsEmailVerified() {
+		return nil, http.StatusForbidden, errors.New("email address is not verified")
+	}
+
+	if _, result := s.organizationRepository.GetMembership(invitation.OrganizationID, userID); result.RowsAffected > 0 {
+		return nil, http.StatusConflict, errors.New("you are already a member of this organization")
+	}
+
+	member := models.OrganizationMember{
+		OrganizationID: invitation.OrganizationID,
+		UserID:         userID,
+		Role:           invitation.Role,
+	}
+
+	accepted, err := s.organizationRepository.AcceptInvitation(invitation, &member)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	if !accepted {
+		return nil, http.StatusBadRequest, errors.New("invalid or expired invitation")
+	}
+
+	return types.NewOrganizationRes(invitation.Organization, member.Role), http.StatusOK, nil
+}
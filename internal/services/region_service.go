@@ -4,8 +4,6 @@ import (
 	"context"
 	"net/http"
 
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/google/uuid"
 	"gwid.io/gwid-core/internal/types"
@@ -13,30 +11,27 @@ import (
 
 type RegionService struct {
 	awsCredentialsService *AWSCredentialsService
-	encryptionService     *EncryptionService
+	encryptionService     EncryptionService
 }
 
-func NewRegionService(awsCredentialsService *AWSCredentialsService, encryptionService *EncryptionService) *RegionService {
+func NewRegionService(awsCredentialsService *AWSCredentialsService, encryptionService EncryptionService) *RegionService {
 	return &RegionService{
 		awsCredentialsService: awsCredentialsService,
 		encryptionService:     encryptionService,
 	}
 }
 
-func (s *RegionService) GetAWSRegions(userID uuid.UUID, credentialsID uuid.UUID) ([]*types.RegionRes, int, error) {
+func (s *RegionService) GetAWSRegions(organizationID uuid.UUID, credentialsID uuid.UUID) ([]*types.RegionRes, int, error) {
 	ctx := context.Background()
 
-	credential, statusCode, err := s.awsCredentialsService.GetAWSCredentialsByID(credentialsID, userID)
+	credential, statusCode, err := s.awsCredentialsService.GetAWSCredentialsByID(credentialsID, organizationID)
 	if err != nil {
 		return nil, statusCode, err
 	}
 
 	awsRegion := "eu-central-1"
 
-	cfg, err := config.LoadDefaultConfig(
-		ctx,
-		config.WithRegion(awsRegion),
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(credential.AccessKeyID, credential.SecretAccessKey, "")))
+	cfg, err := s.awsCredentialsService.LoadAWSConfig(ctx, credential, awsRegion)
 	if err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"gwid.io/gwid-core/internal/repositories"
+	"gwid.io/gwid-core/internal/types"
+)
+
+var ErrSessionRevoked = errors.New("session is no longer active")
+
+type SessionService struct {
+	sessionRepository *repositories.SessionRepository
+}
+
+func NewSessionService(sessionRepository *repositories.SessionRepository) *SessionService {
+	return &SessionService{
+		sessionRepository: sessionRepository,
+	}
+}
+
+// ValidateSession is called by AuthMiddleware on every request, so that
+// revoking a session, suspending its user or deleting their account also ends
+// its outstanding access tokens.
+func (s *SessionService) ValidateSession(sessionID uuid.UUID, userID uuid.UUID) error {
+	if sessionID == uuid.Nil {
+		return ErrSessionRevoked
+	}
+
+	session, result := s.sessionRepository.GetSessionOfActiveUser(sessionID, userID)
+	if result.RowsAffected == 0 || !session.IsActive() {
+		return ErrSessionRevoked
+	}
+
+	return nil
+}
+
+func (s *SessionService) GetUserSessions(userID uuid.UUID, currentSessionID uuid.UUID) ([]*types.SessionRes, int, error) {
+	sessions, err := s.sessionRepository.GetActiveUserSessions(userID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	data := make([]*types.SessionRes, 0, len(*sessions))
+
+	for i := range *sessions {
+		data = append(data, types.NewSessionRes(&(*sessions)[i], currentSessionID))
+	}
+
+	return data, http.StatusOK, nil
+}
+
+func (s *SessionService) RevokeUserSession(sessionID uuid.UUID, userID uuid.UUID) (int, error) {
+	session, result := s.sessionRepository.GetSessionByID(sessionID, userID)
+	if result.RowsAffected == 0 {
+		return http.StatusNotFound, errors.New("session not found")
+	}
+
+	if err := s.sessionRepository.RevokeSession(session, "revoked by user"); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusOK, nil
+}
+
+// RevokeAllUserSessions signs the user out everywhere except the session with
+// exceptID, which may be uuid.Nil.
+func (s *SessionService) RevokeAllUserSessions(userID uuid.UUID, exceptID uuid.UUID, reason string) (int, error) {
+	if err := s.sessionRepository.RevokeUserSessions(userID, exceptID, reason); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusOK, nil
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"gwid.io/gwid-core/internal/models"
+	"gwid.io/gwid-core/internal/repositories"
+	"gwid.io/gwid-core/internal/types"
+	"gwid.io/gwid-core/internal/utils"
+)
+
+const (
+	totpIssuer        = "GWID"
+	recoveryCodeCount = 10
+)
+
+var errInvalidTwoFactorCode = errors.New("invalid two-factor code")
+
+type TwoFactorService struct {
+	userRepository         *repositories.UserRepository
+	recoveryCodeRepository *repositories.RecoveryCodeRepository
+	encryptionService      EncryptionService
+}
+
+func NewTwoFactorService(
+	userRepository *repositories.UserRepository,
+	recoveryCodeRepository *repositories.RecoveryCodeRepository,
+	encryptionService EncryptionService,
+) *TwoFactorService {
+	return &TwoFactorService{
+		userRepository:         userRepository,
+		recoveryCodeRepository: recoveryCodeRepository,
+		encryptionService:      encryptionService,
+	}
+}
+
+// SetupTwoFactor stores a new pending secret. It replaces any earlier pending
+// secret, but 2FA stays off until ConfirmTwoFactor.
+func (s *TwoFactorService) SetupTwoFactor(userID uuid.UUID) (*types.TwoFactorSetupRes, int, error) {
+	user, result := s.userRepository.FindByID(userID)
+	if result.RowsAffected == 0 {
+		return nil, http.StatusNotFound, errors.New("user not found")
+	}
+
+	if user.IsTwoFactorEnabled() {
+		return nil, http.StatusConflict, errors.New("two-factor authentication is already enabled")
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	encryptedSecret, err := s.encryptionService.EncryptData([]byte(secret))
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	user.TOTPSecret = &encryptedSecret
+	user.TOTPLastStep = 0
+
+	if err := s.userRepository.UpdateUserColumns(user, "totp_secret", "totp_last_step"); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return &types.TwoFactorSetupRes{
+		Secret:     secret,
+		OTPAuthURI: utils.TOTPURI(totpIssuer, user.Email, secret),
+	}, http.StatusOK, nil
+}
+
+// ConfirmTwoFactor enables 2FA once the user proves their authenticator
+// produces valid codes, and returns the recovery codes.
+func (s *TwoFactorService) ConfirmTwoFactor(twoFactorCodeReq types.TwoFactorCodeReq, userID uuid.UUID) (*types.RecoveryCodesRes, int, error) {
+	user, result := s.userRepository.FindByID(userID)
+	if result.RowsAffected == 0 {
+		return nil, http.StatusNotFound, errors.New("user not found")
+	}
+
+	if user.IsTwoFactorEnabled() {
+		return nil, http.StatusConflict, errors.New("two-factor authentication is already enabled")
+	}
+
+	if user.TOTPSecret == nil {
+		return nil, http.StatusBadRequest, errors.New("two-factor authentication has not been set up")
+	}
+
+	if err := s.verifyTOTP(user, twoFactorCodeReq.Code); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	now := time.Now()
+	user.TOTPEnabledAt = &now
+
+	if err := s.userRepository.UpdateUserColumns(user, "totp_enabled_at"); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	recoveryCodes, err := s.generateRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return recoveryCodes, http.StatusOK, nil
+}
+
+// DisableTwoFactor needs the password and a TOTP or recovery code. Users who
+// signed up through an OAuth provider never saw their password, so for them
+// the code alone is enough.
+func (s *TwoFactorService) DisableTwoFactor(disableTwoFactorReq types.DisableTwoFactorReq, userID uuid.UUID) (int, error) {
+	user, result := s.userRepository.FindByID(userID)
+	if result.RowsAffected == 0 {
+		return http.StatusNotFound, errors.New("user not found")
+	}
+
+	if !user.IsTwoFactorEnabled() {
+		return http.StatusBadRequest, errors.New("two-factor authentication is not enabled")
+	}
+
+	if user.HasPassword() {
+		if err := user.CheckPassword(disableTwoFactorReq.Password); err != nil {
+			return http.StatusBadRequest, errors.New("invalid password")
+		}
+	}
+
+	if err := s.VerifyCode(user, disableTwoFactorReq.Code); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	user.TOTPSecret = nil
+	user.TOTPEnabledAt = nil
+	user.TOTPLastStep = 0
+
+	if err := s.userRepository.UpdateUserColumns(user, "totp_secret", "totp_enabled_at", "totp_last_step"); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if err := s.recoveryCodeRepository.ReplaceRecoveryCodes(user.ID, nil); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusOK, nil
+}
+
+// RegenerateRecoveryCodes invalidates all recovery codes and returns new ones.
+// It needs a TOTP code so that a leaked recovery code cannot be used to mint
+// more of them.
+func (s *TwoFactorService) RegenerateRecoveryCodes(twoFactorCodeReq types.TwoFactorCodeReq, userID uuid.UUID) (*types.RecoveryCodesRes, int, error) {
+	user, result := s.userRepository.FindByID(userID)
+	if result.RowsAffected == 0 {
+		return nil, http.StatusNotFound, errors.New("user not found")
+	}
+
+	if !user.IsTwoFactorEnabled() {
+		return nil, http.StatusBadRequest, errors.New("two-factor authentication is not enabled")
+	}
+
+	if err := s.verifyTOTP(user, twoFactorCodeReq.Code); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	recoveryCodes, err := s.generateRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return recoveryCodes, http.StatusOK, nil
+}
+
+// VerifyCode accepts either a TOTP code or an unused recovery code.
+func (s *TwoFactorService) VerifyCode(user *models.User, code string) error {
+	if err := s.verifyTOTP(user, code); err == nil || !errors.Is(err, errInvalidTwoFactorCode) {
+		return err
+	}
+
+	consumed, err := s.recoveryCodeRepository.ConsumeRecoveryCode(user.ID, hashToken(utils.NormalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+
+	if !consumed {
+		return errInvalidTwoFactorCode
+	}
+
+	return nil
+}
+
+func (s *TwoFactorService) verifyTOTP(user *models.User, code string) error {
+	if user.TOTPSecret == nil {
+		return errInvalidTwoFactorCode
+	}
+
+	secret, err := s.encryptionService.DecryptData(*user.TOTPSecret)
+	if err != nil {
+		return err
+	}
+
+	step, ok := utils.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return errInvalidTwoFactorCode
+	}
+
+	advanced, err := s.userRepository.AdvanceTOTPStep(user, step)
+	if err != nil {
+		return err
+	}
+
+	if !advanced {
+		return errInvalidTwoFactorCode
+	}
+
+	return nil
+}
+
+func (s *TwoFactorService) generateRecoveryCodes(userID uuid.UUID) (*types.RecoveryCodesRes, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	recoveryCodes := make([]models.RecoveryCode, 0, recoveryCodeCount)
+
+	for range recoveryCodeCount {
+		code, err := utils.GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, code)
+		recoveryCodes = append(recoveryCodes, models.RecoveryCode{
+			UserID:   userID,
+			CodeHash: hashToken(utils.NormalizeRecoveryCode(code)),
+		})
+	}
+
+	if err := s.recoveryCodeRepository.ReplaceRecoveryCodes(userID, recoveryCodes); err != nil {
+		return nil, err
+	}
+
+	return &types.RecoveryCodesRes{RecoveryCodes: codes}, nil
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"gwid.io/gwid-core/internal/config"
+	"gwid.io/gwid-core/internal/models"
+	"gwid.io/gwid-core/internal/repositories"
+	"gwid.io/gwid-core/internal/types"
+	"gwid.io/gwid-core/internal/utils"
+)
+
+type twoFactorServiceTest struct {
+	service        *TwoFactorService
+	userRepository *repositories.UserRepository
+}
+
+func newTwoFactorServiceTest(t *testing.T) *twoFactorServiceTest {
+	t.Helper()
+
+	db := newTestDB(t)
+
+	cfg := &config.Config{
+		EncryptionKeyID: "k1",
+		EncryptionKeys:  map[string]string{"k1": "fedcba9876543210fedcba9876543210"},
+	}
+
+	userRepository := repositories.NewUserRepository(db)
+
+	return &twoFactorServiceTest{
+		service:        NewTwoFactorService(userRepository, repositories.NewRecoveryCodeRepository(db), NewLocalEncryptionService(cfg)),
+		userRepository: userRepository,
+	}
+}
+
+// enableTwoFactor creates a user with 2FA enabled and returns one of their
+// recovery codes.
+func (st *twoFactorServiceTest) enableTwoFactor(t *testing.T, email string, passwordUnset bool) (*models.User, string) {
+	t.Helper()
+
+	user := &models.User{
+		Name:          "Ada",
+		Email:         email,
+		Password:      "correct horse battery",
+		PasswordUnset: passwordUnset,
+		Role:          models.Regular,
+		ReferralCode:  "REF" + email[:3],
+	}
+
+	if err := st.userRepository.CreateUser(user); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	setup, statusCode, err := st.service.SetupTwoFactor(user.ID)
+	if err != nil {
+		t.Fatalf("SetupTwoFactor() = %d, %v", statusCode, err)
+	}
+
+	code, err := utils.TOTPCode(setup.Secret, utils.TOTPStep(time.Now()))
+	if err != nil {
+		t.Fatalf("TOTPCode() error = %v", err)
+	}
+
+	recoveryCodes, statusCode, err := st.service.ConfirmTwoFactor(types.TwoFactorCodeReq{Code: code}, user.ID)
+	if err != nil {
+		t.Fatalf("ConfirmTwoFactor() = %d, %v", statusCode, err)
+	}
+
+	return user, recoveryCodes.RecoveryCodes[0]
+}
+
+func (st *twoFactorServiceTest) isTwoFactorEnabled(t *testing.T, user *models.User) bool {
+	t.Helper()
+
+	user, result := st.userRepository.FindByID(user.ID)
+	if result.RowsAffected == 0 {
+		t.Fatal("user not found")
+	}
+
+	return user.IsTwoFactorEnabled()
+}
+
+func TestTwoFactorServiceDisableTwoFactor(t *testing.T) {
+	tests := []struct {
+		name          string
+		passwordUnset bool
+		password      string
+		wrongCode     bool
+		want          int
+	}{
+		{name: "password and code", password: "correct horse battery", want: http.StatusOK},
+		{name: "code without password", want: http.StatusBadRequest},
+		{name: "wrong password", password: "Tr0ub4dor&3", want: http.StatusBadRequest},
+		{name: "code of user without a password", passwordUnset: true, want: http.StatusOK},
+		{name: "wrong code of user without a password", passwordUnset: true, wrongCode: true, want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := newTwoFactorServiceTest(t)
+			user, code := st.enableTwoFactor(t, "ada@example.com", tt.passwordUnset)
+
+			if tt.wrongCode {
+				code = "AAAA-BBBB"
+			}
+
+			statusCode, err := st.service.DisableTwoFactor(types.DisableTwoFactorReq{Password: tt.password, Code: code}, user.ID)
+			if statusCode != tt.want {
+				t.Fatalf("DisableTwoFactor() = %d, %v, want %d", statusCode, err, tt.want)
+			}
+
+			if enabled := st.isTwoFactorEnabled(t, user); enabled != (tt.want != http.StatusOK) {
+				t.Fatalf("two-factor authentication enabled = %v after DisableTwoFactor() = %d", enabled, statusCode)
+			}
+		})
+	}
+}
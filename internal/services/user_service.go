@@ -66,3 +66,21 @@ func (s *UserService) UpdateUserProfile(updateProfileReq types.UpdateProfileReq,
 
 	return user, http.StatusOK, nil
 }
+
+func (s *UserService) IsEmailVerified(userID uuid.UUID) (bool, error) {
+	user, result := s.userRepository.FindByID(userID)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return user.IsEmailVerified(), nil
+}
+
+func (s *UserService) GetUserRole(userID uuid.UUID) (models.UserRole, error) {
+	user, result := s.userRepository.FindByID(userID)
+	if result.Error != nil {
+		return "", result.Error
+	}
+
+	return user.Role, nil
+}
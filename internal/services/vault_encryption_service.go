@@ -0,0 +1,174 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"gwid.io/gwid-core/internal/config"
+)
+
+const vaultKeyID = "vault"
+
+// VaultEncryptionService does envelope encryption: every secret is encrypted
+// locally with its own data key, and only that data key is sent to a Vault
+// Transit compatible API to be wrapped by the master key. Ciphertexts have the
+// form vault:<wrapped data key>:<ciphertext>. Ciphertexts of the local backend
+// are still decrypted so they can be re-encrypted.
+type VaultEncryptionService struct {
+	config                 config.VaultConfig
+	localEncryptionService *LocalEncryptionService
+	httpClient             *http.Client
+}
+
+func NewVaultEncryptionService(cfg *config.Config, localEncryptionService *LocalEncryptionService) *VaultEncryptionService {
+	return &VaultEncryptionService{
+		config:                 cfg.Vault,
+		localEncryptionService: localEncryptionService,
+		httpClient:             &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type vaultDataKeyRes struct {
+	Data struct {
+		Plaintext  string `json:"plaintext"`
+		Ciphertext string `json:"ciphertext"`
+	} `json:"data"`
+}
+
+type vaultDecryptRes struct {
+	Data struct {
+		Plaintext string `json:"plaintext"`
+	} `json:"data"`
+}
+
+type vaultErrorRes struct {
+	Errors []string `json:"errors"`
+}
+
+func (s *VaultEncryptionService) ActiveKeyID() string {
+	return vaultKeyID
+}
+
+// transit sends a request to the transit engine, e.g. datakey/plaintext/<key>.
+func (s *VaultEncryptionService) transit(path string, body any, res any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/%s/%s", strings.TrimSuffix(s.config.Address, "/"), s.config.TransitMount, path, s.config.TransitKey)
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", s.config.Token)
+
+	if s.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.config.Namespace)
+	}
+
+	httpRes, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault request failed: %w", err)
+	}
+
+	defer httpRes.Body.Close()
+
+	resBody, err := io.ReadAll(httpRes.Body)
+	if err != nil {
+		return err
+	}
+
+	if httpRes.StatusCode != http.StatusOK {
+		var errorRes vaultErrorRes
+
+		if err := json.Unmarshal(resBody, &errorRes); err == nil && len(errorRes.Errors) > 0 {
+			return fmt.Errorf("vault %s failed with status %d: %s", path, httpRes.StatusCode, strings.Join(errorRes.Errors, "; "))
+		}
+
+		return fmt.Errorf("vault %s failed with status %d", path, httpRes.StatusCode)
+	}
+
+	return json.Unmarshal(resBody, res)
+}
+
+func (s *VaultEncryptionService) EncryptData(data []byte) (string, error) {
+	var dataKeyRes vaultDataKeyRes
+
+	if err := s.transit("datakey/plaintext", map[string]any{"bits": 256}, &dataKeyRes); err != nil {
+		return "", err
+	}
+
+	dataKey, err := base64.StdEncoding.DecodeString(dataKeyRes.Data.Plaintext)
+	if err != nil {
+		return "", fmt.Errorf("invalid data key from vault: %w", err)
+	}
+
+	cipherText, err := sealAESGCM(dataKey, data)
+	if err != nil {
+		return "", err
+	}
+
+	wrappedKey := base64.StdEncoding.EncodeToString([]byte(dataKeyRes.Data.Ciphertext))
+
+	return vaultKeyID + ":" + wrappedKey + ":" + base64.StdEncoding.EncodeToString(cipherText), nil
+}
+
+func (s *VaultEncryptionService) DecryptData(encryptedData string) (string, error) {
+	keyID, envelope, _ := strings.Cut(encryptedData, ":")
+	if keyID != vaultKeyID {
+		return s.localEncryptionService.DecryptData(encryptedData)
+	}
+
+	encodedWrappedKey, encodedCipherText, ok := strings.Cut(envelope, ":")
+	if !ok {
+		return "", errors.New("malformed envelope ciphertext")
+	}
+
+	wrappedKey, err := base64.StdEncoding.DecodeString(encodedWrappedKey)
+	if err != nil {
+		return "", err
+	}
+
+	cipherText, err := base64.StdEncoding.DecodeString(encodedCipherText)
+	if err != nil {
+		return "", err
+	}
+
+	var decryptRes vaultDecryptRes
+
+	if err := s.transit("decrypt", map[string]any{"ciphertext": string(wrappedKey)}, &decryptRes); err != nil {
+		return "", err
+	}
+
+	dataKey, err := base64.StdEncoding.DecodeString(decryptRes.Data.Plaintext)
+	if err != nil {
+		return "", fmt.Errorf("invalid data key from vault: %w", err)
+	}
+
+	data, err := openAESGCM(dataKey, cipherText)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+func (s *VaultEncryptionService) ReEncryptData(encryptedData string) (string, error) {
+	data, err := s.DecryptData(encryptedData)
+	if err != nil {
+		return "", err
+	}
+
+	return s.EncryptData([]byte(data))
+}
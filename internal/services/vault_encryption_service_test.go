@@ -0,0 +1,262 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"gwid.io/gwid-core/internal/config"
+)
+
+const (
+	testVaultToken = "test-token"
+	testVaultKey   = "gwid"
+)
+
+// fakeTransit implements the datakey and decrypt endpoints of the Vault
+// Transit engine, wrapping data keys by handing out opaque ciphertexts.
+type fakeTransit struct {
+	mu       sync.Mutex
+	dataKeys map[string]string
+	requests []string
+}
+
+func newFakeTransit() *fakeTransit {
+	return &fakeTransit{dataKeys: map[string]string{}}
+}
+
+func (f *fakeTransit) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, r.URL.Path)
+
+	if r.Method != http.MethodPost || r.Header.Get("X-Vault-Token") != testVaultToken {
+		writeVaultJSON(w, http.StatusForbidden, vaultErrorRes{Errors: []string{"permission denied"}})
+		return
+	}
+
+	switch r.URL.Path {
+	case "/v1/transit/datakey/plaintext/" + testVaultKey:
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			writeVaultJSON(w, http.StatusInternalServerError, vaultErrorRes{Errors: []string{err.Error()}})
+			return
+		}
+
+		wrapped := "vault:v1:" + base64.StdEncoding.EncodeToString(key[:8])
+		f.dataKeys[wrapped] = base64.StdEncoding.EncodeToString(key)
+
+		var res vaultDataKeyRes
+		res.Data.Plaintext = f.dataKeys[wrapped]
+		res.Data.Ciphertext = wrapped
+
+		writeVaultJSON(w, http.StatusOK, res)
+
+	case "/v1/transit/decrypt/" + testVaultKey:
+		var req struct {
+			Ciphertext string `json:"ciphertext"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeVaultJSON(w, http.StatusBadRequest, vaultErrorRes{Errors: []string{err.Error()}})
+			return
+		}
+
+		dataKey, ok := f.dataKeys[req.Ciphertext]
+		if !ok {
+			writeVaultJSON(w, http.StatusBadRequest, vaultErrorRes{Errors: []string{"invalid ciphertext: unable to decrypt"}})
+			return
+		}
+
+		var res vaultDecryptRes
+		res.Data.Plaintext = dataKey
+
+		writeVaultJSON(w, http.StatusOK, res)
+
+	default:
+		writeVaultJSON(w, http.StatusNotFound, vaultErrorRes{})
+	}
+}
+
+func writeVaultJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func newTestVaultEncryptionService(address string) *VaultEncryptionService {
+	cfg := &config.Config{
+		EncryptionKey:   "0123456789abcdef0123456789abcdef",
+		EncryptionKeyID: "k1",
+		EncryptionKeys:  map[string]string{"k1": "fedcba9876543210fedcba9876543210"},
+		Vault: config.VaultConfig{
+			Address:      address,
+			Token:        testVaultToken,
+			TransitMount: "transit",
+			TransitKey:   testVaultKey,
+		},
+	}
+
+	return NewVaultEncryptionService(cfg, NewLocalEncryptionService(cfg))
+}
+
+func TestVaultEncryptionServiceRoundTrip(t *testing.T) {
+	transit := newFakeTransit()
+	server := httptest.NewServer(transit)
+	defer server.Close()
+
+	service := newTestVaultEncryptionService(server.URL + "/")
+
+	encrypted, err := service.EncryptData([]byte("aws-secret-key"))
+	if err != nil {
+		t.Fatalf("EncryptData() error = %v", err)
+	}
+
+	if !strings.HasPrefix(encrypted, service.ActiveKeyID()+":") {
+		t.Errorf("EncryptData() = %q, want the %q key ID prefix", encrypted, service.ActiveKeyID())
+	}
+
+	if strings.Contains(encrypted, "aws-secret-key") {
+		t.Error("EncryptData() leaked the plaintext")
+	}
+
+	decrypted, err := service.DecryptData(encrypted)
+	if err != nil {
+		t.Fatalf("DecryptData() error = %v", err)
+	}
+
+	if decrypted != "aws-secret-key" {
+		t.Errorf("DecryptData() = %q, want %q", decrypted, "aws-secret-key")
+	}
+
+	reEncrypted, err := service.ReEncryptData(encrypted)
+	if err != nil {
+		t.Fatalf("ReEncryptData() error = %v", err)
+	}
+
+	if reEncrypted == encrypted {
+		t.Error("ReEncryptData() reused the data key")
+	}
+
+	if len(transit.requests) != 4 {
+		t.Errorf("transit received %d requests, want 4: %v", len(transit.requests), transit.requests)
+	}
+}
+
+func TestVaultEncryptionServiceDecryptsLocalCiphertexts(t *testing.T) {
+	transit := newFakeTransit()
+	server := httptest.NewServer(transit)
+	defer server.Close()
+
+	service := newTestVaultEncryptionService(server.URL)
+	local := service.localEncryptionService
+
+	versioned, err := local.EncryptData([]byte("versioned"))
+	if err != nil {
+		t.Fatalf("LocalEncryptionService.EncryptData() error = %v", err)
+	}
+
+	legacyKey := []byte(service.localEncryptionService.config.EncryptionKey)
+
+	sealed, err := sealAESGCM(legacyKey, []byte("legacy"))
+	if err != nil {
+		t.Fatalf("sealAESGCM() error = %v", err)
+	}
+
+	tests := map[string]struct {
+		ciphertext string
+		want       string
+	}{
+		"keyring": {ciphertext: versioned, want: "versioned"},
+		"legacy":  {ciphertext: base64.StdEncoding.EncodeToString(sealed), want: "legacy"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := service.DecryptData(tt.ciphertext)
+			if err != nil {
+				t.Fatalf("DecryptData() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("DecryptData() = %q, want %q", got, tt.want)
+			}
+
+			reEncrypted, err := service.ReEncryptData(tt.ciphertext)
+			if err != nil {
+				t.Fatalf("ReEncryptData() error = %v", err)
+			}
+
+			if !strings.HasPrefix(reEncrypted, vaultKeyID+":") {
+				t.Errorf("ReEncryptData() = %q, want a vault ciphertext", reEncrypted)
+			}
+		})
+	}
+
+	if len(transit.requests) != 2 {
+		t.Errorf("transit received %d requests, want only the 2 re-encryptions: %v", len(transit.requests), transit.requests)
+	}
+}
+
+func TestVaultEncryptionServiceErrors(t *testing.T) {
+	transit := newFakeTransit()
+	server := httptest.NewServer(transit)
+	defer server.Close()
+
+	service := newTestVaultEncryptionService(server.URL)
+
+	encrypted, err := service.EncryptData([]byte("secret"))
+	if err != nil {
+		t.Fatalf("EncryptData() error = %v", err)
+	}
+
+	t.Run("error response", func(t *testing.T) {
+		unauthorized := newTestVaultEncryptionService(server.URL)
+		unauthorized.config.Token = "wrong"
+
+		_, err := unauthorized.EncryptData([]byte("secret"))
+		if err == nil || !strings.Contains(err.Error(), "status 403") || !strings.Contains(err.Error(), "permission denied") {
+			t.Errorf("EncryptData() error = %v, want the vault status and errors", err)
+		}
+	})
+
+	t.Run("error response without body", func(t *testing.T) {
+		misconfigured := newTestVaultEncryptionService(server.URL)
+		misconfigured.config.TransitMount = "missing"
+
+		_, err := misconfigured.EncryptData([]byte("secret"))
+		if err == nil || !strings.Contains(err.Error(), "status 404") {
+			t.Errorf("EncryptData() error = %v, want the vault status", err)
+		}
+	})
+
+	t.Run("unknown wrapped key", func(t *testing.T) {
+		_, envelope, _ := strings.Cut(encrypted, ":")
+		_, cipherText, _ := strings.Cut(envelope, ":")
+		forged := vaultKeyID + ":" + base64.StdEncoding.EncodeToString([]byte("vault:v1:forged")) + ":" + cipherText
+
+		if _, err := service.DecryptData(forged); err == nil || !strings.Contains(err.Error(), "status 400") {
+			t.Errorf("DecryptData() error = %v, want the vault status", err)
+		}
+	})
+
+	t.Run("malformed envelope", func(t *testing.T) {
+		if _, err := service.DecryptData(vaultKeyID + ":missing-ciphertext"); err == nil {
+			t.Error("DecryptData() accepted a malformed envelope")
+		}
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		unreachable := newTestVaultEncryptionService("http://127.0.0.1:1")
+
+		if _, err := unreachable.DecryptData(encrypted); err == nil {
+			t.Error("DecryptData() succeeded without vault")
+		}
+	})
+}
@@ -0,0 +1,80 @@
+package types
+
+import (
+	"time"
+
+	"gwid.io/gwid-core/internal/models"
+)
+
+type SuspendUserReq struct {
+	Reason string `json:"reason" binding:"required,min=3,max=500"`
+}
+
+type UpdateUserRoleReq struct {
+	Role string `json:"role" binding:"required,oneof=regular admin"`
+}
+
+// AdminUserRes adds the fields only admins may see to UserRes.
+type AdminUserRes struct {
+	*UserRes
+	SuspendedAt      *time.Time `json:"suspended_at"`
+	SuspensionReason string     `json:"suspension_reason,omitempty"`
+}
+
+type QueueRes struct {
+	Queue     string `json:"queue"`
+	Size      int    `json:"size"`
+	Pending   int    `json:"pending"`
+	Active    int    `json:"active"`
+	Scheduled int    `json:"scheduled"`
+	Retry     int    `json:"retry"`
+	Archived  int    `json:"archived"`
+	Completed int    `json:"completed"`
+	// Processed and Failed count the tasks of the current day.
+	Processed      int     `json:"processed"`
+	Failed         int     `json:"failed"`
+	LatencySeconds float64 `json:"latency_seconds"`
+	Paused         bool    `json:"paused"`
+}
+
+type QueueServerRes struct {
+	ID            string         `json:"id"`
+	Host          string         `json:"host"`
+	PID           int            `json:"pid"`
+	Status        string         `json:"status"`
+	Concurrency   int            `json:"concurrency"`
+	Queues        map[string]int `json:"queues"`
+	ActiveWorkers int            `json:"active_workers"`
+	Started       time.Time      `json:"started"`
+}
+
+type QueueHealthRes struct {
+	Queues  []*QueueRes       `json:"queues"`
+	Servers []*QueueServerRes `json:"servers"`
+}
+
+func NewAdminUserRes(user *models.User) *AdminUserRes {
+	return &AdminUserRes{
+		UserRes:          NewUserRes(user),
+		SuspendedAt:      user.SuspendedAt,
+		SuspensionReason: user.SuspensionReason,
+	}
+}
+
+func NewAdminUserResList(users []models.User) []*AdminUserRes {
+	res := make([]*AdminUserRes, 0, len(users))
+
+	for i := range users {
+		res = append(res, NewAdminUserRes(&users[i]))
+	}
+
+	return res
+}
+
+// LoginLockoutRes describes the failed logins on an account. RetryAt is set
+// while logins are refused, either locked out or delayed.
+type LoginLockoutRes struct {
+	Locked         bool       `json:"locked"`
+	FailedAttempts int64      `json:"failed_attempts"`
+	RetryAt        *time.Time `json:"retry_at"`
+}
@@ -0,0 +1,52 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gwid.io/gwid-core/internal/models"
+)
+
+type CreateAPIKeyReq struct {
+	Name      string     `json:"name" binding:"required,min=2,max=50"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1,dive,oneof=gateways:read gateways:write credentials:read credentials:write"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type APIKeyRes struct {
+	ID            uuid.UUID  `json:"id"`
+	Name          string     `json:"name"`
+	DisplayPrefix string     `json:"display_prefix"`
+	Scopes        []string   `json:"scopes"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+	LastUsedAt    *time.Time `json:"last_used_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// CreatedAPIKeyRes is the only response that contains the key itself.
+type CreatedAPIKeyRes struct {
+	*APIKeyRes
+	Key string `json:"key"`
+}
+
+func NewAPIKeyRes(apiKey *models.APIKey) *APIKeyRes {
+	return &APIKeyRes{
+		ID:            apiKey.ID,
+		Name:          apiKey.Name,
+		DisplayPrefix: apiKey.DisplayPrefix,
+		Scopes:        apiKey.Scopes,
+		ExpiresAt:     apiKey.ExpiresAt,
+		LastUsedAt:    apiKey.LastUsedAt,
+		CreatedAt:     apiKey.CreatedAt,
+	}
+}
+
+func NewAPIKeyResList(apiKeys []models.APIKey) []*APIKeyRes {
+	res := make([]*APIKeyRes, 0, len(apiKeys))
+
+	for i := range apiKeys {
+		res = append(res, NewAPIKeyRes(&apiKeys[i]))
+	}
+
+	return res
+}
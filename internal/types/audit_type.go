@@ -0,0 +1,46 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gwid.io/gwid-core/internal/models"
+)
+
+type AuditEventRes struct {
+	ID         uuid.UUID      `json:"id"`
+	ActorID    *uuid.UUID     `json:"actor_id"`
+	Action     string         `json:"action"`
+	TargetType string         `json:"target_type"`
+	TargetID   string         `json:"target_id"`
+	Metadata   map[string]any `json:"metadata"`
+	IPAddress  string         `json:"ip_address"`
+	UserAgent  string         `json:"user_agent"`
+	RequestID  string         `json:"request_id"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+func NewAuditEventRes(event *models.AuditEvent) *AuditEventRes {
+	return &AuditEventRes{
+		ID:         event.ID,
+		ActorID:    event.ActorID,
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		Metadata:   event.Metadata,
+		IPAddress:  event.IPAddress,
+		UserAgent:  event.UserAgent,
+		RequestID:  event.RequestID,
+		CreatedAt:  event.CreatedAt,
+	}
+}
+
+func NewAuditEventResList(events []models.AuditEvent) []*AuditEventRes {
+	res := make([]*AuditEventRes, 0, len(events))
+
+	for i := range events {
+		res = append(res, NewAuditEventRes(&events[i]))
+	}
+
+	return res
+}
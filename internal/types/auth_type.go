@@ -3,9 +3,19 @@ package types
 import "github.com/google/uuid"
 
 type AuthRes struct {
-	ID          uuid.UUID `json:"id"`
-	Role        string    `json:"role"`
-	AccessToken string    `json:"access_token"`
+	ID           uuid.UUID `json:"id"`
+	Role         string    `json:"role"`
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresIn    int       `json:"expires_in"`
+}
+
+// SessionMeta describes the client a session is created for, or that an
+// audited action came from.
+type SessionMeta struct {
+	UserAgent string
+	IPAddress string
+	RequestID string
 }
 
 type SignupReq struct {
@@ -24,3 +34,59 @@ type ChangePasswordReq struct {
 	CurrentPassword string `json:"current_password" binding:"required"`
 	NewPassword     string `json:"new_password" binding:"required,min=8"`
 }
+
+type RefreshTokenReq struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type ForgotPasswordReq struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordReq struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8"`
+}
+
+type VerifyEmailReq struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// TwoFactorChallengeRes is returned by login instead of tokens when the user
+// has 2FA enabled. The challenge token is exchanged for tokens together with
+// a TOTP or recovery code.
+type TwoFactorChallengeRes struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int    `json:"expires_in"`
+}
+
+type TwoFactorLoginReq struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+type TwoFactorSetupRes struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type TwoFactorCodeReq struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DisableTwoFactorReq leaves the password out for users who have none, see
+// TwoFactorService.DisableTwoFactor.
+type DisableTwoFactorReq struct {
+	Password string `json:"password"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type RecoveryCodesRes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type OAuthCallbackReq struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
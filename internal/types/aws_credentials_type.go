@@ -1,6 +1,21 @@
 package types
 
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gwid.io/gwid-core/internal/models"
+)
+
 type AWSCredentialsReq struct {
+	Kind            string `json:"kind" binding:"omitempty,oneof=access_key assume_role"`
+	AccessKeyID     string `json:"access_key_id" binding:"required_unless=Kind assume_role,omitempty,min=16,max=128"`
+	SecretAccessKey string `json:"secret_access_key" binding:"required_unless=Kind assume_role,omitempty,min=16"`
+	AssumeRoleARN   string `json:"assume_role_arn" binding:"required_if=Kind assume_role,omitempty,startswith=arn:aws:iam::"`
+}
+
+type UpdateAWSCredentialsReq struct {
 	AccessKeyID     string `json:"access_key_id" binding:"required,min=16,max=128"`
 	SecretAccessKey string `json:"secret_access_key" binding:"required,min=16"`
 }
@@ -11,3 +26,71 @@ type AWSCredentailsProfile struct {
 	RoleName    string
 	RoleARN     string
 }
+
+type AWSExternalIDRes struct {
+	AccountID   string `json:"account_id"`
+	ExternalID  string `json:"external_id"`
+	TrustPolicy string `json:"trust_policy"`
+}
+
+type AWSCredentialsRes struct {
+	ID             uuid.UUID `json:"id"`
+	Kind           string    `json:"kind"`
+	AccessKeyID    *string   `json:"access_key_id"`
+	AssumeRoleARN  *string   `json:"assume_role_arn"`
+	RoleARN        string    `json:"role_arn"`
+	ProfileARN     string    `json:"profile_arn"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	CreatedByID    uuid.UUID `json:"created_by_id"`
+	// DeletionRequestedAt is set while the credentials are being deleted.
+	DeletionRequestedAt *time.Time `json:"deletion_requested_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// NewAWSCredentialsRes exposes credentials without their secret, showing only
+// the first and last four characters of the access key ID.
+func NewAWSCredentialsRes(credentials *models.AWSCredentials) *AWSCredentialsRes {
+	res := &AWSCredentialsRes{
+		ID:                  credentials.ID,
+		Kind:                string(credentials.Kind),
+		AssumeRoleARN:       credentials.AssumeRoleARN,
+		RoleARN:             credentials.RoleARN,
+		ProfileARN:          credentials.ProfileARN,
+		OrganizationID:      credentials.OrganizationID,
+		DeletionRequestedAt: credentials.DeletionRequestedAt,
+		CreatedByID:         credentials.UserID,
+		CreatedAt:           credentials.CreatedAt,
+		UpdatedAt:           credentials.UpdatedAt,
+	}
+
+	if credentials.AccessKeyID != nil {
+		maskedAccessKeyID := maskAccessKeyID(*credentials.AccessKeyID)
+		res.AccessKeyID = &maskedAccessKeyID
+	}
+
+	return res
+}
+
+func NewAWSCredentialsResList(credentials []models.AWSCredentials) []*AWSCredentialsRes {
+	res := make([]*AWSCredentialsRes, 0, len(credentials))
+
+	for i := range credentials {
+		res = append(res, NewAWSCredentialsRes(&credentials[i]))
+	}
+
+	return res
+}
+
+func maskAccessKeyID(accessKeyID string) string {
+	if len(accessKeyID) <= 8 {
+		return strings.Repeat("*", len(accessKeyID))
+	}
+
+	return accessKeyID[:4] + strings.Repeat("*", len(accessKeyID)-8) + accessKeyID[len(accessKeyID)-4:]
+}
+
+type DeleteAWSCredentialsPayload struct {
+	CredentialsID  uuid.UUID
+	OrganizationID uuid.UUID
+}
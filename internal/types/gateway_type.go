@@ -1,7 +1,24 @@
 // Package types
 package types
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gwid.io/gwid-core/internal/models"
+)
+
+type CreateGatewayReq struct {
+	Provider           string    `json:"provider" binding:"required,oneof=aws"`
+	CredentialsID      uuid.UUID `json:"credentials_id" binding:"required,uuid"`
+	InstanceTypeID     uuid.UUID `json:"instance_type_id" binding:"required,uuid"`
+	Region             string    `json:"region" binding:"required"`
+	RPCURL             string    `json:"rpc_url" binding:"required,url"`
+	Password           string    `json:"password" binding:"required,min=8"`
+	GatewayType        string    `json:"gateway_type" binding:"required,oneof=ai transcoding"`
+	GatewayName        string    `json:"gateway_name" binding:"required,min=3"`
+	TranscodingProfile string    `json:"transcoding_profile" binding:"required,oneof=480p 720p 1080p"`
+}
 
 type CreateGatewayWithAWSReq struct {
 	CredentialsID      uuid.UUID `json:"credentials_id" binding:"required,uuid"`
@@ -16,10 +33,39 @@ type CreateGatewayWithAWSReq struct {
 
 type CreateEC2InstanceReq struct {
 	InstanceName      string
+	Region            string
 	CredentialsID     uuid.UUID `json:"credentials_id" binding:"required,uuid"`
 	EC2InstanceTypeID uuid.UUID `json:"ec2_instance_type_id" binding:"required,uuid"`
 }
 
+type CreateInstanceReq struct {
+	InstanceName   string
+	Region         string
+	CredentialsID  uuid.UUID
+	InstanceTypeID uuid.UUID
+	OrganizationID uuid.UUID
+}
+
+type InstanceTarget struct {
+	InstanceID     string
+	Region         string
+	CredentialsID  uuid.UUID
+	OrganizationID uuid.UUID
+}
+
+type DeployGatewayPayload struct {
+	GatewayID        uuid.UUID
+	OrganizationID   uuid.UUID
+	UnhashedPassword string
+}
+
+type GatewayActionPayload struct {
+	GatewayID      uuid.UUID
+	OrganizationID uuid.UUID
+}
+
+// DeployAWSGatewayPayload is the payload of the AWS-only deploy task, which
+// addressed gateways by their user.
 type DeployAWSGatewayPayload struct {
 	GatewayID        uuid.UUID
 	CredentialsID    uuid.UUID
@@ -28,3 +74,72 @@ type DeployAWSGatewayPayload struct {
 	UnhashedPassword string
 	Region           string
 }
+
+type GatewayDeployScriptParams struct {
+	GatewayName        string
+	GatewayType        string
+	RPCURL             string
+	Password           string
+	TranscodingProfile string
+	LivepeerVersion    string
+}
+
+type GatewayDeployResult struct {
+	Success         bool   `json:"success"`
+	ScriptVersion   string `json:"script_version"`
+	LivepeerVersion string `json:"livepeer_version"`
+	Step            string `json:"step"`
+	Error           string `json:"error"`
+}
+
+type GatewayRes struct {
+	ID                 uuid.UUID `json:"id"`
+	Provider           string    `json:"provider"`
+	Region             string    `json:"region"`
+	GatewayName        string    `json:"gateway_name"`
+	GatewayType        string    `json:"gateway_type"`
+	RPCURL             string    `json:"rpc_url"`
+	TranscodingProfile string    `json:"transcoding_profile"`
+	Status             string    `json:"status"`
+	ErrorStatus        string    `json:"error_status"`
+	InstanceID         *string   `json:"instance_id"`
+	PublicIP           *string   `json:"public_ip"`
+	Subdomain          *string   `json:"subdomain"`
+	AWSCredentialsID   uuid.UUID `json:"aws_credentials_id"`
+	OrganizationID     uuid.UUID `json:"organization_id"`
+	CreatedByID        uuid.UUID `json:"created_by_id"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+func NewGatewayRes(gateway *models.Gateway) *GatewayRes {
+	return &GatewayRes{
+		ID:                 gateway.ID,
+		Provider:           gateway.Provider,
+		Region:             gateway.Region,
+		GatewayName:        gateway.GatewayName,
+		GatewayType:        gateway.GatewayType,
+		RPCURL:             gateway.RPCURL,
+		TranscodingProfile: gateway.TranscodingProfile,
+		Status:             string(gateway.Status),
+		ErrorStatus:        gateway.ErrorStatus,
+		InstanceID:         gateway.InstanceID,
+		PublicIP:           gateway.PublicIP,
+		Subdomain:          gateway.Subdomain,
+		AWSCredentialsID:   gateway.AWSCredentialsID,
+		OrganizationID:     gateway.OrganizationID,
+		CreatedByID:        gateway.UserID,
+		CreatedAt:          gateway.CreatedAt,
+		UpdatedAt:          gateway.UpdatedAt,
+	}
+}
+
+func NewGatewayResList(gateways []models.Gateway) []*GatewayRes {
+	res := make([]*GatewayRes, 0, len(gateways))
+
+	for i := range gateways {
+		res = append(res, NewGatewayRes(&gateways[i]))
+	}
+
+	return res
+}
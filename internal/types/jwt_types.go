@@ -6,7 +6,13 @@ import (
 )
 
 type JwtCustomClaims struct {
-	ID   uuid.UUID `json:"id"`
-	Role string    `json:"role"`
+	ID        uuid.UUID `json:"id"`
+	Role      string    `json:"role"`
+	SessionID uuid.UUID `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+type TwoFactorChallengeClaims struct {
+	ID uuid.UUID `json:"id"`
 	jwt.RegisteredClaims
 }
@@ -0,0 +1,120 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gwid.io/gwid-core/internal/models"
+)
+
+// OrganizationContext is the organization a request acts in and the role of
+// the user in it, set by OrganizationMiddleware.
+type OrganizationContext struct {
+	ID   uuid.UUID
+	Role models.OrganizationRole
+}
+
+type CreateOrganizationReq struct {
+	Name string `json:"name" binding:"required,min=2,max=100"`
+}
+
+type UpdateOrganizationReq struct {
+	Name string `json:"name" binding:"required,min=2,max=100"`
+}
+
+type InviteMemberReq struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=owner admin member viewer"`
+}
+
+type UpdateMemberRoleReq struct {
+	Role string `json:"role" binding:"required,oneof=owner admin member viewer"`
+}
+
+type AcceptInvitationReq struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type OrganizationRes struct {
+	ID         uuid.UUID               `json:"id"`
+	Name       string                  `json:"name"`
+	IsPersonal bool                    `json:"is_personal"`
+	Role       models.OrganizationRole `json:"role"`
+	CreatedAt  time.Time               `json:"created_at"`
+}
+
+type OrganizationMemberRes struct {
+	UserID    uuid.UUID               `json:"user_id"`
+	Name      string                  `json:"name"`
+	Email     string                  `json:"email"`
+	Role      models.OrganizationRole `json:"role"`
+	CreatedAt time.Time               `json:"created_at"`
+}
+
+type OrganizationInvitationRes struct {
+	ID        uuid.UUID               `json:"id"`
+	Email     string                  `json:"email"`
+	Role      models.OrganizationRole `json:"role"`
+	ExpiresAt time.Time               `json:"expires_at"`
+	CreatedAt time.Time               `json:"created_at"`
+}
+
+func NewOrganizationRes(organization *models.Organization, role models.OrganizationRole) *OrganizationRes {
+	return &OrganizationRes{
+		ID:         organization.ID,
+		Name:       organization.Name,
+		IsPersonal: organization.IsPersonal(),
+		Role:       role,
+		CreatedAt:  organization.CreatedAt,
+	}
+}
+
+// NewOrganizationResList expects the memberships to have their organization
+// preloaded.
+func NewOrganizationResList(memberships []models.OrganizationMember) []*OrganizationRes {
+	res := make([]*OrganizationRes, 0, len(memberships))
+
+	for i := range memberships {
+		res = append(res, NewOrganizationRes(memberships[i].Organization, memberships[i].Role))
+	}
+
+	return res
+}
+
+// NewOrganizationMemberResList expects the members to have their user
+// preloaded.
+func NewOrganizationMemberResList(members []models.OrganizationMember) []*OrganizationMemberRes {
+	res := make([]*OrganizationMemberRes, 0, len(members))
+
+	for _, member := range members {
+		res = append(res, &OrganizationMemberRes{
+			UserID:    member.UserID,
+			Name:      member.User.Name,
+			Email:     member.User.Email,
+			Role:      member.Role,
+			CreatedAt: member.CreatedAt,
+		})
+	}
+
+	return res
+}
+
+func NewOrganizationInvitationRes(invitation *models.OrganizationInvitation) *OrganizationInvitationRes {
+	return &OrganizationInvitationRes{
+		ID:        invitation.ID,
+		Email:     invitation.Email,
+		Role:      invitation.Role,
+		ExpiresAt: invitation.ExpiresAt,
+		CreatedAt: invitation.CreatedAt,
+	}
+}
+
+func NewOrganizationInvitationResList(invitations []models.OrganizationInvitation) []*OrganizationInvitationRes {
+	res := make([]*OrganizationInvitationRes, 0, len(invitations))
+
+	for i := range invitations {
+		res = append(res, NewOrganizationInvitationRes(&invitations[i]))
+	}
+
+	return res
+}
@@ -1,5 +1,67 @@
 package types
 
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gwid.io/gwid-core/internal/models"
+)
+
 type UpdateProfileReq struct {
 	Name string `json:"name" binding:"required,min=2,max=30"`
 }
+
+type UserRes struct {
+	ID           uuid.UUID `json:"id"`
+	Name         string    `json:"name"`
+	Email        string    `json:"email"`
+	Role         string    `json:"role"`
+	ReferralCode string    `json:"referral_code"`
+	// EmailVerifiedAt is nil until the user follows the link sent at signup.
+	EmailVerifiedAt  *time.Time `json:"email_verified_at"`
+	TwoFactorEnabled bool       `json:"two_factor_enabled"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+func NewUserRes(user *models.User) *UserRes {
+	return &UserRes{
+		ID:               user.ID,
+		Name:             user.Name,
+		Email:            user.Email,
+		Role:             string(user.Role),
+		ReferralCode:     user.ReferralCode,
+		EmailVerifiedAt:  user.EmailVerifiedAt,
+		TwoFactorEnabled: user.IsTwoFactorEnabled(),
+		CreatedAt:        user.CreatedAt,
+		UpdatedAt:        user.UpdatedAt,
+	}
+}
+
+type DeleteAccountReq struct {
+	Password string `json:"password" binding:"required"`
+}
+
+type AccountDeletionRes struct {
+	ID          uuid.UUID  `json:"id"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+func NewAccountDeletionRes(deletion *models.AccountDeletion) *AccountDeletionRes {
+	return &AccountDeletionRes{
+		ID:          deletion.ID,
+		Status:      string(deletion.Status),
+		Error:       deletion.Error,
+		CreatedAt:   deletion.CreatedAt,
+		UpdatedAt:   deletion.UpdatedAt,
+		CompletedAt: deletion.CompletedAt,
+	}
+}
+
+type DeleteAccountPayload struct {
+	AccountDeletionID uuid.UUID
+}
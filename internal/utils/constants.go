@@ -1,5 +1,22 @@
 package utils
 
+const (
+	DefaultQueue = "default"
+)
+
+const (
+	TypeDeployGateway    = "deploy:gateway"
+	TypeTerminateGateway = "terminate:gateway"
+	TypeStopGateway      = "stop:gateway"
+	TypeStartGateway     = "start:gateway"
+	TypeRebootGateway    = "reboot:gateway"
+	TypeDeleteAccount    = "delete:account"
+
+	TypeDeleteAWSCredentials = "delete:aws-credentials"
+)
+
+// TypeDeployAWSGateway is the deploy task queued before gateways were deployed
+// through compute providers.
 const (
 	TypeDeployAWSGateway = "deploy:aws-gateway"
 )
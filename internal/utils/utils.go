@@ -2,8 +2,10 @@ package utils
 
 import (
 	"crypto/rand"
+	"fmt"
 	"math/big"
 	"strings"
+	"time"
 	"unicode"
 )
 
@@ -94,3 +96,23 @@ func GenerateReferralCode() (string, error) {
 
 	return builder.String(), nil
 }
+
+// HumanizeDuration spells a duration out in its largest whole unit for
+// emails, e.g. "15 minutes" or "1 hour".
+func HumanizeDuration(d time.Duration) string {
+	unit, size := "second", time.Second
+
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		unit, size = "hour", time.Hour
+	case d >= time.Minute && d%time.Minute == 0:
+		unit, size = "minute", time.Minute
+	}
+
+	count := int64(d / size)
+	if count == 1 {
+		return "1 " + unit
+	}
+
+	return fmt.Sprintf("%d %ss", count, unit)
+}